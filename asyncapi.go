@@ -1,43 +1,49 @@
 package steel
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
 	"reflect"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
-	json "github.com/json-iterator/go"
 )
 
 // AsyncAPISpec represents the structure of an AsyncAPI specification document.
 type AsyncAPISpec struct {
-	AsyncAPI   string                     `json:"asyncapi"`
-	Info       AsyncAPIInfo               `json:"info"`
-	Servers    map[string]AsyncAPIServer  `json:"servers"`
-	Channels   map[string]AsyncAPIChannel `json:"channels"`
-	Components AsyncAPIComponents         `json:"components"`
+	AsyncAPI           string                     `json:"asyncapi"`
+	Info               AsyncAPIInfo               `json:"info"`
+	Servers            map[string]AsyncAPIServer  `json:"servers"`
+	DefaultContentType string                     `json:"defaultContentType,omitempty"`
+	Channels           map[string]AsyncAPIChannel `json:"channels"`
+	Components         AsyncAPIComponents         `json:"components"`
+	Tags               []AsyncAPITag              `json:"tags,omitempty"`
+	ExternalDocs       *AsyncAPIExternalDocs      `json:"externalDocs,omitempty"`
 }
 
 // AsyncAPIInfo represents metadata about an AsyncAPI specification.
 // It includes the title, version, and a description of the API.
+// Tags and ExternalDocs are only set in 3.0 documents, which moved them from the document root into info.
 type AsyncAPIInfo struct {
-	Title       string `json:"title"`
-	Version     string `json:"version"`
-	Description string `json:"description"`
+	Title        string                `json:"title"`
+	Version      string                `json:"version"`
+	Description  string                `json:"description"`
+	Tags         []AsyncAPITag         `json:"tags,omitempty"`
+	ExternalDocs *AsyncAPIExternalDocs `json:"externalDocs,omitempty"`
 }
 
 // AsyncAPIServer represents a server object in an AsyncAPI specification, containing its URL, protocol, and other details.
 type AsyncAPIServer struct {
-	URL         string                 `json:"url"`
-	Protocol    string                 `json:"protocol"`
-	Description string                 `json:"description,omitempty"`
-	Variables   map[string]interface{} `json:"variables,omitempty"`
+	URL         string                       `json:"url"`
+	Protocol    string                       `json:"protocol"`
+	Description string                       `json:"description,omitempty"`
+	Variables   map[string]interface{}       `json:"variables,omitempty"`
+	Security    []OpenAPISecurityRequirement `json:"security,omitempty"`
 }
 
 // AsyncAPIChannel represents a communication channel in an AsyncAPI specification.
@@ -52,12 +58,14 @@ type AsyncAPIChannel struct {
 
 // AsyncAPIOperation represents an operation in an AsyncAPI definition, such as publish or subscribe in a channel description.
 type AsyncAPIOperation struct {
-	OperationID string                 `json:"operationId,omitempty"`
-	Summary     string                 `json:"summary,omitempty"`
-	Description string                 `json:"description,omitempty"`
-	Tags        []AsyncAPITag          `json:"tags,omitempty"`
-	Message     AsyncAPIMessage        `json:"message"`
-	Bindings    map[string]interface{} `json:"bindings,omitempty"`
+	OperationID string                       `json:"operationId,omitempty"`
+	Summary     string                       `json:"summary,omitempty"`
+	Description string                       `json:"description,omitempty"`
+	Tags        []AsyncAPITag                `json:"tags,omitempty"`
+	Message     AsyncAPIMessage              `json:"message"`
+	Security    []OpenAPISecurityRequirement `json:"security,omitempty"`
+	Bindings    map[string]interface{}       `json:"bindings,omitempty"`
+	Deprecated  bool                         `json:"x-deprecated,omitempty"`
 }
 
 // AsyncAPIMessage represents a message in an AsyncAPI specification, including metadata, payload, headers, and bindings.
@@ -68,22 +76,15 @@ type AsyncAPIMessage struct {
 	Description string                 `json:"description,omitempty"`
 	ContentType string                 `json:"contentType,omitempty"`
 	Payload     AsyncAPISchema         `json:"payload,omitempty"`
-	Headers     AsyncAPISchema         `json:"headers,omitempty"`
+	Headers     *AsyncAPISchema        `json:"headers,omitempty"`
 	Examples    []AsyncAPIExample      `json:"examples,omitempty"`
 	Bindings    map[string]interface{} `json:"bindings,omitempty"`
+	OneOf       []AsyncAPIMessage      `json:"oneOf,omitempty"`
 }
 
-// AsyncAPISchema defines a schema object used to describe message payloads, including type, format, and properties.
-type AsyncAPISchema struct {
-	Type        string                    `json:"type,omitempty"`
-	Format      string                    `json:"format,omitempty"`
-	Description string                    `json:"description,omitempty"`
-	Properties  map[string]AsyncAPISchema `json:"properties,omitempty"`
-	Required    []string                  `json:"required,omitempty"`
-	Items       *AsyncAPISchema           `json:"items,omitempty"`
-	Ref         string                    `json:"$ref,omitempty"`
-	Example     interface{}               `json:"example,omitempty"`
-}
+// AsyncAPISchema defines a schema object used to describe message payloads.
+// It is the same JSON Schema model used by the OpenAPI generator, so types documented in both specifications are identical.
+type AsyncAPISchema = OpenAPISchema
 
 // AsyncAPIParam represents an AsyncAPI parameter with a description, associated schema, and its location in the API.
 type AsyncAPIParam struct {
@@ -94,21 +95,29 @@ type AsyncAPIParam struct {
 
 // AsyncAPITag represents a tag used in the AsyncAPI specification, including a name and an optional description.
 type AsyncAPITag struct {
-	Name        string `json:"name"`
-	Description string `json:"description,omitempty"`
+	Name         string                `json:"name"`
+	Description  string                `json:"description,omitempty"`
+	ExternalDocs *AsyncAPIExternalDocs `json:"externalDocs,omitempty"`
 }
 
-// AsyncAPIExample represents an example object used in AsyncAPI messages, containing a name, summary, and value.
+// AsyncAPIExternalDocs references external documentation. It has the same shape as its OpenAPI counterpart.
+type AsyncAPIExternalDocs = OpenAPIExternalDocs
+
+// AsyncAPIExample represents an example object used in AsyncAPI messages, containing a name, summary, payload and headers.
+// Value is kept for compatibility; new code should set Payload.
 type AsyncAPIExample struct {
-	Name    string      `json:"name,omitempty"`
-	Summary string      `json:"summary,omitempty"`
-	Value   interface{} `json:"value,omitempty"`
+	Name    string                 `json:"name,omitempty"`
+	Summary string                 `json:"summary,omitempty"`
+	Headers map[string]interface{} `json:"headers,omitempty"`
+	Payload interface{}            `json:"payload,omitempty"`
+	Value   interface{}            `json:"value,omitempty"`
 }
 
 // AsyncAPIComponents represents the components section of an AsyncAPI document, holding reusable definitions for schemas and messages.
 type AsyncAPIComponents struct {
-	Schemas  map[string]AsyncAPISchema  `json:"schemas,omitempty"`
-	Messages map[string]AsyncAPIMessage `json:"messages,omitempty"`
+	Schemas         map[string]AsyncAPISchema         `json:"schemas,omitempty"`
+	Messages        map[string]AsyncAPIMessage        `json:"messages,omitempty"`
+	SecuritySchemes map[string]AsyncAPISecurityScheme `json:"securitySchemes,omitempty"`
 }
 
 // ConnectionManager manages WebSocket and Server-Sent Event connections concurrently.
@@ -135,18 +144,27 @@ func (cm *ConnectionManager) AddWSConnection(id string, conn *WSConnection) {
 	cm.wsConnections[id] = conn
 }
 
-// WSConnections returns the map of active WebSocket connections managed by the ConnectionManager.
+// WSConnections returns a snapshot of the active WebSocket connections managed by the ConnectionManager.
 func (cm *ConnectionManager) WSConnections() map[string]*WSConnection {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	return cm.wsConnections
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	connections := make(map[string]*WSConnection, len(cm.wsConnections))
+	for id, conn := range cm.wsConnections {
+		connections[id] = conn
+	}
+	return connections
 }
 
-// SSEConnections returns the map of all active Server-Sent Events (SSE) connections managed by the ConnectionManager.
+// SSEConnections returns a snapshot of the active Server-Sent Events (SSE) connections managed by the ConnectionManager.
+// Connections are removed as soon as the client disconnects or the connection is closed.
 func (cm *ConnectionManager) SSEConnections() map[string]*SSEConnection {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
-	return cm.sseConnections
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	connections := make(map[string]*SSEConnection, len(cm.sseConnections))
+	for id, conn := range cm.sseConnections {
+		connections[id] = conn
+	}
+	return connections
 }
 
 // RemoveWSConnection removes a WebSocket connection from the connection manager using the provided connection ID.
@@ -192,19 +210,13 @@ func (cm *ConnectionManager) BroadcastSSE(message SSEMessage) {
 func (r *SteelRouter) initAsyncAPI() {
 	if r.asyncAPISpec == nil {
 		r.asyncAPISpec = &AsyncAPISpec{
-			AsyncAPI: "2.6.0",
+			AsyncAPI: AsyncAPIVersion2,
 			Info: AsyncAPIInfo{
 				Title:       r.options.OpenAPITitle,
 				Version:     r.options.OpenAPIVersion,
 				Description: r.options.OpenAPIDescription,
 			},
-			Servers: map[string]AsyncAPIServer{
-				"production": {
-					URL:         "ws://localhost:8080",
-					Protocol:    "ws",
-					Description: "WebSocket server",
-				},
-			},
+			Servers:  make(map[string]AsyncAPIServer),
 			Channels: make(map[string]AsyncAPIChannel),
 			Components: AsyncAPIComponents{
 				Schemas:  make(map[string]AsyncAPISchema),
@@ -213,6 +225,11 @@ func (r *SteelRouter) initAsyncAPI() {
 		}
 	}
 
+	if r.asyncAPI3Spec == nil {
+		r.asyncAPI3Spec = newAsyncAPI3Spec(r.asyncAPISpec)
+		r.asyncAPIChannelIDs = make(map[string]string)
+	}
+
 	if r.wsHandlers == nil {
 		r.wsHandlers = make(map[string]*WSHandlerInfo)
 	}
@@ -268,16 +285,25 @@ func (r *SteelRouter) registerWSHandler(pattern string, handler interface{}, opt
 	}
 
 	r.wsHandlers[pattern] = info
-	r.generateAsyncAPIForWS(info)
+	r.updateSpec(func() {
+		r.generateAsyncAPIForWS(info)
+	})
 
-	// Create HTTP handler for WebSocket upgrade
+	r.addRoute("GET", pattern, r.wsUpgradeHandler(info, func(wsConn *WSConnection) {
+		r.handleWebSocketConnection(wsConn, handler, messageType, responseType)
+	})).kind = endpointWebSocket
+}
+
+// wsUpgradeHandler returns the HTTP handler that upgrades requests for a WebSocket endpoint,
+// registers the connection with the connection manager and hands it to serve.
+func (r *SteelRouter) wsUpgradeHandler(info *WSHandlerInfo, serve func(wsConn *WSConnection)) HandlerFunc {
 	upgrader := websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Configure as needed
 		},
 	}
 
-	httpHandler := func(w http.ResponseWriter, req *http.Request) {
+	return func(w http.ResponseWriter, req *http.Request) {
 		conn, err := upgrader.Upgrade(w, req, nil)
 		if err != nil {
 			log.Printf("WebSocket upgrade error: %v", err)
@@ -297,16 +323,18 @@ func (r *SteelRouter) registerWSHandler(pattern string, handler interface{}, opt
 			params:   params,
 			request:  req,
 			clientID: clientID,
+			codec:    info.Codec,
 			metadata: make(map[string]interface{}),
+
+			requestTimeout: info.RequestTimeout,
+			acknowledge:    info.Acknowledge,
 		}
 
 		r.connectionManager.AddWSConnection(clientID, wsConn)
 		defer r.connectionManager.RemoveWSConnection(clientID)
 
-		r.handleWebSocketConnection(wsConn, handler, messageType, responseType)
+		serve(wsConn)
 	}
-
-	r.GET(pattern, httpHandler)
 }
 
 // handleWebSocketConnection manages communication with a WebSocket client, handling incoming messages and sending responses.
@@ -318,58 +346,93 @@ func (r *SteelRouter) handleWebSocketConnection(wsConn *WSConnection, handler in
 	handlerValue := reflect.ValueOf(handler)
 	defer wsConn.Close()
 
-	for {
-		var rawMessage WSMessage
-		if err := wsConn.conn.ReadJSON(&rawMessage); err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
-			}
-			break
-		}
+	for inbound := range wsConn.startReader() {
+		rawMessage := inbound.message
 
-		// Parse message payload into expected type
-		payloadData, _ := json.Marshal(rawMessage.Payload)
-		message := reflect.New(messageType).Interface()
-
-		if err := json.Unmarshal(payloadData, message); err != nil {
+		if inbound.err != nil {
 			wsConn.SendMessage(WSMessage{
-				Type: "error",
-				Error: &WSError{
-					Code:    "INVALID_MESSAGE",
-					Message: "Failed to parse message",
-					Details: err.Error(),
-				},
+				Type:  WSMessageTypeError,
+				Error: NewWSError(WSErrorInvalidMessage, "Failed to decode message", inbound.err.Error()),
 			})
 			continue
 		}
 
-		// Call handler
-		results := handlerValue.Call([]reflect.Value{
-			reflect.ValueOf(wsConn),
-			reflect.ValueOf(message).Elem(),
-		})
+		r.handleWebSocketMessage(wsConn, handlerValue, messageType, rawMessage)
+	}
+}
 
-		response := results[0]
-		err := results[1]
+// handleWebSocketMessage decodes one message for the handler and sends its response or error,
+// in a consumer span that is a child of the span of the upgrade request when tracing is enabled.
+func (r *SteelRouter) handleWebSocketMessage(wsConn *WSConnection, handlerValue reflect.Value, messageType reflect.Type, rawMessage WSMessage) {
+	_, span := StartSpan(wsConn.request.Context(), "WS "+RoutePattern(wsConn.request), SpanKindConsumer)
+	defer span.End()
+	span.SetAttribute("messaging.system", "websocket")
+	span.SetAttribute("messaging.operation", rawMessage.Type)
+	if rawMessage.ID != "" {
+		span.SetAttribute("messaging.message.id", rawMessage.ID)
+	}
 
-		if !err.IsNil() {
+	// A panicking handler fails the message, not the connection
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := r.reportPanic(wsConn.request, value)
+			span.RecordError(panicErr)
+			span.SetStatus(SpanStatusError, panicErr.Error())
 			wsConn.SendMessage(WSMessage{
-				Type: "error",
-				Error: &WSError{
-					Code:    "HANDLER_ERROR",
-					Message: err.Interface().(error).Error(),
-				},
+				Type:  WSMessageTypeError,
+				ID:    rawMessage.ID,
+				Error: NewWSError(WSErrorInternal, "Internal server error"),
 			})
-			continue
 		}
+	}()
 
-		if !response.IsNil() {
-			wsConn.SendMessage(WSMessage{
-				Type:    "response",
-				Payload: response.Interface(),
-				ID:      rawMessage.ID,
-			})
-		}
+	if wsConn.acknowledge && rawMessage.ID != "" {
+		wsConn.SendMessage(WSMessage{Type: WSMessageTypeAck, ID: rawMessage.ID})
+	}
+
+	// Parse message payload into expected type
+	codec := wsConn.Codec()
+	payloadData, _ := codec.Marshal(rawMessage.Payload)
+	message := reflect.New(messageType).Interface()
+
+	if err := codec.Unmarshal(payloadData, message); err != nil {
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, "Failed to parse message")
+		wsConn.SendMessage(WSMessage{
+			Type:  WSMessageTypeError,
+			ID:    rawMessage.ID,
+			Error: NewWSError(WSErrorInvalidMessage, "Failed to parse message", err.Error()),
+		})
+		return
+	}
+
+	// Call handler
+	results := handlerValue.Call([]reflect.Value{
+		reflect.ValueOf(wsConn),
+		reflect.ValueOf(message).Elem(),
+	})
+
+	response := results[0]
+	handlerErr := results[1]
+
+	if !handlerErr.IsNil() {
+		err := handlerErr.Interface().(error)
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, err.Error())
+		wsConn.SendMessage(WSMessage{
+			Type:  WSMessageTypeError,
+			ID:    rawMessage.ID,
+			Error: toWSError(err),
+		})
+		return
+	}
+
+	if !response.IsNil() {
+		wsConn.SendMessage(WSMessage{
+			Type:    WSMessageTypeResponse,
+			Payload: response.Interface(),
+			ID:      rawMessage.ID,
+		})
 	}
 }
 
@@ -406,13 +469,26 @@ func (r *SteelRouter) registerSSEHandler(pattern string, handler interface{}, op
 	}
 
 	r.sseHandlers[pattern] = info
-	r.generateAsyncAPIForSSE(info)
+	r.updateSpec(func() {
+		r.generateAsyncAPIForSSE(info)
+	})
+
+	heartbeat := info.HeartbeatInterval
+	if heartbeat == 0 {
+		heartbeat = DefaultSSEHeartbeatInterval
+	}
 
 	httpHandler := func(w http.ResponseWriter, req *http.Request) {
+		if !canFlush(w) {
+			r.handleError(w, req, InternalServerError(ErrSSEStreamingUnsupported.Error()))
+			return
+		}
+
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
 		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.WriteHeader(http.StatusOK)
 
 		params := r.pool.Get().(*Params)
 		params.Reset()
@@ -420,6 +496,20 @@ func (r *SteelRouter) registerSSEHandler(pattern string, handler interface{}, op
 
 		r.extractURLParams(req.URL.Path, req.Method, params)
 
+		stream := req.URL.Path
+		if info.StreamKey != nil {
+			stream = info.StreamKey(req)
+		}
+
+		// The stream span lasts until the client disconnects and records each event sent
+		spanCtx, span := StartSpan(req.Context(), "SSE "+RoutePattern(req), SpanKindProducer)
+		defer span.End()
+		span.SetAttribute("messaging.system", "sse")
+		span.SetAttribute("messaging.destination.name", stream)
+
+		ctx, cancel := context.WithCancel(spanCtx)
+		defer cancel()
+
 		clientID := generateClientID()
 		sseConn := &SSEConnection{
 			writer:   w,
@@ -428,15 +518,46 @@ func (r *SteelRouter) registerSSEHandler(pattern string, handler interface{}, op
 			request:  req,
 			clientID: clientID,
 			metadata: make(map[string]interface{}),
+			ctx:      ctx,
+			cancel:   cancel,
+			stream:   stream,
+			history:  info.History,
 		}
 
+		// Hold the connection lock while replaying so live events published meanwhile queue up behind the replay
+		sseConn.mu.Lock()
 		r.connectionManager.AddSSEConnection(clientID, sseConn)
-		defer r.connectionManager.RemoveSSEConnection(clientID)
+		err := sseConn.replayHistory()
+		if err == nil {
+			err = sseConn.flush()
+		}
+		sseConn.mu.Unlock()
+
+		if err != nil {
+			sseConn.Close()
+			span.RecordError(err)
+			span.SetStatus(SpanStatusError, err.Error())
+			log.Printf("SSE stream error: %v", err)
+			return
+		}
+
+		// The heartbeat must stop before the handler returns since the writer is invalid afterwards
+		var heartbeats sync.WaitGroup
+		if heartbeat > 0 {
+			heartbeats.Add(1)
+			go func() {
+				defer heartbeats.Done()
+				sseConn.heartbeat(heartbeat)
+			}()
+		}
 
 		r.handleSSEConnection(sseConn, handler, paramsType)
+
+		sseConn.Close()
+		heartbeats.Wait()
 	}
 
-	r.GET(pattern, httpHandler)
+	r.addRoute("GET", pattern, httpHandler).kind = endpointSSE
 }
 
 // handleSSEConnection handles an SSE connection by binding parameters, invoking the handler, and processing the result.
@@ -450,16 +571,28 @@ func (r *SteelRouter) handleSSEConnection(sseConn *SSEConnection, handler interf
 	params := reflect.New(paramsType).Interface()
 
 	// Bind parameters (similar to regular handlers)
+	span := SpanFromContext(sseConn.Context())
 	if err := r.bindParameters(&Context{
 		Request:  sseConn.request,
 		Response: sseConn.writer,
 		router:   r,
 		params:   sseConn.params,
 	}, params); err != nil {
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, err.Error())
 		log.Printf("SSE parameter binding error: %v", err)
 		return
 	}
 
+	// A panicking handler ends the stream, whose headers are already sent
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := r.reportPanic(sseConn.request, value)
+			span.RecordError(panicErr)
+			span.SetStatus(SpanStatusError, panicErr.Error())
+		}
+	}()
+
 	// Call handler
 	results := handlerValue.Call([]reflect.Value{
 		reflect.ValueOf(sseConn),
@@ -467,139 +600,153 @@ func (r *SteelRouter) handleSSEConnection(sseConn *SSEConnection, handler interf
 	})
 
 	if !results[0].IsNil() {
-		log.Printf("SSE handler error: %v", results[0].Interface().(error))
+		err := results[0].Interface().(error)
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, err.Error())
+		log.Printf("SSE handler error: %v", err)
 	}
 }
 
 // generateAsyncAPIForWS generates the AsyncAPI specification for a WebSocket route and updates the AsyncAPI spec.
 // It creates a channel with subscribe and publish operations based on the provided WSHandlerInfo.
 func (r *SteelRouter) generateAsyncAPIForWS(info *WSHandlerInfo) {
+	codec := info.Codec
+	if codec == nil {
+		codec = JSONCodec
+	}
+
+	message := AsyncAPIMessage{
+		ContentType: codec.ContentType(),
+		Payload:     r.wsPayloadSchema(codec, info.MessageType),
+		Headers:     r.asyncHeadersSchema(info.HeadersType),
+		Examples:    info.Examples,
+	}
+	response := AsyncAPIMessage{
+		ContentType: codec.ContentType(),
+		Payload:     r.wsPayloadSchema(codec, info.ResponseType),
+	}
+
 	channel := AsyncAPIChannel{
 		Description: info.Description,
 		Subscribe: &AsyncAPIOperation{
+			OperationID: info.OperationID,
 			Summary:     info.Summary,
 			Description: info.Description,
 			Tags:        convertToAsyncAPITags(info.Tags),
-			Message: AsyncAPIMessage{
-				ContentType: "application/json",
-				Payload:     r.typeToAsyncAPISchema(info.MessageType),
-			},
+			Message:     message,
+			Security:    info.SecurityRequirements,
+			Deprecated:  info.Deprecated,
 		},
 		Publish: &AsyncAPIOperation{
+			OperationID: replyOperationID(info.OperationID),
 			Summary:     info.Summary + " Response",
 			Description: info.Description,
 			Tags:        convertToAsyncAPITags(info.Tags),
-			Message: AsyncAPIMessage{
-				ContentType: "application/json",
-				Payload:     r.typeToAsyncAPISchema(info.ResponseType),
-			},
+			Message:     response,
+			Security:    info.SecurityRequirements,
+			Deprecated:  info.Deprecated,
 		},
+		Bindings: r.wsChannelBindings(info.ParamsType),
 	}
 
 	r.asyncAPISpec.Channels[info.Path] = channel
+	r.generateAsyncAPI3ForWS(info, message, response)
+}
+
+// wsPayloadSchema returns the payload schema for a WebSocket message type.
+// Raw frames are documented as binary strings since they carry no structured envelope.
+func (r *SteelRouter) wsPayloadSchema(codec WSCodec, t reflect.Type) AsyncAPISchema {
+	if isRawCodec(codec) {
+		return AsyncAPISchema{Type: "string", Format: "binary"}
+	}
+	return r.typeToAsyncAPISchema(t)
 }
 
 // generateAsyncAPIForSSE generates an AsyncAPI channel configuration for an SSE endpoint based on the provided handler info.
+// Declared events are each documented as their own message, named after the event.
 func (r *SteelRouter) generateAsyncAPIForSSE(info *SSEHandlerInfo) {
+	headers := r.asyncHeadersSchema(info.HeadersType)
+	message := AsyncAPIMessage{
+		ContentType: "text/event-stream",
+		Payload:     r.typeToAsyncAPISchema(reflect.TypeOf(SSEMessage{})),
+		Headers:     headers,
+		Examples:    info.Examples,
+	}
+	messages := []AsyncAPIMessage{message}
+
+	if len(info.Events) > 0 {
+		message = AsyncAPIMessage{
+			ContentType: "text/event-stream",
+		}
+		for _, event := range info.Events {
+			eventMessage := r.sseEventMessage(event)
+			eventMessage.Headers = headers
+			for _, example := range info.Examples {
+				if example.Name == event.Name {
+					eventMessage.Examples = append(eventMessage.Examples, example)
+				}
+			}
+			r.asyncAPISpec.Components.Messages[event.Name] = eventMessage
+			message.OneOf = append(message.OneOf, eventMessage)
+		}
+		messages = message.OneOf
+	}
+
 	channel := AsyncAPIChannel{
 		Description: info.Description,
 		Subscribe: &AsyncAPIOperation{
+			OperationID: info.OperationID,
 			Summary:     info.Summary,
 			Description: info.Description,
 			Tags:        convertToAsyncAPITags(info.Tags),
-			Message: AsyncAPIMessage{
-				ContentType: "text/event-stream",
-				Payload:     r.typeToAsyncAPISchema(reflect.TypeOf(SSEMessage{})),
-			},
+			Message:     message,
+			Security:    info.SecurityRequirements,
+			Bindings:    r.sseOperationBindings(info.ParamsType),
+			Deprecated:  info.Deprecated,
 		},
 	}
 
 	r.asyncAPISpec.Channels[info.Path] = channel
+	r.generateAsyncAPI3ForSSE(info, messages)
 }
 
-// typeToAsyncAPISchema converts a given Go reflect.Type to its corresponding AsyncAPISchema representation.
-func (r *SteelRouter) typeToAsyncAPISchema(t reflect.Type) AsyncAPISchema {
-	if t.Kind() == reflect.Ptr {
-		t = t.Elem()
+// sseEventMessage documents a typed SSE event. String payloads are sent as plain text lines,
+// everything else as JSON.
+func (r *SteelRouter) sseEventMessage(event SSEEventInfo) AsyncAPIMessage {
+	contentType := "application/json"
+	if event.DataType.Kind() == reflect.String {
+		contentType = "text/plain"
 	}
 
-	switch t.Kind() {
-	case reflect.String:
-		return AsyncAPISchema{Type: "string"}
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return AsyncAPISchema{Type: "integer"}
-	case reflect.Float32, reflect.Float64:
-		return AsyncAPISchema{Type: "number"}
-	case reflect.Bool:
-		return AsyncAPISchema{Type: "boolean"}
-	case reflect.Slice, reflect.Array:
-		return AsyncAPISchema{
-			Type:  "array",
-			Items: &AsyncAPISchema{Type: "string"}, // Simplified
-		}
-	case reflect.Struct:
-		if t.Name() != "" {
-			schemaName := t.Name()
-			if _, exists := r.asyncAPISpec.Components.Schemas[schemaName]; !exists {
-				r.asyncAPISpec.Components.Schemas[schemaName] = r.generateAsyncAPIStructSchema(t)
-			}
-			return AsyncAPISchema{Ref: "#/components/schemas/" + schemaName}
-		}
-		return r.generateAsyncAPIStructSchema(t)
-	default:
-		return AsyncAPISchema{Type: "object"}
+	return AsyncAPIMessage{
+		Name:        event.Name,
+		Title:       event.Name,
+		ContentType: contentType,
+		Payload:     r.typeToAsyncAPISchema(event.DataType),
 	}
 }
 
-// generateAsyncAPIStructSchema generates an AsyncAPISchema for a given Go struct type using reflection.
-// It constructs schema properties, required fields, and handles JSON tags, including omitempty handling.
-// Fields marked as unexported or explicitly omitted via JSON tags are excluded from the schema.
-// The method processes field metadata such as type, description, and JSON serialization behavior.
-func (r *SteelRouter) generateAsyncAPIStructSchema(t reflect.Type) AsyncAPISchema {
-	schema := AsyncAPISchema{
-		Type:       "object",
-		Properties: make(map[string]AsyncAPISchema),
-		Required:   []string{},
-	}
-
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-
-		jsonTag := field.Tag.Get("json")
-		if jsonTag == "" || jsonTag == "-" {
-			continue
-		}
-
-		parts := strings.Split(jsonTag, ",")
-		fieldName := parts[0]
-		if fieldName == "" {
-			fieldName = field.Name
-		}
-
-		fieldSchema := r.typeToAsyncAPISchema(field.Type)
-		if desc := field.Tag.Get("description"); desc != "" {
-			fieldSchema.Description = desc
-		}
-
-		schema.Properties[fieldName] = fieldSchema
-
-		omitEmpty := false
-		for _, part := range parts[1:] {
-			if part == "omitempty" {
-				omitEmpty = true
-				break
-			}
-		}
+// typeToAsyncAPISchema converts a given Go reflect.Type to its corresponding AsyncAPISchema representation.
+// Named structs are registered in the AsyncAPI components and referenced, using the same engine as the OpenAPI generator.
+func (r *SteelRouter) typeToAsyncAPISchema(t reflect.Type) AsyncAPISchema {
+	return r.typeToComponentSchema(t, r.asyncAPISpec.Components.Schemas)
+}
 
-		if !omitEmpty && field.Type.Kind() != reflect.Ptr {
-			schema.Required = append(schema.Required, fieldName)
-		}
+// asyncHeadersSchema documents the headers declared with WithAsyncHeaders, or returns nil when none are declared.
+func (r *SteelRouter) asyncHeadersSchema(t reflect.Type) *AsyncAPISchema {
+	if t == nil {
+		return nil
 	}
+	schema := r.typeToAsyncAPISchema(t)
+	return &schema
+}
 
-	return schema
+// replyOperationID derives the operation ID of the reply to an operation, keeping it empty when no ID is set.
+func replyOperationID(operationID string) string {
+	if operationID == "" {
+		return ""
+	}
+	return operationID + "Reply"
 }
 
 // convertToAsyncAPITags converts a slice of strings into a slice of AsyncAPITag structs with names matching the input strings.
@@ -614,15 +761,11 @@ func convertToAsyncAPITags(tags []string) []AsyncAPITag {
 // EnableAsyncAPI with embedded Studio support
 func (r *SteelRouter) EnableAsyncAPI() {
 	r.initAsyncAPI()
+	paths := r.specPaths()
 
-	// JSON endpoint for the spec
-	r.GET("/asyncapi", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		json.NewEncoder(w).Encode(r.asyncAPISpec)
-	})
+	// JSON and YAML endpoints for the spec
+	r.GET(paths.AsyncAPIJSON, r.specHandler(specAsyncAPI, SpecFormatJSON))
+	r.GET(paths.AsyncAPIYAML, r.specHandler(specAsyncAPI, SpecFormatYAML))
 
 	// 	// Embedded AsyncAPI Studio
 	// 	r.GET("/asyncapi/studio", func(w http.ResponseWriter, req *http.Request) {
@@ -1298,6 +1441,7 @@ func (r *SteelRouter) EnableAsyncAPI() {
                 <h3>📋 Raw Specification</h3>
                 <p>View or download the raw AsyncAPI specification in JSON format for integration with other tools.</p>
                 <a href="/asyncapi" class="btn btn-secondary" target="_blank">View JSON</a>
+                <a href="/asyncapi.yaml" class="btn btn-secondary" target="_blank">View YAML</a>
             </div>
             
             <div class="option">
@@ -1335,8 +1479,9 @@ func (r *SteelRouter) EnableAsyncAPI() {
     </script>
 </body>
 </html>`
+		html = string(withSpecURL(html, DefaultAsyncAPIYAMLPath, paths.AsyncAPIYAML))
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultAsyncAPIJSONPath, paths.AsyncAPIJSON))
 	})
 
 	// Simple docs endpoint (keeping the existing one with minor updates)
@@ -1344,11 +1489,12 @@ func (r *SteelRouter) EnableAsyncAPI() {
 		w.Header().Set("Content-Type", "text/html")
 
 		// Get the spec
-		specJSON, err := json.MarshalIndent(r.asyncAPISpec, "", "  ")
+		doc, err := r.specDocument(specAsyncAPI)
 		if err != nil {
 			http.Error(w, "Failed to marshal AsyncAPI spec", http.StatusInternalServerError)
 			return
 		}
+		specJSON := doc.bodies[SpecFormatJSON]
 
 		html := fmt.Sprintf(`<!DOCTYPE html>
 <html>
@@ -1367,7 +1513,8 @@ func (r *SteelRouter) EnableAsyncAPI() {
     <div class="header">
         <h1>AsyncAPI Specification</h1>
         <div class="links">
-            <a href="/asyncapi">JSON Format</a>
+            <a href="%s">JSON Format</a>
+            <a href="%s">YAML Format</a>
             <a href="/asyncapi/docs">Documentation Hub</a>
             <a href="/asyncapi/studio">Embedded Studio</a>
             <a href="https://studio.asyncapi.com/?url=%s" target="_blank">External Studio</a>
@@ -1376,7 +1523,9 @@ func (r *SteelRouter) EnableAsyncAPI() {
     <pre><code>%s</code></pre>
 </body>
 </html>`,
-			req.Host+"/asyncapi",
+			paths.AsyncAPIJSON,
+			paths.AsyncAPIYAML,
+			req.Host+paths.AsyncAPIJSON,
 			string(specJSON))
 
 		w.Write([]byte(html))
@@ -1436,6 +1585,85 @@ func (o asyncTagsOption) ApplyToSSE(info *SSEHandlerInfo) {
 	info.Tags = o.tags
 }
 
+// asyncCodecOption is a type that implements AsyncHandlerOption to select the codec of a WebSocket handler.
+type asyncCodecOption struct {
+	codec WSCodec
+}
+
+// ApplyToWS sets the codec used to encode and decode messages for the WebSocket handler.
+func (o asyncCodecOption) ApplyToWS(info *WSHandlerInfo) {
+	info.Codec = o.codec
+}
+
+// ApplyToSSE is a no-op since SSE streams are always text encoded.
+func (o asyncCodecOption) ApplyToSSE(info *SSEHandlerInfo) {}
+
+// asyncRequestTimeoutOption is a type that implements AsyncHandlerOption to bound server-initiated WebSocket requests.
+type asyncRequestTimeoutOption struct {
+	timeout time.Duration
+}
+
+// ApplyToWS sets the timeout applied to WSConnection.SendRequest calls without a context deadline.
+func (o asyncRequestTimeoutOption) ApplyToWS(info *WSHandlerInfo) {
+	info.RequestTimeout = o.timeout
+}
+
+// ApplyToSSE is a no-op since SSE connections cannot receive replies.
+func (o asyncRequestTimeoutOption) ApplyToSSE(info *SSEHandlerInfo) {}
+
+// asyncAcknowledgeOption is a type that implements AsyncHandlerOption to acknowledge client WebSocket requests.
+type asyncAcknowledgeOption struct{}
+
+// ApplyToWS enables "ack" messages for client messages carrying an ID.
+func (o asyncAcknowledgeOption) ApplyToWS(info *WSHandlerInfo) {
+	info.Acknowledge = true
+}
+
+// ApplyToSSE is a no-op since SSE connections cannot send requests.
+func (o asyncAcknowledgeOption) ApplyToSSE(info *SSEHandlerInfo) {}
+
+// asyncSSEHistoryOption is a type that implements AsyncHandlerOption to enable event replay on SSE endpoints.
+type asyncSSEHistoryOption struct {
+	store SSEHistoryStore
+}
+
+// ApplyToWS is a no-op since WebSocket endpoints have no event history.
+func (o asyncSSEHistoryOption) ApplyToWS(info *WSHandlerInfo) {}
+
+// ApplyToSSE sets the history store used for Last-Event-ID replay.
+func (o asyncSSEHistoryOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.History = o.store
+}
+
+// asyncSSEStreamOption is a type that implements AsyncHandlerOption to select the history stream of an SSE request.
+type asyncSSEStreamOption struct {
+	key func(r *http.Request) string
+}
+
+// ApplyToWS is a no-op since WebSocket endpoints have no event history.
+func (o asyncSSEStreamOption) ApplyToWS(info *WSHandlerInfo) {}
+
+// ApplyToSSE sets the function selecting the stream of each connection.
+func (o asyncSSEStreamOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.StreamKey = o.key
+}
+
+// asyncSSEHeartbeatOption is a type that implements AsyncHandlerOption to configure SSE heartbeats.
+type asyncSSEHeartbeatOption struct {
+	interval time.Duration
+}
+
+// ApplyToWS is a no-op since WebSocket connections use protocol-level pings.
+func (o asyncSSEHeartbeatOption) ApplyToWS(info *WSHandlerInfo) {}
+
+// ApplyToSSE sets the heartbeat interval, disabling heartbeats for non-positive intervals.
+func (o asyncSSEHeartbeatOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.HeartbeatInterval = o.interval
+	if o.interval <= 0 {
+		info.HeartbeatInterval = -1
+	}
+}
+
 // WithAsyncSummary sets a summary description for an asynchronous handler and returns an AsyncHandlerOption.
 func WithAsyncSummary(summary string) AsyncHandlerOption {
 	return asyncSummaryOption{summary: summary}
@@ -1451,6 +1679,40 @@ func WithAsyncTags(tags ...string) AsyncHandlerOption {
 	return asyncTagsOption{tags: tags}
 }
 
+// WithWSCodec selects the codec (JSONCodec, MessagePackCodec, CBORCodec, RawCodec or a custom WSCodec) for a WebSocket handler.
+func WithWSCodec(codec WSCodec) AsyncHandlerOption {
+	return asyncCodecOption{codec: codec}
+}
+
+// WithWSRequestTimeout sets the default timeout for server-initiated requests made with WSConnection.SendRequest.
+func WithWSRequestTimeout(timeout time.Duration) AsyncHandlerOption {
+	return asyncRequestTimeoutOption{timeout: timeout}
+}
+
+// WithWSAcknowledgements makes the server reply with an "ack" message, carrying the same ID,
+// as soon as a client message with an ID is received and before its handler runs.
+func WithWSAcknowledgements() AsyncHandlerOption {
+	return asyncAcknowledgeOption{}
+}
+
+// WithSSEHistory records events sent on an SSE endpoint in store and replays the events published after
+// the client's Last-Event-ID when it reconnects, before live delivery resumes.
+func WithSSEHistory(store SSEHistoryStore) AsyncHandlerOption {
+	return asyncSSEHistoryOption{store: store}
+}
+
+// WithSSEStream selects the history stream of each SSE connection. By default the request path is used,
+// so every resolved URL (for example /events/42) is its own stream.
+func WithSSEStream(key func(r *http.Request) string) AsyncHandlerOption {
+	return asyncSSEStreamOption{key: key}
+}
+
+// WithSSEHeartbeat sets the interval between ": ping" comments sent on idle SSE streams.
+// An interval of zero or less disables heartbeats.
+func WithSSEHeartbeat(interval time.Duration) AsyncHandlerOption {
+	return asyncSSEHeartbeatOption{interval: interval}
+}
+
 // generateClientID generates a unique client identifier based on the current timestamp in nanoseconds.
 func generateClientID() string {
 	b := make([]byte, 8)
@@ -0,0 +1,346 @@
+package steel
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Binding versions emitted for protocol bindings.
+const (
+	asyncAPIWSBindingVersion   = "0.1.0"
+	asyncAPIHTTPBindingVersion = "0.3.0"
+)
+
+// AsyncAPISecurityScheme represents a security scheme in an AsyncAPI document.
+// Schemes are derived from the OpenAPI security schemes registered on the router.
+type AsyncAPISecurityScheme struct {
+	Type             string      `json:"type"`
+	Description      string      `json:"description,omitempty"`
+	Name             string      `json:"name,omitempty"`
+	In               string      `json:"in,omitempty"`
+	Scheme           string      `json:"scheme,omitempty"`
+	BearerFormat     string      `json:"bearerFormat,omitempty"`
+	Flows            interface{} `json:"flows,omitempty"`
+	OpenIDConnectURL string      `json:"openIdConnectUrl,omitempty"`
+}
+
+// asyncAPIOAuth2Flow is an OAuth2 flow in AsyncAPI 3.0, where scopes are named availableScopes.
+type asyncAPIOAuth2Flow struct {
+	AuthorizationURL string            `json:"authorizationUrl,omitempty"`
+	TokenURL         string            `json:"tokenUrl,omitempty"`
+	RefreshURL       string            `json:"refreshUrl,omitempty"`
+	AvailableScopes  map[string]string `json:"availableScopes"`
+}
+
+// toAsyncAPISecurityScheme converts an OpenAPI security scheme to its AsyncAPI equivalent for the given document version.
+// API keys sent over HTTP become httpApiKey and mutual TLS becomes X509.
+func toAsyncAPISecurityScheme(scheme OpenAPISecurityScheme, version string) AsyncAPISecurityScheme {
+	converted := AsyncAPISecurityScheme{
+		Type:             scheme.Type,
+		Description:      scheme.Description,
+		Scheme:           scheme.Scheme,
+		BearerFormat:     scheme.BearerFormat,
+		OpenIDConnectURL: scheme.OpenIDConnectURL,
+	}
+
+	switch scheme.Type {
+	case SecurityTypeAPIKey:
+		converted.Type = "httpApiKey"
+		converted.Name = scheme.Name
+		converted.In = scheme.In
+	case "mutualTLS":
+		converted.Type = "X509"
+	case SecurityTypeOAuth2:
+		if scheme.Flows == nil {
+			break
+		}
+		if version != AsyncAPIVersion3 {
+			converted.Flows = scheme.Flows
+			break
+		}
+
+		flows := make(map[string]asyncAPIOAuth2Flow)
+		for name, flow := range map[string]*OpenAPIOAuth2Flow{
+			OAuth2FlowImplicit:          scheme.Flows.Implicit,
+			OAuth2FlowPassword:          scheme.Flows.Password,
+			OAuth2FlowClientCredentials: scheme.Flows.ClientCredentials,
+			OAuth2FlowAuthorizationCode: scheme.Flows.AuthorizationCode,
+		} {
+			if flow != nil {
+				flows[name] = asyncAPIOAuth2Flow{
+					AuthorizationURL: flow.AuthorizationURL,
+					TokenURL:         flow.TokenURL,
+					RefreshURL:       flow.RefreshURL,
+					AvailableScopes:  flow.Scopes,
+				}
+			}
+		}
+		converted.Flows = flows
+	}
+
+	return converted
+}
+
+// asyncAPISecurityRefs flattens security requirements into references to component security schemes,
+// as AsyncAPI 3.0 lists schemes instead of requirement objects.
+func asyncAPISecurityRefs(requirements []OpenAPISecurityRequirement) []AsyncAPIReference {
+	seen := make(map[string]bool)
+	var names []string
+	for _, requirement := range requirements {
+		for name := range requirement {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	refs := make([]AsyncAPIReference, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, AsyncAPIReference{Ref: "#/components/securitySchemes/" + name})
+	}
+	return refs
+}
+
+// paramsBindingSchemas builds the query and header object schemas of a bound params type from its query and header tags.
+// Either schema is nil when the type declares no such parameters.
+func (r *SteelRouter) paramsBindingSchemas(t reflect.Type) (query, headers *AsyncAPISchema) {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	add := func(target **AsyncAPISchema, name string, field reflect.StructField) {
+		if *target == nil {
+			*target = &AsyncAPISchema{
+				Type:       "object",
+				Properties: make(map[string]AsyncAPISchema),
+			}
+		}
+
+		schema := r.typeToAsyncAPISchema(field.Type)
+		if desc := field.Tag.Get("description"); desc != "" {
+			schema.Description = desc
+		}
+		(*target).Properties[name] = schema
+
+		if field.Tag.Get("required") == "true" {
+			(*target).Required = append((*target).Required, name)
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if name := field.Tag.Get("query"); name != "" {
+			add(&query, name, field)
+		}
+		if name := field.Tag.Get("header"); name != "" {
+			add(&headers, name, field)
+		}
+	}
+
+	return query, headers
+}
+
+// wsChannelBindings returns the ws channel binding describing the upgrade request of a WebSocket endpoint.
+func (r *SteelRouter) wsChannelBindings(paramsType reflect.Type) map[string]interface{} {
+	binding := map[string]interface{}{
+		"method":         http.MethodGet,
+		"bindingVersion": asyncAPIWSBindingVersion,
+	}
+
+	query, headers := r.paramsBindingSchemas(paramsType)
+	if query != nil {
+		binding["query"] = query
+	}
+	if headers != nil {
+		binding["headers"] = headers
+	}
+
+	return map[string]interface{}{"ws": binding}
+}
+
+// sseOperationBindings returns the http operation binding describing the GET request that opens an SSE stream.
+func (r *SteelRouter) sseOperationBindings(paramsType reflect.Type) map[string]interface{} {
+	binding := map[string]interface{}{
+		"method":         http.MethodGet,
+		"bindingVersion": asyncAPIHTTPBindingVersion,
+	}
+
+	if query, _ := r.paramsBindingSchemas(paramsType); query != nil {
+		binding["query"] = query
+	}
+
+	return map[string]interface{}{"http": binding}
+}
+
+// asyncAPIServerCandidate is a server derived from the router configuration before it is named.
+type asyncAPIServerCandidate struct {
+	url         string
+	protocol    string
+	description string
+}
+
+// defaultAsyncAPIServers derives servers from the OpenAPI servers, or localhost:8080 when none are configured.
+// WebSocket endpoints produce ws/wss servers and SSE endpoints produce http/https servers.
+func (r *SteelRouter) defaultAsyncAPIServers() map[string]AsyncAPIServer {
+	bases := []OpenAPIServer{{URL: "http://localhost:8080"}}
+	if len(r.openAPISpec.Servers) > 0 {
+		bases = r.openAPISpec.Servers
+	}
+
+	var candidates []asyncAPIServerCandidate
+	for _, base := range bases {
+		scheme, rest, ok := strings.Cut(base.URL, "://")
+		if !ok {
+			scheme, rest = "http", strings.TrimPrefix(base.URL, "//")
+		}
+		secure := scheme == "https"
+
+		if len(r.wsHandlers) > 0 {
+			protocol := "ws"
+			if secure {
+				protocol = "wss"
+			}
+			candidates = append(candidates, asyncAPIServerCandidate{
+				url:         protocol + "://" + rest,
+				protocol:    protocol,
+				description: strings.TrimSpace(base.Description + " WebSocket server"),
+			})
+		}
+
+		if len(r.sseHandlers) > 0 {
+			protocol := "http"
+			if secure {
+				protocol = "https"
+			}
+			candidates = append(candidates, asyncAPIServerCandidate{
+				url:         protocol + "://" + rest,
+				protocol:    protocol,
+				description: strings.TrimSpace(base.Description + " Server-Sent Events server"),
+			})
+		}
+	}
+
+	servers := make(map[string]AsyncAPIServer)
+	counts := make(map[string]int)
+	for _, candidate := range candidates {
+		counts[candidate.protocol]++
+		name := candidate.protocol
+		if counts[candidate.protocol] > 1 {
+			name = fmt.Sprintf("%s-%d", candidate.protocol, counts[candidate.protocol])
+		}
+		servers[name] = AsyncAPIServer{
+			URL:         candidate.url,
+			Protocol:    candidate.protocol,
+			Description: candidate.description,
+		}
+	}
+
+	return servers
+}
+
+// finalizeAsyncAPI fills the parts of the AsyncAPI documents that depend on router-wide configuration,
+// which may change after async handlers are registered: servers, security schemes and global security.
+// Document-level settings of the 2.x document, such as tags, are mirrored into the 3.0 document.
+func (r *SteelRouter) finalizeAsyncAPI() {
+	if !r.asyncAPICustomServers {
+		r.asyncAPISpec.Servers = r.defaultAsyncAPIServers()
+	}
+
+	for name, server := range r.asyncAPISpec.Servers {
+		server.Security = r.globalSecurity
+		r.asyncAPISpec.Servers[name] = server
+	}
+
+	servers := make(map[string]AsyncAPI3Server)
+	for name, server := range r.asyncAPISpec.Servers {
+		protocol, host, pathname := splitAsyncAPIServerURL(server.URL, server.Protocol)
+		servers[name] = AsyncAPI3Server{
+			Host:        host,
+			Protocol:    protocol,
+			Pathname:    pathname,
+			Description: server.Description,
+			Security:    asyncAPISecurityRefs(r.globalSecurity),
+		}
+	}
+	r.asyncAPI3Spec.Servers = servers
+	r.asyncAPI3Spec.Info = r.asyncAPISpec.Info
+	r.asyncAPI3Spec.Info.Tags = r.asyncAPISpec.Tags
+	r.asyncAPI3Spec.Info.ExternalDocs = r.asyncAPISpec.ExternalDocs
+	r.asyncAPI3Spec.DefaultContentType = r.asyncAPISpec.DefaultContentType
+
+	if len(r.openAPISpec.Components.SecuritySchemes) > 0 {
+		schemes := make(map[string]AsyncAPISecurityScheme)
+		schemes3 := make(map[string]AsyncAPISecurityScheme)
+		for name, scheme := range r.openAPISpec.Components.SecuritySchemes {
+			schemes[name] = toAsyncAPISecurityScheme(scheme, AsyncAPIVersion2)
+			schemes3[name] = toAsyncAPISecurityScheme(scheme, AsyncAPIVersion3)
+		}
+		r.asyncAPISpec.Components.SecuritySchemes = schemes
+		r.asyncAPI3Spec.Components.SecuritySchemes = schemes3
+	}
+}
+
+// asyncSecurityOption is a type that implements AsyncHandlerOption to document security requirements of async endpoints.
+type asyncSecurityOption struct {
+	requirements []OpenAPISecurityRequirement
+}
+
+// ApplyToWS sets the security requirements of the WebSocket endpoint.
+func (o asyncSecurityOption) ApplyToWS(info *WSHandlerInfo) {
+	info.SecurityRequirements = append(info.SecurityRequirements, o.requirements...)
+}
+
+// ApplyToSSE sets the security requirements of the SSE endpoint.
+func (o asyncSecurityOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.SecurityRequirements = append(info.SecurityRequirements, o.requirements...)
+}
+
+// WithAsyncSecurity documents the security requirements of an async endpoint on its AsyncAPI operations.
+// The referenced schemes are the ones registered for OpenAPI, for example with RegisterSecurityScheme.
+func WithAsyncSecurity(requirements ...OpenAPISecurityRequirement) AsyncHandlerOption {
+	return asyncSecurityOption{requirements: requirements}
+}
+
+// asyncWSParamsOption is a type that implements AsyncHandlerOption to declare the params bound on WebSocket upgrade.
+type asyncWSParamsOption struct {
+	paramsType reflect.Type
+}
+
+// ApplyToWS sets the params type documented in the ws binding.
+func (o asyncWSParamsOption) ApplyToWS(info *WSHandlerInfo) {
+	info.ParamsType = o.paramsType
+}
+
+// ApplyToSSE is a no-op since SSE handlers declare their params type in the handler signature.
+func (o asyncWSParamsOption) ApplyToSSE(info *SSEHandlerInfo) {}
+
+// WithWSParams declares the query and header parameters accepted by a WebSocket upgrade request.
+// They are documented in the ws binding and can be read with WSConnection.BindParams.
+func WithWSParams[T any]() AsyncHandlerOption {
+	return asyncWSParamsOption{paramsType: reflect.TypeOf((*T)(nil)).Elem()}
+}
+
+// BindParams binds the path, query and header parameters of the upgrade request into v,
+// using the same tags as regular handlers. Path parameters are those matched by the router.
+func (ws *WSConnection) BindParams(v interface{}) error {
+	if ws.router == nil || ws.request == nil {
+		return fmt.Errorf("websocket connection has no request")
+	}
+
+	return ws.router.bindParameters(&Context{
+		Request: ws.request,
+		router:  ws.router,
+		params:  ParamsFromContext(ws.request.Context()),
+	}, v)
+}
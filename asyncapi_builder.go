@@ -0,0 +1,281 @@
+package steel
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AsyncAPIBuilder provides a fluent interface for configuring AsyncAPI specifications
+type AsyncAPIBuilder struct {
+	router             *SteelRouter
+	info               *AsyncAPIInfo
+	servers            map[string]AsyncAPIServer
+	tags               []AsyncAPITag
+	externalDocs       *AsyncAPIExternalDocs
+	defaultContentType string
+}
+
+// AsyncAPI returns a new AsyncAPIBuilder for fluent configuration
+func (r *SteelRouter) AsyncAPI() *AsyncAPIBuilder {
+	return &AsyncAPIBuilder{
+		router:  r,
+		info:    &AsyncAPIInfo{},
+		servers: make(map[string]AsyncAPIServer),
+		tags:    make([]AsyncAPITag, 0),
+	}
+}
+
+// =============================================================================
+// Info Configuration
+// =============================================================================
+
+// SetTitle sets the API title
+func (b *AsyncAPIBuilder) SetTitle(title string) *AsyncAPIBuilder {
+	b.info.Title = title
+	return b
+}
+
+// SetDescription sets the API description
+func (b *AsyncAPIBuilder) SetDescription(description string) *AsyncAPIBuilder {
+	b.info.Description = description
+	return b
+}
+
+// SetVersion sets the API version
+func (b *AsyncAPIBuilder) SetVersion(version string) *AsyncAPIBuilder {
+	b.info.Version = version
+	return b
+}
+
+// SetDefaultContentType sets the content type of messages that do not declare one
+func (b *AsyncAPIBuilder) SetDefaultContentType(contentType string) *AsyncAPIBuilder {
+	b.defaultContentType = contentType
+	return b
+}
+
+// =============================================================================
+// Server Configuration
+// =============================================================================
+
+// AddServer adds a named server to the API specification.
+// Once a server is added, servers are no longer derived from the OpenAPI servers.
+func (b *AsyncAPIBuilder) AddServer(name, url, protocol, description string) *AsyncAPIBuilder {
+	b.servers[name] = AsyncAPIServer{
+		URL:         url,
+		Protocol:    protocol,
+		Description: description,
+	}
+	return b
+}
+
+// AddWebSocketServer adds a WebSocket server, using wss for secure URLs
+func (b *AsyncAPIBuilder) AddWebSocketServer(name, url, description string) *AsyncAPIBuilder {
+	protocol, _, _ := splitAsyncAPIServerURL(url, "")
+	if protocol != "wss" {
+		protocol = "ws"
+	}
+	return b.AddServer(name, url, protocol, description)
+}
+
+// AddDevelopmentServer adds a common development WebSocket server
+func (b *AsyncAPIBuilder) AddDevelopmentServer(port int) *AsyncAPIBuilder {
+	return b.AddServer("development", fmt.Sprintf("ws://localhost:%d", port), "ws", "Development server")
+}
+
+// AddProductionServer adds a common production WebSocket server
+func (b *AsyncAPIBuilder) AddProductionServer(domain string) *AsyncAPIBuilder {
+	return b.AddServer("production", fmt.Sprintf("wss://%s", domain), "wss", "Production server")
+}
+
+// =============================================================================
+// Documentation Configuration
+// =============================================================================
+
+// AddTag adds a tag to the API specification
+func (b *AsyncAPIBuilder) AddTag(name, description string) *AsyncAPIBuilder {
+	b.tags = append(b.tags, AsyncAPITag{
+		Name:        name,
+		Description: description,
+	})
+	return b
+}
+
+// AddTagWithDocs adds a tag with external documentation
+func (b *AsyncAPIBuilder) AddTagWithDocs(name, description, docsURL, docsDescription string) *AsyncAPIBuilder {
+	tag := AsyncAPITag{
+		Name:        name,
+		Description: description,
+	}
+	if docsURL != "" {
+		tag.ExternalDocs = &AsyncAPIExternalDocs{
+			URL:         docsURL,
+			Description: docsDescription,
+		}
+	}
+	b.tags = append(b.tags, tag)
+	return b
+}
+
+// SetExternalDocs sets external documentation for the entire API
+func (b *AsyncAPIBuilder) SetExternalDocs(url, description string) *AsyncAPIBuilder {
+	b.externalDocs = &AsyncAPIExternalDocs{
+		URL:         url,
+		Description: description,
+	}
+	return b
+}
+
+// =============================================================================
+// Build Method
+// =============================================================================
+
+// Build applies all the configuration to the router and enables AsyncAPI
+func (b *AsyncAPIBuilder) Build() *SteelRouter {
+	b.router.updateSpec(func() {
+		b.router.initAsyncAPI()
+		spec := b.router.asyncAPISpec
+
+		// Merge with existing info, preserving any existing values for unset fields
+		if b.info.Title != "" {
+			spec.Info.Title = b.info.Title
+		}
+		if b.info.Version != "" {
+			spec.Info.Version = b.info.Version
+		}
+		if b.info.Description != "" {
+			spec.Info.Description = b.info.Description
+		}
+
+		// Apply servers, replacing the ones derived from the OpenAPI servers
+		if len(b.servers) > 0 {
+			spec.Servers = make(map[string]AsyncAPIServer, len(b.servers))
+			for name, server := range b.servers {
+				spec.Servers[name] = server
+			}
+			b.router.asyncAPICustomServers = true
+		}
+
+		if len(b.tags) > 0 {
+			spec.Tags = b.tags
+		}
+
+		if b.externalDocs != nil {
+			spec.ExternalDocs = b.externalDocs
+		}
+
+		if b.defaultContentType != "" {
+			spec.DefaultContentType = b.defaultContentType
+		}
+	})
+
+	b.router.EnableAsyncAPI()
+
+	return b.router
+}
+
+// Validate checks if the current configuration is valid
+func (b *AsyncAPIBuilder) Validate() error {
+	if b.info.Title == "" {
+		return fmt.Errorf("API title is required")
+	}
+	if b.info.Version == "" {
+		return fmt.Errorf("API version is required")
+	}
+
+	for name, server := range b.servers {
+		if server.URL == "" || server.Protocol == "" {
+			return fmt.Errorf("server %s requires a URL and protocol", name)
+		}
+	}
+
+	return nil
+}
+
+// =============================================================================
+// Handler Options
+// =============================================================================
+
+// asyncOperationIDOption is a type that implements AsyncHandlerOption to set the operation ID of async endpoints.
+type asyncOperationIDOption struct {
+	operationID string
+}
+
+// ApplyToWS sets the operation ID of the WebSocket endpoint.
+func (o asyncOperationIDOption) ApplyToWS(info *WSHandlerInfo) {
+	info.OperationID = o.operationID
+}
+
+// ApplyToSSE sets the operation ID of the SSE endpoint.
+func (o asyncOperationIDOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.OperationID = o.operationID
+}
+
+// asyncDeprecatedOption is a type that implements AsyncHandlerOption to mark async endpoints as deprecated.
+type asyncDeprecatedOption struct {
+	deprecated bool
+}
+
+// ApplyToWS sets the deprecation flag of the WebSocket endpoint.
+func (o asyncDeprecatedOption) ApplyToWS(info *WSHandlerInfo) {
+	info.Deprecated = o.deprecated
+}
+
+// ApplyToSSE sets the deprecation flag of the SSE endpoint.
+func (o asyncDeprecatedOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.Deprecated = o.deprecated
+}
+
+// asyncExamplesOption is a type that implements AsyncHandlerOption to document message examples.
+type asyncExamplesOption struct {
+	examples []AsyncAPIExample
+}
+
+// ApplyToWS adds the examples to the message received by the WebSocket endpoint.
+func (o asyncExamplesOption) ApplyToWS(info *WSHandlerInfo) {
+	info.Examples = append(info.Examples, o.examples...)
+}
+
+// ApplyToSSE adds the examples to the messages streamed by the SSE endpoint.
+func (o asyncExamplesOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.Examples = append(info.Examples, o.examples...)
+}
+
+// asyncHeadersOption is a type that implements AsyncHandlerOption to document message headers.
+type asyncHeadersOption struct {
+	headersType reflect.Type
+}
+
+// ApplyToWS sets the headers type of the message received by the WebSocket endpoint.
+func (o asyncHeadersOption) ApplyToWS(info *WSHandlerInfo) {
+	info.HeadersType = o.headersType
+}
+
+// ApplyToSSE sets the headers type of the messages streamed by the SSE endpoint.
+func (o asyncHeadersOption) ApplyToSSE(info *SSEHandlerInfo) {
+	info.HeadersType = o.headersType
+}
+
+// WithAsyncOperationID sets the operationId of an async endpoint. In AsyncAPI 3.0 documents it is also the operation key.
+func WithAsyncOperationID(operationID string) AsyncHandlerOption {
+	return asyncOperationIDOption{operationID: operationID}
+}
+
+// WithAsyncDeprecated marks the operations of an async endpoint as deprecated with the x-deprecated extension.
+func WithAsyncDeprecated(deprecated bool) AsyncHandlerOption {
+	return asyncDeprecatedOption{deprecated: deprecated}
+}
+
+// WithAsyncExamples documents examples of the message an async endpoint receives (WebSocket) or streams (SSE).
+func WithAsyncExamples(examples ...AsyncAPIExample) AsyncHandlerOption {
+	return asyncExamplesOption{examples: examples}
+}
+
+// WithAsyncExample documents a single named message example with its payload.
+func WithAsyncExample(name, summary string, payload interface{}) AsyncHandlerOption {
+	return WithAsyncExamples(AsyncAPIExample{Name: name, Summary: summary, Payload: payload})
+}
+
+// WithAsyncHeaders documents the message headers of an async endpoint using the fields of T.
+func WithAsyncHeaders[T any]() AsyncHandlerOption {
+	return asyncHeadersOption{headersType: reflect.TypeOf((*T)(nil)).Elem()}
+}
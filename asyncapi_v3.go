@@ -0,0 +1,347 @@
+package steel
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Supported AsyncAPI document versions.
+const (
+	AsyncAPIVersion2 = "2.6.0"
+	AsyncAPIVersion3 = "3.0.0"
+)
+
+// Operation actions in AsyncAPI 3.0 documents, from the application's point of view.
+const (
+	AsyncAPIActionSend    = "send"
+	AsyncAPIActionReceive = "receive"
+)
+
+// AsyncAPI3Spec represents an AsyncAPI 3.0 document.
+// Unlike 2.x, operations are declared separately from channels and reference them.
+type AsyncAPI3Spec struct {
+	AsyncAPI           string                        `json:"asyncapi"`
+	Info               AsyncAPIInfo                  `json:"info"`
+	Servers            map[string]AsyncAPI3Server    `json:"servers,omitempty"`
+	DefaultContentType string                        `json:"defaultContentType,omitempty"`
+	Channels           map[string]AsyncAPI3Channel   `json:"channels"`
+	Operations         map[string]AsyncAPI3Operation `json:"operations"`
+	Components         AsyncAPIComponents            `json:"components"`
+}
+
+// AsyncAPI3Server represents a server object in an AsyncAPI 3.0 document, addressed by host and pathname.
+type AsyncAPI3Server struct {
+	Host        string              `json:"host"`
+	Protocol    string              `json:"protocol"`
+	Pathname    string              `json:"pathname,omitempty"`
+	Description string              `json:"description,omitempty"`
+	Security    []AsyncAPIReference `json:"security,omitempty"`
+}
+
+// AsyncAPI3Channel represents a channel in an AsyncAPI 3.0 document with its address and the messages it carries.
+type AsyncAPI3Channel struct {
+	Address     string                        `json:"address"`
+	Title       string                        `json:"title,omitempty"`
+	Summary     string                        `json:"summary,omitempty"`
+	Description string                        `json:"description,omitempty"`
+	Messages    map[string]AsyncAPIReference  `json:"messages,omitempty"`
+	Parameters  map[string]AsyncAPI3Parameter `json:"parameters,omitempty"`
+	Bindings    map[string]interface{}        `json:"bindings,omitempty"`
+}
+
+// AsyncAPI3Parameter describes a parameter of a channel address.
+type AsyncAPI3Parameter struct {
+	Description string `json:"description,omitempty"`
+}
+
+// AsyncAPI3Operation represents an operation in an AsyncAPI 3.0 document.
+// Action is AsyncAPIActionSend or AsyncAPIActionReceive.
+type AsyncAPI3Operation struct {
+	Action      string                 `json:"action"`
+	Channel     AsyncAPIReference      `json:"channel"`
+	Title       string                 `json:"title,omitempty"`
+	Summary     string                 `json:"summary,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Tags        []AsyncAPITag          `json:"tags,omitempty"`
+	Messages    []AsyncAPIReference    `json:"messages,omitempty"`
+	Reply       *AsyncAPI3Reply        `json:"reply,omitempty"`
+	Security    []AsyncAPIReference    `json:"security,omitempty"`
+	Bindings    map[string]interface{} `json:"bindings,omitempty"`
+	Deprecated  bool                   `json:"x-deprecated,omitempty"`
+}
+
+// AsyncAPI3Reply describes the reply sent in response to an operation.
+type AsyncAPI3Reply struct {
+	Channel  *AsyncAPIReference  `json:"channel,omitempty"`
+	Messages []AsyncAPIReference `json:"messages,omitempty"`
+}
+
+// AsyncAPIReference is a JSON reference to another object of the document.
+type AsyncAPIReference struct {
+	Ref string `json:"$ref"`
+}
+
+// SetAsyncAPIVersion selects the AsyncAPI document version served by the router,
+// either AsyncAPIVersion2 (default) or AsyncAPIVersion3.
+func (r *SteelRouter) SetAsyncAPIVersion(version string) {
+	r.updateSpec(func() {
+		r.options.AsyncAPIVersion = version
+	})
+}
+
+// AsyncAPIV2 returns a deep copy of the AsyncAPI 2.x document of the router, whichever version it serves
+func (r *SteelRouter) AsyncAPIV2() *AsyncAPISpec {
+	r.specs.mu.Lock()
+	defer r.specs.mu.Unlock()
+
+	r.initAsyncAPI()
+	r.finalizeAsyncAPI()
+	return copySpec(r.asyncAPISpec)
+}
+
+// AsyncAPIV3 returns a deep copy of the AsyncAPI 3.0 document of the router, whichever version it serves
+func (r *SteelRouter) AsyncAPIV3() *AsyncAPI3Spec {
+	r.specs.mu.Lock()
+	defer r.specs.mu.Unlock()
+
+	r.initAsyncAPI()
+	r.finalizeAsyncAPI()
+	return copySpec(r.asyncAPI3Spec)
+}
+
+// asyncAPIDocument finalizes and returns the AsyncAPI document of the selected version for
+// serialization. Callers must hold the spec lock.
+func (r *SteelRouter) asyncAPIDocument() interface{} {
+	r.initAsyncAPI()
+	r.finalizeAsyncAPI()
+	if r.options.AsyncAPIVersion == AsyncAPIVersion3 {
+		return r.asyncAPI3Spec
+	}
+	return r.asyncAPISpec
+}
+
+// newAsyncAPI3Spec creates an empty 3.0 document sharing info and component schemas with the 2.x document.
+// Servers and security schemes are filled in by finalizeAsyncAPI.
+func newAsyncAPI3Spec(spec *AsyncAPISpec) *AsyncAPI3Spec {
+	return &AsyncAPI3Spec{
+		AsyncAPI:   AsyncAPIVersion3,
+		Info:       spec.Info,
+		Servers:    make(map[string]AsyncAPI3Server),
+		Channels:   make(map[string]AsyncAPI3Channel),
+		Operations: make(map[string]AsyncAPI3Operation),
+		Components: AsyncAPIComponents{
+			Schemas:  spec.Components.Schemas,
+			Messages: make(map[string]AsyncAPIMessage),
+		},
+	}
+}
+
+// splitAsyncAPIServerURL splits a 2.x server URL into the protocol, host and pathname used by 3.0 servers.
+func splitAsyncAPIServerURL(url, protocol string) (string, string, string) {
+	if scheme, rest, ok := strings.Cut(url, "://"); ok {
+		if protocol == "" {
+			protocol = scheme
+		}
+		url = rest
+	}
+
+	host, pathname, _ := strings.Cut(url, "/")
+	if pathname != "" {
+		pathname = "/" + pathname
+	}
+	return protocol, host, pathname
+}
+
+var (
+	colonParamPattern   = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+	channelParamPattern = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+	channelIDPattern    = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// asyncAPIChannelAddress converts a route pattern into a channel address using {param} expressions.
+func asyncAPIChannelAddress(pattern string) string {
+	return colonParamPattern.ReplaceAllString(pattern, "{$1}")
+}
+
+// asyncAPIChannelID derives an identifier from a route pattern or name, for example "/ws/chat/{room}"
+// becomes "ws_chat_room". Channel keys use channelID, which keeps them unique.
+func asyncAPIChannelID(pattern string) string {
+	id := strings.Trim(channelIDPattern.ReplaceAllString(pattern, "_"), "_")
+	if id == "" {
+		return "root"
+	}
+	return id
+}
+
+// channelID returns the 3.0 channel key of a route pattern. Patterns deriving the same key, such as
+// "/ws/chat" and "/ws-chat", get numbered suffixes in registration order.
+func (r *SteelRouter) channelID(pattern string) string {
+	if id, ok := r.asyncAPIChannelIDs[pattern]; ok {
+		return id
+	}
+
+	base := asyncAPIChannelID(pattern)
+	id := base
+	for n := 2; r.channelIDTaken(id); n++ {
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+	r.asyncAPIChannelIDs[pattern] = id
+	return id
+}
+
+func (r *SteelRouter) channelIDTaken(id string) bool {
+	for _, taken := range r.asyncAPIChannelIDs {
+		if taken == id {
+			return true
+		}
+	}
+	return false
+}
+
+// asyncAPI3Channel creates a channel for a route pattern with its address parameters.
+func asyncAPI3Channel(pattern, description string) AsyncAPI3Channel {
+	address := asyncAPIChannelAddress(pattern)
+
+	channel := AsyncAPI3Channel{
+		Address:     address,
+		Description: description,
+		Messages:    make(map[string]AsyncAPIReference),
+	}
+
+	for _, match := range channelParamPattern.FindAllStringSubmatch(address, -1) {
+		if channel.Parameters == nil {
+			channel.Parameters = make(map[string]AsyncAPI3Parameter)
+		}
+		channel.Parameters[match[1]] = AsyncAPI3Parameter{}
+	}
+
+	return channel
+}
+
+// addAsyncAPI3Message registers a message in the 3.0 components and on the channel, returning the reference
+// operations use. Identical messages share one component, so types used on several channels are documented once.
+func (r *SteelRouter) addAsyncAPI3Message(channelID string, channel *AsyncAPI3Channel, name string, message AsyncAPIMessage) AsyncAPIReference {
+	components := r.asyncAPI3Spec.Components.Messages
+
+	if existing, ok := components[name]; ok && !reflect.DeepEqual(existing, message) {
+		name = channelID + "." + name
+	}
+	components[name] = message
+
+	channel.Messages[name] = AsyncAPIReference{Ref: "#/components/messages/" + name}
+	return AsyncAPIReference{Ref: "#/channels/" + channelID + "/messages/" + name}
+}
+
+// asyncAPI3OperationID returns the operation key of a 3.0 document, the ID set with WithAsyncOperationID or fallback.
+func asyncAPI3OperationID(operationID, fallback string) string {
+	if operationID != "" {
+		return operationID
+	}
+	return fallback
+}
+
+// asyncAPIMessageName names a message after its Go type, falling back to the channel and role for unnamed types.
+func asyncAPIMessageName(t reflect.Type, channelID, role string) string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != nil && t.Name() != "" && t.PkgPath() != "" {
+		return t.Name()
+	}
+	return channelID + "." + role
+}
+
+// generateAsyncAPI3ForWS documents a WebSocket endpoint as a receive operation whose reply carries the handler response.
+func (r *SteelRouter) generateAsyncAPI3ForWS(info *WSHandlerInfo, message, response AsyncAPIMessage) {
+	channelID := r.channelID(info.Path)
+	channel := asyncAPI3Channel(info.Path, info.Description)
+	channel.Bindings = r.wsChannelBindings(info.ParamsType)
+	channelRef := AsyncAPIReference{Ref: "#/channels/" + channelID}
+
+	messageRef := r.addAsyncAPI3Message(channelID, &channel, asyncAPIMessageName(info.MessageType, channelID, "message"), message)
+	responseRef := r.addAsyncAPI3Message(channelID, &channel, asyncAPIMessageName(info.ResponseType, channelID, "response"), response)
+
+	r.asyncAPI3Spec.Channels[channelID] = channel
+	r.asyncAPI3Spec.Operations[asyncAPI3OperationID(info.OperationID, channelID+"_receive")] = AsyncAPI3Operation{
+		Action:      AsyncAPIActionReceive,
+		Channel:     channelRef,
+		Summary:     info.Summary,
+		Description: info.Description,
+		Tags:        convertToAsyncAPITags(info.Tags),
+		Messages:    []AsyncAPIReference{messageRef},
+		Reply: &AsyncAPI3Reply{
+			Channel:  &channelRef,
+			Messages: []AsyncAPIReference{responseRef},
+		},
+		Security:   asyncAPISecurityRefs(info.SecurityRequirements),
+		Deprecated: info.Deprecated,
+	}
+}
+
+// generateAsyncAPI3ForSSE documents an SSE endpoint as a send operation with one message per declared event.
+func (r *SteelRouter) generateAsyncAPI3ForSSE(info *SSEHandlerInfo, messages []AsyncAPIMessage) {
+	channelID := r.channelID(info.Path)
+	channel := asyncAPI3Channel(info.Path, info.Description)
+
+	refs := make([]AsyncAPIReference, 0, len(messages))
+	for _, message := range messages {
+		name := message.Name
+		if name == "" {
+			name = channelID + ".event"
+		}
+		refs = append(refs, r.addAsyncAPI3Message(channelID, &channel, name, message))
+	}
+
+	r.asyncAPI3Spec.Channels[channelID] = channel
+	r.asyncAPI3Spec.Operations[asyncAPI3OperationID(info.OperationID, channelID+"_send")] = AsyncAPI3Operation{
+		Action:      AsyncAPIActionSend,
+		Channel:     AsyncAPIReference{Ref: "#/channels/" + channelID},
+		Summary:     info.Summary,
+		Description: info.Description,
+		Tags:        convertToAsyncAPITags(info.Tags),
+		Messages:    refs,
+		Security:    asyncAPISecurityRefs(info.SecurityRequirements),
+		Bindings:    r.sseOperationBindings(info.ParamsType),
+		Deprecated:  info.Deprecated,
+	}
+}
+
+// generateAsyncAPI3ForJSONRPC documents each JSON-RPC method as a receive operation replying with its result message.
+func (r *SteelRouter) generateAsyncAPI3ForJSONRPC(info *WSHandlerInfo, requests, results []AsyncAPIMessage) {
+	channelID := r.channelID(info.Path)
+	channel := asyncAPI3Channel(info.Path, info.Description)
+	channel.Bindings = r.wsChannelBindings(info.ParamsType)
+	channelRef := AsyncAPIReference{Ref: "#/channels/" + channelID}
+
+	for id, operation := range r.asyncAPI3Spec.Operations {
+		if operation.Channel == channelRef {
+			delete(r.asyncAPI3Spec.Operations, id)
+		}
+	}
+
+	prefix := asyncAPI3OperationID(info.OperationID, channelID)
+	for i, request := range requests {
+		requestRef := r.addAsyncAPI3Message(channelID, &channel, request.Name, request)
+		resultRef := r.addAsyncAPI3Message(channelID, &channel, results[i].Name, results[i])
+
+		r.asyncAPI3Spec.Operations[prefix+"_"+asyncAPIChannelID(request.Name)] = AsyncAPI3Operation{
+			Action:      AsyncAPIActionReceive,
+			Channel:     channelRef,
+			Title:       request.Title,
+			Summary:     request.Summary,
+			Description: request.Description,
+			Tags:        convertToAsyncAPITags(info.Tags),
+			Messages:    []AsyncAPIReference{requestRef},
+			Reply: &AsyncAPI3Reply{
+				Channel:  &channelRef,
+				Messages: []AsyncAPIReference{resultRef},
+			},
+			Security:   asyncAPISecurityRefs(info.SecurityRequirements),
+			Deprecated: info.Deprecated,
+		}
+	}
+
+	r.asyncAPI3Spec.Channels[channelID] = channel
+}
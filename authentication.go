@@ -0,0 +1,209 @@
+package steel
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+)
+
+// Authenticator authenticates requests for one security scheme registered with RegisterSecurityScheme
+type Authenticator interface {
+	// Scheme returns the name of the security scheme
+	Scheme() string
+
+	// Authenticate returns the principal of a request, given the registered definition of the scheme.
+	// It returns a nil principal without error when the request carries no credentials for the scheme,
+	// and an error when the credentials are invalid.
+	Authenticate(req *http.Request, scheme OpenAPISecurityScheme) (Principal, error)
+}
+
+// Authentication returns middleware running the authenticators on every request and recording each
+// principal under its scheme with WithPrincipal. Requests with invalid credentials get 401 Unauthorized,
+// requests without credentials continue anonymously so Authorization can decide whether the route allows it.
+//
+// The schemes must be registered with RegisterSecurityScheme before calling Authentication.
+func (r *SteelRouter) Authentication(authenticators ...Authenticator) MiddlewareFunc {
+	schemes := make([]OpenAPISecurityScheme, len(authenticators))
+	for i, authenticator := range authenticators {
+		var scheme OpenAPISecurityScheme
+		var ok bool
+		r.readSpec(func() {
+			scheme, ok = r.openAPISpec.Components.SecuritySchemes[authenticator.Scheme()]
+		})
+		if !ok {
+			panic(fmt.Sprintf("security scheme %q is not registered", authenticator.Scheme()))
+		}
+		schemes[i] = scheme
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			for i, authenticator := range authenticators {
+				principal, err := authenticator.Authenticate(req, schemes[i])
+				if err != nil {
+					r.handleError(w, req, Unauthorized("Invalid credentials", map[string]interface{}{
+						"scheme": authenticator.Scheme(),
+						"error":  err.Error(),
+					}))
+					return
+				}
+				if principal != nil {
+					ctx = WithPrincipal(ctx, authenticator.Scheme(), principal)
+				}
+			}
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	}
+}
+
+// StaticPrincipal is a Principal with fixed scopes and roles, such as the owner of an API key
+type StaticPrincipal struct {
+	Subject       string
+	GrantedScopes []string
+	GrantedRoles  []string
+}
+
+func (p StaticPrincipal) ID() string       { return p.Subject }
+func (p StaticPrincipal) Scopes() []string { return p.GrantedScopes }
+func (p StaticPrincipal) Roles() []string  { return p.GrantedRoles }
+
+// =============================================================================
+// API Key Authentication
+// =============================================================================
+
+// APIKeyStore resolves API keys to their owners
+type APIKeyStore interface {
+	// Lookup returns the principal owning key, or nil when the key is unknown
+	Lookup(ctx context.Context, key string) (Principal, error)
+}
+
+// StaticAPIKeys is an APIKeyStore backed by a map of keys to principals
+type StaticAPIKeys map[string]Principal
+
+func (s StaticAPIKeys) Lookup(ctx context.Context, key string) (Principal, error) {
+	return s[key], nil
+}
+
+type apiKeyAuthenticator struct {
+	scheme string
+	store  APIKeyStore
+}
+
+// APIKeyAuthenticator authenticates the API key of a scheme registered with APIKeyInHeader or
+// APIKeyInQuery, reading the key from the header, query parameter or cookie the scheme declares.
+func APIKeyAuthenticator(scheme string, store APIKeyStore) Authenticator {
+	return &apiKeyAuthenticator{scheme: scheme, store: store}
+}
+
+func (a *apiKeyAuthenticator) Scheme() string {
+	return a.scheme
+}
+
+func (a *apiKeyAuthenticator) Authenticate(req *http.Request, scheme OpenAPISecurityScheme) (Principal, error) {
+	var key string
+	switch scheme.In {
+	case APIKeyInHeaderType:
+		key = req.Header.Get(scheme.Name)
+	case APIKeyInQueryType:
+		key = req.URL.Query().Get(scheme.Name)
+	case APIKeyInCookieType:
+		if cookie, err := req.Cookie(scheme.Name); err == nil {
+			key = cookie.Value
+		}
+	default:
+		return nil, fmt.Errorf("security scheme %q is not an API key scheme", a.scheme)
+	}
+	if key == "" {
+		return nil, nil
+	}
+
+	principal, err := a.store.Lookup(req.Context(), key)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, fmt.Errorf("unknown API key")
+	}
+	return principal, nil
+}
+
+// =============================================================================
+// HTTP Basic Authentication
+// =============================================================================
+
+// BasicValidator checks a username and password, returning nil when they do not match
+type BasicValidator func(ctx context.Context, username, password string) (Principal, error)
+
+type basicAuthenticator struct {
+	scheme   string
+	validate BasicValidator
+}
+
+// BasicAuthenticator authenticates HTTP Basic credentials for a scheme registered with BasicAuth
+func BasicAuthenticator(scheme string, validate BasicValidator) Authenticator {
+	return &basicAuthenticator{scheme: scheme, validate: validate}
+}
+
+func (a *basicAuthenticator) Scheme() string {
+	return a.scheme
+}
+
+func (a *basicAuthenticator) Authenticate(req *http.Request, scheme OpenAPISecurityScheme) (Principal, error) {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+
+	principal, err := a.validate(req.Context(), username, password)
+	if err != nil {
+		return nil, err
+	}
+	if principal == nil {
+		return nil, fmt.Errorf("invalid username or password")
+	}
+	return principal, nil
+}
+
+// =============================================================================
+// Mutual TLS Authentication
+// =============================================================================
+
+// CertificateMapper maps a verified client certificate to its principal
+type CertificateMapper func(cert *x509.Certificate) (Principal, error)
+
+type mutualTLSAuthenticator struct {
+	scheme string
+	mapper CertificateMapper
+}
+
+// MutualTLSAuthenticator authenticates client certificates for a scheme registered with MutualTLS.
+// Only certificates verified by the TLS server are accepted, so the server must set ClientCAs and
+// ClientAuth to VerifyClientCertIfGiven or RequireAndVerifyClientCert. A nil mapper identifies the
+// principal by the certificate common name, with its organizational units as roles.
+func MutualTLSAuthenticator(scheme string, mapper CertificateMapper) Authenticator {
+	if mapper == nil {
+		mapper = func(cert *x509.Certificate) (Principal, error) {
+			return StaticPrincipal{
+				Subject:      cert.Subject.CommonName,
+				GrantedRoles: cert.Subject.OrganizationalUnit,
+			}, nil
+		}
+	}
+	return &mutualTLSAuthenticator{scheme: scheme, mapper: mapper}
+}
+
+func (a *mutualTLSAuthenticator) Scheme() string {
+	return a.scheme
+}
+
+func (a *mutualTLSAuthenticator) Authenticate(req *http.Request, scheme OpenAPISecurityScheme) (Principal, error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	if len(req.TLS.VerifiedChains) == 0 {
+		return nil, fmt.Errorf("client certificate is not verified")
+	}
+	return a.mapper(req.TLS.VerifiedChains[0][0])
+}
@@ -0,0 +1,178 @@
+package steel
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Principal is the authenticated caller of a request
+type Principal interface {
+	// ID identifies the caller, such as a user or client ID
+	ID() string
+	// Scopes returns the OAuth2 scopes granted to the caller
+	Scopes() []string
+	// Roles returns the roles of the caller
+	Roles() []string
+}
+
+// principalEntry is a principal with the security scheme that authenticated it
+type principalEntry struct {
+	scheme    string
+	principal Principal
+}
+
+type principalsKeyType struct{}
+
+var principalsKey = principalsKeyType{}
+
+// WithPrincipal returns a copy of ctx recording a principal authenticated by the named security scheme.
+// Authentication middleware calls it so authorization can evaluate the security requirements of a route.
+func WithPrincipal(ctx context.Context, scheme string, principal Principal) context.Context {
+	existing, _ := ctx.Value(principalsKey).([]principalEntry)
+
+	entries := make([]principalEntry, 0, len(existing)+1)
+	for _, entry := range existing {
+		if entry.scheme != scheme {
+			entries = append(entries, entry)
+		}
+	}
+	entries = append(entries, principalEntry{scheme: scheme, principal: principal})
+
+	return context.WithValue(ctx, principalsKey, entries)
+}
+
+// PrincipalFromContext returns the principal authenticated by the named security scheme, or nil
+func PrincipalFromContext(ctx context.Context, scheme string) Principal {
+	entries, _ := ctx.Value(principalsKey).([]principalEntry)
+	for _, entry := range entries {
+		if entry.scheme == scheme {
+			return entry.principal
+		}
+	}
+	return nil
+}
+
+// FirstPrincipal returns the first principal recorded on ctx, whichever scheme authenticated it, or nil.
+// It is the principal Context.Principal returns, for code that only has the request.
+func FirstPrincipal(ctx context.Context) Principal {
+	entries, _ := ctx.Value(principalsKey).([]principalEntry)
+	if len(entries) == 0 {
+		return nil
+	}
+	return entries[0].principal
+}
+
+// principalID returns the ID of the first principal recorded on ctx, or an empty string
+func principalID(ctx context.Context) string {
+	if principal := FirstPrincipal(ctx); principal != nil {
+		return principal.ID()
+	}
+	return ""
+}
+
+// Authorization returns opinionated middleware enforcing the security requirements declared on each
+// route with WithSecurity, falling back to the global security set with SetGlobalSecurity.
+//
+// A request is allowed when it satisfies any of the requirements. A requirement is satisfied when every
+// security scheme it names has authenticated a principal, see WithPrincipal, holding every scope or role
+// listed for that scheme. An empty requirement allows anonymous access. Requests without a principal for
+// the required schemes get 401 Unauthorized, authenticated requests missing scopes or roles 403 Forbidden.
+//
+// Register it after the middleware authenticating requests:
+//
+//	router.UseOpinionated(middleware.OpinionatedJWT(jwtConfig), router.Authorization())
+func (r *SteelRouter) Authorization() OpinionatedMiddleware {
+	return NewMiddleware("authorization").
+		Description("Enforces the OpenAPI security requirements of each operation").
+		Before(func(ctx *MiddlewareContext) error {
+			var requirements []OpenAPISecurityRequirement
+			if ctx.HandlerInfo != nil && len(ctx.HandlerInfo.SecurityRequirements) > 0 {
+				requirements = ctx.HandlerInfo.SecurityRequirements
+			} else {
+				r.readSpec(func() {
+					requirements = r.globalSecurity
+				})
+			}
+			if len(requirements) == 0 {
+				return nil
+			}
+
+			authenticated := false
+			var missing []string
+			for _, requirement := range requirements {
+				principal, lacking, ok := evaluateSecurityRequirement(ctx.Request.Context(), requirement)
+				if ok {
+					if ctx.UserID == "" && principal != nil {
+						ctx.UserID = principal.ID()
+					}
+					return nil
+				}
+				if lacking != nil {
+					authenticated = true
+					missing = append(missing, strings.Join(lacking, ", "))
+				}
+			}
+
+			if !authenticated {
+				return Unauthorized("Authentication required", map[string]interface{}{
+					"schemes": securityRequirementSchemes(requirements),
+				})
+			}
+			return Forbidden("Insufficient scopes or roles for this operation", map[string]interface{}{
+				"missing": missing,
+			})
+		}).
+		Build()
+}
+
+// evaluateSecurityRequirement checks one requirement against the principals of a request. It returns
+// the first principal of the requirement and, when all schemes are authenticated but the requirement is
+// not satisfied, the scopes and roles missing as "scheme:value".
+func evaluateSecurityRequirement(ctx context.Context, requirement OpenAPISecurityRequirement) (Principal, []string, bool) {
+	schemes := make([]string, 0, len(requirement))
+	for scheme := range requirement {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	var first Principal
+	var lacking []string
+	for _, scheme := range schemes {
+		principal := PrincipalFromContext(ctx, scheme)
+		if principal == nil {
+			return nil, nil, false
+		}
+		if first == nil {
+			first = principal
+		}
+
+		for _, value := range requirement[scheme] {
+			if !containsString(principal.Scopes(), value) && !containsString(principal.Roles(), value) {
+				lacking = append(lacking, fmt.Sprintf("%s:%s", scheme, value))
+			}
+		}
+	}
+
+	if len(lacking) > 0 {
+		return first, lacking, false
+	}
+	return first, nil, true
+}
+
+// securityRequirementSchemes returns the sorted scheme names used by a list of requirements
+func securityRequirementSchemes(requirements []OpenAPISecurityRequirement) []string {
+	seen := make(map[string]bool)
+	var schemes []string
+	for _, requirement := range requirements {
+		for scheme := range requirement {
+			if !seen[scheme] {
+				seen[scheme] = true
+				schemes = append(schemes, scheme)
+			}
+		}
+	}
+	sort.Strings(schemes)
+	return schemes
+}
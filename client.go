@@ -0,0 +1,444 @@
+package steel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// APIClient is the transport of generated clients. Inputs are mapped to requests with the path, query,
+// header and body tags handlers are bound with, and error responses are decoded into *HTTPError.
+type APIClient struct {
+	// BaseURL is prepended to every route pattern, for example "https://api.example.com".
+	BaseURL string
+
+	// HTTPClient sends HTTP requests and opens event streams. Defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// Dialer opens WebSocket connections. Defaults to websocket.DefaultDialer.
+	Dialer *websocket.Dialer
+
+	// Header is sent with every request, for example to carry credentials.
+	Header http.Header
+}
+
+// NewAPIClient creates a client for the API served at baseURL.
+func NewAPIClient(baseURL string) *APIClient {
+	return &APIClient{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Header:  make(http.Header),
+	}
+}
+
+// Do calls the route registered with method and pattern. Input is a struct, or a pointer to one, tagged like
+// a handler input and output is decoded from a successful JSON response. Either may be nil.
+func (c *APIClient) Do(ctx context.Context, method, pattern string, input, output interface{}) error {
+	req, err := c.newRequest(ctx, method, pattern, input)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return decodeErrorResponse(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if output == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("failed to decode %s %s response: %w", method, pattern, err)
+	}
+	return nil
+}
+
+func (c *APIClient) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// newRequest builds a request for a route, mirroring how bindParameters reads the input.
+func (c *APIClient) newRequest(ctx context.Context, method, pattern string, input interface{}) (*http.Request, error) {
+	path, query, header, body, err := mapClientInput(method, pattern, input)
+	if err != nil {
+		return nil, err
+	}
+
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, values := range c.Header {
+		req.Header[key] = append([]string(nil), values...)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return req, nil
+}
+
+// mapClientInput splits an input struct into the path, query, headers and JSON body of a request.
+// A field tagged body is sent as the body; otherwise the whole struct is sent when it has untagged fields
+// and the method carries a body.
+func mapClientInput(method, pattern string, input interface{}) (string, url.Values, http.Header, []byte, error) {
+	query := make(url.Values)
+	header := make(http.Header)
+
+	val := reflect.ValueOf(input)
+	for val.Kind() == reflect.Ptr {
+		if val.IsNil() {
+			return pattern, query, header, nil, nil
+		}
+		val = val.Elem()
+	}
+	if !val.IsValid() || val.Kind() != reflect.Struct {
+		return pattern, query, header, nil, nil
+	}
+
+	typ := val.Type()
+	segments := strings.Split(pattern, "/")
+
+	var body []byte
+	bodyHandled, hasBodyFields := false, false
+
+	for i := 0; i < typ.NumField(); i++ {
+		fieldType := typ.Field(i)
+		if !fieldType.IsExported() {
+			continue
+		}
+		field := val.Field(i)
+
+		if bodyTag := fieldType.Tag.Get("body"); bodyTag != "" && !bodyHandled {
+			data, err := json.Marshal(field.Interface())
+			if err != nil {
+				return "", nil, nil, nil, fmt.Errorf("body: %w", err)
+			}
+			body, bodyHandled = data, true
+			continue
+		}
+
+		tagged := false
+		if name := fieldType.Tag.Get("path"); name != "" {
+			tagged = true
+			values := formatClientValues(field)
+			value := ""
+			if len(values) > 0 {
+				value = values[0]
+			}
+			for j, segment := range segments {
+				if segment == ":"+name {
+					segments[j] = url.PathEscape(value)
+				}
+			}
+		}
+		if name := fieldType.Tag.Get("query"); name != "" {
+			tagged = true
+			for _, value := range formatClientValues(field) {
+				query.Add(name, value)
+			}
+		}
+		if name := fieldType.Tag.Get("header"); name != "" {
+			tagged = true
+			for _, value := range formatClientValues(field) {
+				header.Add(name, value)
+			}
+		}
+
+		if !tagged {
+			hasBodyFields = true
+		}
+	}
+
+	if !bodyHandled && hasBodyFields && (method == http.MethodPost || method == http.MethodPut || method == http.MethodPatch) {
+		data, err := json.Marshal(val.Interface())
+		if err != nil {
+			return "", nil, nil, nil, fmt.Errorf("body: %w", err)
+		}
+		body = data
+	}
+
+	return strings.Join(segments, "/"), query, header, body, nil
+}
+
+// formatClientValues formats a parameter the way setFieldValue parses it. Zero values are omitted
+// since the server treats empty parameters as absent, and slices produce one value per element.
+func formatClientValues(field reflect.Value) []string {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	if field.IsZero() {
+		return nil
+	}
+
+	if marshaler, ok := field.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		if err != nil {
+			return nil
+		}
+		return []string{string(text)}
+	}
+
+	if field.Kind() == reflect.Slice || field.Kind() == reflect.Array {
+		values := make([]string, 0, field.Len())
+		for i := 0; i < field.Len(); i++ {
+			values = append(values, formatClientValues(field.Index(i))...)
+		}
+		return values
+	}
+
+	return []string{fmt.Sprint(field.Interface())}
+}
+
+// decodeErrorResponse converts an error response into an *HTTPError, falling back to the status
+// text when the body is not a standard ErrorResponse.
+func decodeErrorResponse(resp *http.Response) error {
+	data, _ := io.ReadAll(resp.Body)
+
+	var response ErrorResponse
+	if err := json.Unmarshal(data, &response); err == nil && response.Error.Status != 0 {
+		return &HTTPError{
+			Status:    response.Error.Status,
+			Code:      response.Error.Code,
+			Message:   response.Error.Message,
+			Detail:    response.Error.Detail,
+			Timestamp: response.Error.Timestamp,
+			RequestID: response.Error.RequestID,
+			Path:      response.Error.Path,
+		}
+	}
+
+	message := strings.TrimSpace(string(data))
+	if message == "" {
+		message = http.StatusText(resp.StatusCode)
+	}
+	return &HTTPError{
+		Status:    resp.StatusCode,
+		Code:      strings.ToUpper(strings.ReplaceAll(http.StatusText(resp.StatusCode), " ", "_")),
+		Message:   message,
+		Timestamp: time.Now(),
+		Path:      resp.Request.URL.Path,
+	}
+}
+
+// WSClient is a client connection to a WebSocket endpoint that receives messages of type TMessage
+// and replies with TResponse, using the JSON codec.
+type WSClient[TMessage any, TResponse any] struct {
+	conn *websocket.Conn
+}
+
+// DialWebSocket connects to the WebSocket endpoint registered with pattern. Params fills the path, query and
+// header parameters of the upgrade request like the input of an HTTP call.
+func DialWebSocket[TMessage any, TResponse any](ctx context.Context, c *APIClient, pattern string, params interface{}) (*WSClient[TMessage, TResponse], error) {
+	path, query, header, _, err := mapClientInput(http.MethodGet, pattern, params)
+	if err != nil {
+		return nil, err
+	}
+
+	target := strings.Replace(c.BaseURL, "http", "ws", 1) + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	for key, values := range c.Header {
+		header[key] = append(values, header[key]...)
+	}
+
+	dialer := c.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, target, header)
+	if err != nil {
+		if resp != nil && resp.StatusCode >= http.StatusBadRequest {
+			defer resp.Body.Close()
+			return nil, decodeErrorResponse(resp)
+		}
+		return nil, err
+	}
+	return &WSClient[TMessage, TResponse]{conn: conn}, nil
+}
+
+// Send sends a message of the given type. A non-empty id is echoed on the response.
+func (c *WSClient[TMessage, TResponse]) Send(messageType, id string, message TMessage) error {
+	return c.conn.WriteJSON(WSMessage{Type: messageType, ID: id, Payload: message})
+}
+
+// ReadMessage reads the next raw message, including acknowledgements and server-initiated requests.
+func (c *WSClient[TMessage, TResponse]) ReadMessage() (WSMessage, error) {
+	var message WSMessage
+	err := c.conn.ReadJSON(&message)
+	return message, err
+}
+
+// Receive reads the next response, skipping acknowledgements. Error messages are returned as *WSError.
+func (c *WSClient[TMessage, TResponse]) Receive() (*TResponse, string, error) {
+	for {
+		message, err := c.ReadMessage()
+		if err != nil {
+			return nil, "", err
+		}
+
+		switch message.Type {
+		case WSMessageTypeAck:
+			continue
+		case WSMessageTypeError:
+			if message.Error == nil {
+				return nil, message.ID, NewWSError(WSErrorInternal, "unknown error")
+			}
+			return nil, message.ID, message.Error
+		}
+
+		data, err := json.Marshal(message.Payload)
+		if err != nil {
+			return nil, message.ID, err
+		}
+		var response TResponse
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, message.ID, fmt.Errorf("failed to decode %s message: %w", message.Type, err)
+		}
+		return &response, message.ID, nil
+	}
+}
+
+// Close closes the connection.
+func (c *WSClient[TMessage, TResponse]) Close() error {
+	return c.conn.Close()
+}
+
+// StreamEvent is an event read from a server-sent events stream.
+type StreamEvent struct {
+	ID    string
+	Event string
+	Data  []byte
+	Retry int
+}
+
+// Decode decodes the JSON data of the event into v.
+func (e StreamEvent) Decode(v interface{}) error {
+	return json.Unmarshal(e.Data, v)
+}
+
+// EventStream reads the events of a server-sent events endpoint.
+type EventStream struct {
+	body        io.ReadCloser
+	reader      *bufio.Reader
+	lastEventID string
+}
+
+// OpenEventStream connects to the SSE endpoint registered with pattern. Params fills the path, query and header
+// parameters of the request. A non-empty lastEventID asks the server to replay the events missed since then.
+func OpenEventStream(ctx context.Context, c *APIClient, pattern string, params interface{}, lastEventID string) (*EventStream, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, pattern, params)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close()
+		return nil, decodeErrorResponse(resp)
+	}
+
+	return &EventStream{body: resp.Body, reader: bufio.NewReader(resp.Body), lastEventID: lastEventID}, nil
+}
+
+// Next blocks until the next event arrives. Comments such as heartbeats are skipped.
+func (s *EventStream) Next() (StreamEvent, error) {
+	var (
+		event StreamEvent
+		data  []string
+	)
+
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return StreamEvent{}, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if data == nil && event.Event == "" && event.ID == "" {
+				continue
+			}
+			event.Data = []byte(strings.Join(data, "\n"))
+			if event.ID != "" {
+				s.lastEventID = event.ID
+			}
+			return event, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			fmt.Sscan(value, &event.Retry)
+		}
+	}
+}
+
+// LastEventID returns the ID of the last event read, to resume the stream after reconnecting.
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Close closes the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
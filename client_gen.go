@@ -0,0 +1,465 @@
+package steel
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// steelImportPath is the import path generated clients use for the client runtime.
+const steelImportPath = "github.com/xraph/steel"
+
+// ClientConfig configures GenerateClient.
+type ClientConfig struct {
+	// PackageName is the package clause of the generated file. Defaults to "client".
+	PackageName string
+}
+
+// GenerateClient writes a Go source file with a typed client for the router's opinionated, WebSocket and SSE
+// handlers. Each HTTP operation becomes a method taking a copy of the handler's input type, so the path,
+// query, header and body tags keep working, and returning its output type. Error responses are returned
+// as *HTTPError. Input and output types are copied field by field; methods on them, including custom
+// JSON marshaling, are not. Types declared in package main cannot be imported and are reported as an error.
+//
+// The cmd/steel-client command runs GenerateClient from go:generate.
+func (r *SteelRouter) GenerateClient(w io.Writer, config ClientConfig) error {
+	if config.PackageName == "" {
+		config.PackageName = "client"
+	}
+
+	g := &clientGenerator{
+		imports:     map[string]bool{steelImportPath: true},
+		types:       make(map[reflect.Type]string),
+		names:       make(map[string]reflect.Type),
+		methodNames: make(map[string]bool),
+		events:      make(map[string]bool),
+	}
+
+	for _, info := range sortedHandlers(r.handlers) {
+		g.writeOperation(info)
+	}
+
+	wsPatterns := make([]string, 0, len(r.wsHandlers))
+	for pattern := range r.wsHandlers {
+		wsPatterns = append(wsPatterns, pattern)
+	}
+	sort.Strings(wsPatterns)
+	for _, pattern := range wsPatterns {
+		g.writeWebSocket(r.wsHandlers[pattern])
+	}
+
+	ssePatterns := make([]string, 0, len(r.sseHandlers))
+	for pattern := range r.sseHandlers {
+		ssePatterns = append(ssePatterns, pattern)
+	}
+	sort.Strings(ssePatterns)
+	for _, pattern := range ssePatterns {
+		g.writeSSE(r.sseHandlers[pattern])
+	}
+
+	if g.err != nil {
+		return g.err
+	}
+
+	source, err := format.Source(g.file(config.PackageName))
+	if err != nil {
+		return fmt.Errorf("failed to format generated client: %w", err)
+	}
+	_, err = w.Write(source)
+	return err
+}
+
+// sortedHandlers orders handlers by path and method so generated files are stable.
+func sortedHandlers(handlers map[string]*HandlerInfo) []*HandlerInfo {
+	sorted := make([]*HandlerInfo, 0, len(handlers))
+	for _, info := range handlers {
+		sorted = append(sorted, info)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Path != sorted[j].Path {
+			return sorted[i].Path < sorted[j].Path
+		}
+		return sorted[i].Method < sorted[j].Method
+	})
+	return sorted
+}
+
+// clientGenerator collects the declarations of a generated client file.
+type clientGenerator struct {
+	imports     map[string]bool
+	types       map[reflect.Type]string
+	names       map[string]reflect.Type
+	decls       []string
+	methods     bytes.Buffer
+	methodNames map[string]bool
+	events      map[string]bool
+	err         error
+}
+
+// file assembles the generated source.
+func (g *clientGenerator) file(packageName string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by steel. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\n", packageName)
+
+	// Standard library imports come first, separated from the others by a blank line
+	imports := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		imports = append(imports, path)
+	}
+	sort.Slice(imports, func(i, j int) bool {
+		if isStdlibPackage(imports[i]) != isStdlibPackage(imports[j]) {
+			return isStdlibPackage(imports[i])
+		}
+		return imports[i] < imports[j]
+	})
+	buf.WriteString("import (\n")
+	for i, path := range imports {
+		if i > 0 && isStdlibPackage(imports[i-1]) && !isStdlibPackage(path) {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "\t%q\n", path)
+	}
+	buf.WriteString(")\n\n")
+
+	buf.WriteString("// Client is a typed client for the API.\n")
+	buf.WriteString("type Client struct {\n\t*steel.APIClient\n}\n\n")
+	buf.WriteString("// New creates a client for the API served at baseURL.\n")
+	buf.WriteString("func New(baseURL string) *Client {\n\treturn &Client{APIClient: steel.NewAPIClient(baseURL)}\n}\n\n")
+
+	buf.Write(g.methods.Bytes())
+
+	for _, decl := range g.decls {
+		buf.WriteString(decl)
+		buf.WriteString("\n\n")
+	}
+
+	return buf.Bytes()
+}
+
+// methodName returns a unique method name, avoiding the fields and methods promoted from APIClient.
+func (g *clientGenerator) methodName(operationID, prefix, pattern string) string {
+	g.imports["context"] = true
+
+	name := clientIdentifier(operationID)
+	if operationID == "" {
+		name = prefix + pathIdentifier(pattern)
+	}
+
+	switch name {
+	case "Do", "BaseURL", "HTTPClient", "Dialer", "Header", "APIClient":
+		name += "Operation"
+	}
+
+	unique := name
+	for i := 2; g.methodNames[unique]; i++ {
+		unique = name + strconv.Itoa(i)
+	}
+	g.methodNames[unique] = true
+	return unique
+}
+
+// writeDoc writes a method comment from the handler documentation.
+func (g *clientGenerator) writeDoc(name, action, summary, description string, deprecated bool) {
+	fmt.Fprintf(&g.methods, "// %s %s.\n", name, action)
+	for _, text := range []string{summary, description} {
+		if text == "" {
+			continue
+		}
+		g.methods.WriteString("//\n")
+		for _, line := range strings.Split(text, "\n") {
+			fmt.Fprintf(&g.methods, "// %s\n", line)
+		}
+	}
+	if deprecated {
+		g.methods.WriteString("//\n// Deprecated: the operation is deprecated.\n")
+	}
+}
+
+func (g *clientGenerator) writeOperation(info *HandlerInfo) {
+	name := g.methodName(info.OperationID, clientIdentifier(strings.ToLower(info.Method)), info.Path)
+	g.writeDoc(name, fmt.Sprintf("calls %s %s", info.Method, info.Path), info.Summary, info.Description, info.Deprecated)
+
+	params, input := "ctx context.Context", "nil"
+	if !isEmptyStruct(info.InputType) {
+		params += ", in " + g.typeExpr(info.InputType)
+		input = "&in"
+	}
+
+	if info.OutputType == reflect.TypeOf(APIResponse{}) {
+		// APIResponse carries arbitrary data with a status code chosen at runtime
+		g.imports["encoding/json"] = true
+		fmt.Fprintf(&g.methods, "func (c *Client) %s(%s) (json.RawMessage, error) {\n", name, params)
+		g.methods.WriteString("\tvar out json.RawMessage\n")
+		fmt.Fprintf(&g.methods, "\tif err := c.APIClient.Do(ctx, %q, %q, %s, &out); err != nil {\n", info.Method, info.Path, input)
+		g.methods.WriteString("\t\treturn nil, err\n\t}\n\treturn out, nil\n}\n\n")
+		return
+	}
+
+	output := g.typeExpr(info.OutputType)
+	fmt.Fprintf(&g.methods, "func (c *Client) %s(%s) (*%s, error) {\n", name, params, output)
+	fmt.Fprintf(&g.methods, "\tvar out %s\n", output)
+	fmt.Fprintf(&g.methods, "\tif err := c.APIClient.Do(ctx, %q, %q, %s, &out); err != nil {\n", info.Method, info.Path, input)
+	g.methods.WriteString("\t\treturn nil, err\n\t}\n\treturn &out, nil\n}\n\n")
+}
+
+func (g *clientGenerator) writeWebSocket(info *WSHandlerInfo) {
+	if info.MessageType == nil || info.ResponseType == nil {
+		// JSON-RPC endpoints have no single message type
+		return
+	}
+
+	name := g.methodName(info.OperationID, "Connect", info.Path)
+	g.writeDoc(name, "opens the WebSocket endpoint "+info.Path, info.Summary, info.Description, info.Deprecated)
+
+	params, args := g.asyncParams(name, info.Path, info.ParamsType)
+	message, response := g.typeExpr(info.MessageType), g.typeExpr(info.ResponseType)
+	fmt.Fprintf(&g.methods, "func (c *Client) %s(ctx context.Context%s) (*steel.WSClient[%s, %s], error) {\n", name, params, message, response)
+	fmt.Fprintf(&g.methods, "\treturn steel.DialWebSocket[%s, %s](ctx, c.APIClient, %q, %s)\n}\n\n", message, response, info.Path, args)
+}
+
+func (g *clientGenerator) writeSSE(info *SSEHandlerInfo) {
+	name := g.methodName(info.OperationID, "Subscribe", info.Path)
+	g.writeDoc(name, "opens the event stream "+info.Path+", resuming after lastEventID when it is not empty",
+		info.Summary, info.Description, info.Deprecated)
+
+	params, args := g.asyncParams(name, info.Path, info.ParamsType)
+	fmt.Fprintf(&g.methods, "func (c *Client) %s(ctx context.Context%s, lastEventID string) (*steel.EventStream, error) {\n", name, params)
+	fmt.Fprintf(&g.methods, "\treturn steel.OpenEventStream(ctx, c.APIClient, %q, %s, lastEventID)\n}\n\n", info.Path, args)
+
+	for _, event := range info.Events {
+		if g.events[event.Name] || event.DataType == nil {
+			continue
+		}
+		g.events[event.Name] = true
+
+		eventName := clientIdentifier(event.Name)
+		data := g.typeExpr(event.DataType)
+		fmt.Fprintf(&g.methods, "// Event%s is the name of the %s event.\nconst Event%s = %q\n\n", eventName, event.Name, eventName, event.Name)
+		fmt.Fprintf(&g.methods, "// Decode%sEvent decodes the data of a %s event.\n", eventName, event.Name)
+		fmt.Fprintf(&g.methods, "func Decode%sEvent(event steel.StreamEvent) (*%s, error) {\n", eventName, data)
+		fmt.Fprintf(&g.methods, "\tvar data %s\n\tif err := event.Decode(&data); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &data, nil\n}\n\n", data)
+	}
+}
+
+// asyncParams returns the parameter list and argument of an async helper. Endpoints without a params type
+// get a generated struct with their path parameters.
+func (g *clientGenerator) asyncParams(name, pattern string, paramsType reflect.Type) (string, string) {
+	if paramsType != nil && !isEmptyStruct(paramsType) {
+		return ", params " + g.typeExpr(paramsType), "params"
+	}
+
+	var fields []string
+	for _, segment := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(segment, ":") {
+			fields = append(fields, fmt.Sprintf("\t%s string `path:%q`", clientIdentifier(segment[1:]), segment[1:]))
+		}
+	}
+	if len(fields) == 0 {
+		return "", "nil"
+	}
+
+	typeName := name + "Params"
+	g.decls = append(g.decls, fmt.Sprintf("// %s holds the path parameters of %s.\ntype %s struct {\n%s\n}",
+		typeName, pattern, typeName, strings.Join(fields, "\n")))
+	return ", params " + typeName, "params"
+}
+
+// typeExpr returns the Go expression of a type in the generated package, declaring copies of named types.
+func (g *clientGenerator) typeExpr(t reflect.Type) string {
+	if t.Name() != "" {
+		switch {
+		case t.PkgPath() == "":
+			return t.Name()
+		case t.PkgPath() == "main":
+			if g.err == nil {
+				g.err = fmt.Errorf("type %s is declared in package main, which clients cannot import; move it to its own package", t)
+			}
+			return t.Name()
+		case t.PkgPath() == steelImportPath && ast.IsExported(t.Name()):
+			return "steel." + t.Name()
+		case isStdlibPackage(t.PkgPath()):
+			g.imports[t.PkgPath()] = true
+			return t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:] + "." + t.Name()
+		default:
+			return g.declare(t)
+		}
+	}
+	return g.unnamedExpr(t)
+}
+
+// unnamedExpr returns the type literal of a type, ignoring its name.
+func (g *clientGenerator) unnamedExpr(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + g.typeExpr(t.Elem())
+	case reflect.Slice:
+		return "[]" + g.typeExpr(t.Elem())
+	case reflect.Array:
+		return fmt.Sprintf("[%d]%s", t.Len(), g.typeExpr(t.Elem()))
+	case reflect.Map:
+		return fmt.Sprintf("map[%s]%s", g.typeExpr(t.Key()), g.typeExpr(t.Elem()))
+	case reflect.Struct:
+		return g.structExpr(t)
+	case reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return "interface{}"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// declare adds a copy of a named type to the generated file. Names taken by another package's type
+// are prefixed with the package name.
+func (g *clientGenerator) declare(t reflect.Type) string {
+	if name, ok := g.types[t]; ok {
+		return name
+	}
+
+	base := clientIdentifier(t.Name())
+	name := base
+	if other, taken := g.names[name]; taken && other != t {
+		base = clientIdentifier(t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]) + base
+		name = base
+		for i := 2; g.names[name] != nil; i++ {
+			name = base + strconv.Itoa(i)
+		}
+	}
+	g.types[t] = name
+	g.names[name] = t
+
+	g.decls = append(g.decls, "") // reserve the position so declarations follow first use
+	index := len(g.decls) - 1
+	g.decls[index] = fmt.Sprintf("// %s mirrors %s.%s.\ntype %s %s", name, t.PkgPath(), t.Name(), name, g.unnamedExpr(t))
+	return name
+}
+
+// structExpr returns a struct literal with the exported fields and tags of t.
+func (g *clientGenerator) structExpr(t reflect.Type) string {
+	var buf strings.Builder
+	buf.WriteString("struct {\n")
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+
+		if field.Anonymous {
+			buf.WriteString("\t" + g.typeExpr(field.Type))
+		} else {
+			buf.WriteString("\t" + field.Name + " " + g.typeExpr(field.Type))
+		}
+		if field.Tag != "" {
+			buf.WriteString(" " + quoteTag(string(field.Tag)))
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+func quoteTag(tag string) string {
+	if strings.Contains(tag, "`") {
+		return strconv.Quote(tag)
+	}
+	return "`" + tag + "`"
+}
+
+func isEmptyStruct(t reflect.Type) bool {
+	return t == nil || (t.Kind() == reflect.Struct && t.NumField() == 0)
+}
+
+// isStdlibPackage reports whether an import path belongs to the standard library, whose first element has no dot.
+// Package main is not importable, so it never is.
+func isStdlibPackage(path string) bool {
+	first, _, _ := strings.Cut(path, "/")
+	return path != "main" && !strings.Contains(first, ".")
+}
+
+// clientInitialisms are kept upper case in generated identifiers.
+var clientInitialisms = map[string]string{
+	"api": "API", "http": "HTTP", "id": "ID", "ids": "IDs", "json": "JSON", "sse": "SSE",
+	"url": "URL", "uuid": "UUID", "ws": "WS",
+}
+
+// clientIdentifier converts a name such as an operation ID, path segment or event name to an exported identifier.
+func clientIdentifier(name string) string {
+	var buf strings.Builder
+	for _, word := range splitIdentifierWords(name) {
+		if initialism, ok := clientInitialisms[strings.ToLower(word)]; ok {
+			buf.WriteString(initialism)
+			continue
+		}
+		runes := []rune(word)
+		runes[0] = unicode.ToUpper(runes[0])
+		buf.WriteString(string(runes))
+	}
+
+	identifier := buf.String()
+	if identifier == "" {
+		return "Root"
+	}
+	if unicode.IsDigit(rune(identifier[0])) {
+		return "N" + identifier
+	}
+	return identifier
+}
+
+// splitIdentifierWords splits a name on non-alphanumeric characters and lower-to-upper case transitions.
+func splitIdentifierWords(name string) []string {
+	var (
+		words   []string
+		current []rune
+	)
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = nil
+		}
+	}
+
+	runes := []rune(name)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && unicode.IsLower(runes[i-1]):
+			flush()
+			current = append(current, r)
+		default:
+			current = append(current, r)
+		}
+	}
+	flush()
+	return words
+}
+
+// pathIdentifier names a route pattern, for example /users/:id/posts becomes UsersByIDPosts.
+func pathIdentifier(pattern string) string {
+	var buf strings.Builder
+	for _, segment := range strings.Split(pattern, "/") {
+		switch {
+		case segment == "":
+		case strings.HasPrefix(segment, ":"):
+			buf.WriteString("By" + clientIdentifier(segment[1:]))
+		case segment == "*":
+			buf.WriteString("Wildcard")
+		default:
+			buf.WriteString(clientIdentifier(segment))
+		}
+	}
+	if buf.Len() == 0 {
+		return "Root"
+	}
+	return buf.String()
+}
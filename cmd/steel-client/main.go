@@ -0,0 +1,107 @@
+// Command steel-client generates a typed Go client from the handlers registered on a steel router.
+//
+// The router is built by calling a constructor of type func() *steel.SteelRouter from an importable package,
+// so it must not live in package main. Typical go:generate use, from a directory inside the same module:
+//
+//	//go:generate go run github.com/xraph/steel/cmd/steel-client -pkg example.com/app/api -func NewRouter -out client/client.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"text/template"
+)
+
+var program = template.Must(template.New("main").Parse(`package main
+
+import (
+	"log"
+	"os"
+
+	api {{printf "%q" .Package}}
+	"github.com/xraph/steel"
+)
+
+func main() {
+	var router *steel.SteelRouter = api.{{.Func}}()
+
+	file, err := os.Create({{printf "%q" .Out}})
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := router.GenerateClient(file, steel.ClientConfig{PackageName: {{printf "%q" .PackageName}}}); err != nil {
+		file.Close()
+		log.Fatal(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+`))
+
+func main() {
+	pkg := flag.String("pkg", "", "import path of the package with the router constructor (required)")
+	fn := flag.String("func", "NewRouter", "name of the router constructor, of type func() *steel.SteelRouter")
+	out := flag.String("out", "client/client.go", "path of the generated file")
+	packageName := flag.String("package", "", "package name of the generated file (default: the name of the output directory)")
+	flag.Parse()
+
+	if *pkg == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := generate(*pkg, *fn, *out, *packageName); err != nil {
+		log.Fatalf("steel-client: %v", err)
+	}
+}
+
+// generate builds and runs a temporary program that calls GenerateClient on the router.
+// The program is created in the working directory so it resolves imports within the current module.
+func generate(pkg, fn, out, packageName string) error {
+	out, err := filepath.Abs(out)
+	if err != nil {
+		return err
+	}
+	if packageName == "" {
+		packageName = filepath.Base(filepath.Dir(out))
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp(".", "_steel_client_")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	file, err := os.Create(filepath.Join(dir, "main.go"))
+	if err != nil {
+		return err
+	}
+	err = program.Execute(file, map[string]string{
+		"Package":     pkg,
+		"Func":        fn,
+		"Out":         out,
+		"PackageName": packageName,
+	})
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("go", "run", "./"+filepath.Base(dir))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running the generator: %w", err)
+	}
+	return nil
+}
@@ -33,11 +33,23 @@ func URLParam(r *http.Request, key string) string {
 	return params.Get(key)
 }
 
+// RoutePattern returns the pattern of the route matched for a request, such as "/users/:id",
+// or an empty string outside of the router. Unlike the URL path it has bounded cardinality,
+// so it suits metric labels and span names.
+func RoutePattern(r *http.Request) string {
+	return ParamsFromContext(r.Context()).Route()
+}
+
 // Param Context methods
 func (c *Context) Param(key string) string {
 	return c.params.Get(key)
 }
 
+// RoutePattern returns the pattern of the matched route
+func (c *Context) RoutePattern() string {
+	return c.params.Route()
+}
+
 func (c *Context) Query(key string) string {
 	return c.Request.URL.Query().Get(key)
 }
@@ -61,6 +73,12 @@ func (c *Context) BindJSON(v interface{}) error {
 	return json.NewDecoder(c.Request.Body).Decode(v)
 }
 
+// Principal returns the authenticated caller of the request, or nil for anonymous requests.
+// When several schemes authenticated the request, the first principal recorded is returned.
+func (c *Context) Principal() Principal {
+	return FirstPrincipal(c.Request.Context())
+}
+
 // Remove method to Params for backtracking
 func (p *Params) Remove(key string) {
 	for i, k := range p.keys {
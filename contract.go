@@ -0,0 +1,538 @@
+package steel
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContractValidationMode selects what happens when a request or response does not match the specification.
+type ContractValidationMode int
+
+const (
+	// ContractModeLogOnly reports violations without changing responses.
+	ContractModeLogOnly ContractValidationMode = iota
+
+	// ContractModeEnforce rejects invalid requests with 400 and replaces invalid responses with 500.
+	ContractModeEnforce
+)
+
+// Where a contract violation was found.
+const (
+	ContractDirectionRequest  = "request"
+	ContractDirectionResponse = "response"
+)
+
+// ContractViolation is a single mismatch between traffic and the OpenAPI specification.
+// In is "path", "query", "header", "body" or "status" and Pointer is a JSON pointer within it.
+type ContractViolation struct {
+	Direction string `json:"direction"`
+	In        string `json:"in"`
+	Pointer   string `json:"pointer"`
+	Message   string `json:"message"`
+}
+
+// ContractReport groups the violations found for one request.
+type ContractReport struct {
+	Method     string              `json:"method"`
+	Path       string              `json:"path"`
+	Operation  string              `json:"operation"`
+	Status     int                 `json:"status,omitempty"`
+	Violations []ContractViolation `json:"violations"`
+}
+
+// ContractValidationConfig configures the contract validation middleware.
+type ContractValidationConfig struct {
+	Mode              ContractValidationMode
+	ValidateRequests  bool
+	ValidateResponses bool
+
+	// SampleRate is the fraction of requests validated, between 0 and 1. Zero validates no request;
+	// DefaultContractValidationConfig validates every request.
+	SampleRate float64
+
+	// MaxBodyBytes limits the request bodies buffered for validation. Larger bodies are rejected with 413.
+	// Zero uses DefaultContractMaxBodyBytes.
+	MaxBodyBytes int64
+
+	// OnViolation receives every report with at least one violation. Defaults to logging the report.
+	OnViolation func(r *http.Request, report ContractReport)
+}
+
+// DefaultContractMaxBodyBytes is the default limit of request bodies buffered for validation.
+const DefaultContractMaxBodyBytes = 10 << 20
+
+// errContractBodyTooLarge is returned when a request body exceeds the validation limit.
+var errContractBodyTooLarge = errors.New("request body too large")
+
+// DefaultContractValidationConfig returns a log-only configuration validating every request and response.
+func DefaultContractValidationConfig() ContractValidationConfig {
+	return ContractValidationConfig{
+		Mode:              ContractModeLogOnly,
+		ValidateRequests:  true,
+		ValidateResponses: true,
+		SampleRate:        1,
+		MaxBodyBytes:      DefaultContractMaxBodyBytes,
+	}
+}
+
+// contractRoute is a documented operation matched by path template segments.
+type contractRoute struct {
+	method    string
+	template  string
+	segments  []string
+	operation OpenAPIOperation
+}
+
+// contractIndex matches requests to the documented operations of a specification.
+type contractIndex struct {
+	routes    []contractRoute
+	validator *schemaValidator
+}
+
+// contractIndexCache holds the index of the router's current OpenAPI document.
+type contractIndexCache struct {
+	router *SteelRouter
+	mu     sync.Mutex
+	doc    *specDocument
+	index  *contractIndex
+}
+
+// current returns the index of the OpenAPI document, rebuilding it when the specification changed.
+// The index is built from the serialized document so it shares no state with the specification.
+func (c *contractIndexCache) current() (*contractIndex, error) {
+	doc, err := c.router.specDocument(specOpenAPI)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.doc != doc {
+		spec, err := LoadOpenAPISpec(doc.bodies[SpecFormatJSON])
+		if err != nil {
+			return nil, err
+		}
+		c.doc, c.index = doc, newContractIndex(spec)
+	}
+	return c.index, nil
+}
+
+// ContractValidation returns a middleware validating requests and responses against the router's OpenAPI specification.
+// Routes registered while serving are validated once documented. Routes without a documented operation,
+// WebSocket upgrades and event streams are not validated.
+func (r *SteelRouter) ContractValidation(config ...ContractValidationConfig) MiddlewareFunc {
+	cfg := DefaultContractValidationConfig()
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.OnViolation == nil {
+		cfg.OnViolation = logContractReport
+	}
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultContractMaxBodyBytes
+	}
+
+	indexes := &contractIndexCache{router: r}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !sampled(cfg.SampleRate) || isStreamingRequest(req) {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			index, err := indexes.current()
+			if err != nil {
+				log.Printf("steel: contract validation skipped: %v", err)
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			route, params := index.match(req.Method, req.URL.Path)
+			if route == nil {
+				next.ServeHTTP(w, req)
+				return
+			}
+
+			report := ContractReport{
+				Method:    req.Method,
+				Path:      req.URL.Path,
+				Operation: route.name(),
+			}
+
+			if cfg.ValidateRequests {
+				report.Violations, err = index.validateRequest(route, req, params, cfg.MaxBodyBytes)
+				if err != nil {
+					r.handleError(w, req, PayloadTooLarge(fmt.Sprintf("Request body exceeds %d bytes", cfg.MaxBodyBytes)))
+					return
+				}
+				if len(report.Violations) > 0 && cfg.Mode == ContractModeEnforce {
+					cfg.OnViolation(req, report)
+					r.handleError(w, req, BadRequest("Request does not match the API contract", report.Violations))
+					return
+				}
+			}
+
+			if !cfg.ValidateResponses {
+				next.ServeHTTP(w, req)
+				if len(report.Violations) > 0 {
+					cfg.OnViolation(req, report)
+				}
+				return
+			}
+
+			recorder := newContractRecorder(w, cfg.Mode == ContractModeEnforce)
+			next.ServeHTTP(recorder, req)
+
+			report.Status = recorder.statusCode()
+			responseViolations := index.validateResponse(route, recorder.statusCode(), recorder.Header(), recorder.body.Bytes())
+			report.Violations = append(report.Violations, responseViolations...)
+			if len(report.Violations) > 0 {
+				cfg.OnViolation(req, report)
+			}
+
+			if !recorder.buffered {
+				return
+			}
+			if len(responseViolations) > 0 {
+				recorder.discard()
+				r.handleError(w, req, InternalServerError("Response does not match the API contract", responseViolations))
+				return
+			}
+			recorder.flush()
+		})
+	}
+}
+
+// logContractReport is the default violation handler.
+func logContractReport(r *http.Request, report ContractReport) {
+	for _, violation := range report.Violations {
+		log.Printf("steel: contract violation in %s %s: %s %s %q: %s",
+			report.Operation, violation.Direction, r.URL.Path, violation.In, violation.Pointer, violation.Message)
+	}
+}
+
+// sampled reports whether a request falls within the sample rate.
+func sampled(rate float64) bool {
+	return rate >= 1 || (rate > 0 && rand.Float64() < rate)
+}
+
+// isStreamingRequest reports whether a request opens a WebSocket or an event stream, whose bodies cannot be validated.
+func isStreamingRequest(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(req.Header.Get("Accept"), "text/event-stream")
+}
+
+// newContractIndex indexes the operations of a specification by method and path template.
+func newContractIndex(spec *OpenAPISpec) *contractIndex {
+	index := &contractIndex{validator: newSchemaValidator(spec.Components.Schemas)}
+
+	for template, pathItem := range spec.Paths {
+		for method, operation := range pathItem {
+			index.routes = append(index.routes, contractRoute{
+				method:    strings.ToUpper(method),
+				template:  template,
+				segments:  strings.Split(strings.Trim(template, "/"), "/"),
+				operation: operation,
+			})
+		}
+	}
+
+	return index
+}
+
+// match finds the operation for a request, preferring templates with more static segments.
+func (index *contractIndex) match(method, path string) (*contractRoute, map[string]string) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	var (
+		best       *contractRoute
+		bestParams map[string]string
+		bestStatic = -1
+	)
+
+	for i := range index.routes {
+		route := &index.routes[i]
+		if route.method != method || len(route.segments) != len(segments) {
+			continue
+		}
+
+		params := make(map[string]string)
+		static := 0
+		matched := true
+		for j, segment := range route.segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				params[segment[1:len(segment)-1]] = segments[j]
+				continue
+			}
+			if segment != segments[j] {
+				matched = false
+				break
+			}
+			static++
+		}
+
+		if matched && static > bestStatic {
+			best, bestParams, bestStatic = route, params, static
+		}
+	}
+
+	return best, bestParams
+}
+
+// name identifies the operation in reports.
+func (route *contractRoute) name() string {
+	if route.operation.OperationID != "" {
+		return route.operation.OperationID
+	}
+	return route.method + " " + route.template
+}
+
+// validateRequest checks parameters and the body of a request. The body is restored for the handler.
+// Bodies over maxBody bytes are not buffered and return errContractBodyTooLarge.
+func (index *contractIndex) validateRequest(route *contractRoute, req *http.Request, params map[string]string, maxBody int64) ([]ContractViolation, error) {
+	var violations []ContractViolation
+	add := func(in string, found []SchemaViolation, prefix string) {
+		for _, violation := range found {
+			violations = append(violations, ContractViolation{
+				Direction: ContractDirectionRequest,
+				In:        in,
+				Pointer:   prefix + violation.Pointer,
+				Message:   violation.Message,
+			})
+		}
+	}
+
+	query := req.URL.Query()
+	for _, parameter := range route.operation.Parameters {
+		var (
+			raw     string
+			present bool
+		)
+		switch parameter.In {
+		case "path":
+			raw, present = params[parameter.Name]
+		case "query":
+			present = query.Has(parameter.Name)
+			raw = query.Get(parameter.Name)
+		case "header":
+			raw = req.Header.Get(parameter.Name)
+			present = raw != ""
+		default:
+			continue
+		}
+
+		pointer := "/" + escapeJSONPointer(parameter.Name)
+		if !present {
+			if parameter.Required {
+				add(parameter.In, []SchemaViolation{{Message: "required parameter is missing"}}, pointer)
+			}
+			continue
+		}
+		add(parameter.In, index.validator.Validate(parameter.Schema, index.validator.coerceParameter(parameter.Schema, raw)), pointer)
+	}
+
+	body := route.operation.RequestBody
+	if body == nil {
+		return violations, nil
+	}
+
+	var data []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		data, _ = io.ReadAll(io.LimitReader(req.Body, maxBody+1))
+		if int64(len(data)) > maxBody {
+			return nil, errContractBodyTooLarge
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(data))
+	}
+
+	if len(data) == 0 {
+		if body.Required {
+			add("body", []SchemaViolation{{Message: "request body is required"}}, "")
+		}
+		return violations, nil
+	}
+
+	media, ok := matchMediaType(body.Content, req.Header.Get("Content-Type"))
+	if !ok {
+		add("header", []SchemaViolation{{Message: "unsupported content type " + req.Header.Get("Content-Type")}}, "/Content-Type")
+		return violations, nil
+	}
+	if media != nil {
+		add("body", index.validateJSON(*media, data), "")
+	}
+
+	return violations, nil
+}
+
+// validateResponse checks the status code and body of a response.
+func (index *contractIndex) validateResponse(route *contractRoute, status int, header http.Header, data []byte) []ContractViolation {
+	report := func(in string, found []SchemaViolation) []ContractViolation {
+		violations := make([]ContractViolation, 0, len(found))
+		for _, violation := range found {
+			violations = append(violations, ContractViolation{
+				Direction: ContractDirectionResponse,
+				In:        in,
+				Pointer:   violation.Pointer,
+				Message:   violation.Message,
+			})
+		}
+		return violations
+	}
+
+	response, ok := documentedResponse(route.operation.Responses, status)
+	if !ok {
+		return report("status", []SchemaViolation{{Message: "status " + strconv.Itoa(status) + " is not documented"}})
+	}
+
+	if len(response.Content) == 0 {
+		if len(data) > 0 {
+			return report("body", []SchemaViolation{{Message: "response body is not documented"}})
+		}
+		return nil
+	}
+
+	media, ok := matchMediaType(response.Content, header.Get("Content-Type"))
+	if !ok {
+		return report("header", []SchemaViolation{{Pointer: "/Content-Type", Message: "undocumented content type " + header.Get("Content-Type")}})
+	}
+	if media == nil {
+		return nil
+	}
+	return report("body", index.validateJSON(*media, data))
+}
+
+// validateJSON decodes and validates a JSON body against a media type schema.
+func (index *contractIndex) validateJSON(media OpenAPIMediaType, data []byte) []SchemaViolation {
+	value, err := decodeJSONValue(data)
+	if err != nil {
+		return []SchemaViolation{{Message: "invalid JSON: " + err.Error()}}
+	}
+	return index.validator.Validate(media.Schema, value)
+}
+
+// documentedResponse finds the response documented for a status code, falling back to ranges such as 2XX and default.
+func documentedResponse(responses map[string]OpenAPIResponse, status int) (OpenAPIResponse, bool) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if response, ok := responses[key]; ok {
+			return response, true
+		}
+	}
+	return OpenAPIResponse{}, false
+}
+
+// matchMediaType finds the documented media type for a content type. It returns a nil media type
+// when the content type is documented but not JSON, since only JSON bodies are validated.
+func matchMediaType(content map[string]OpenAPIMediaType, contentType string) (*OpenAPIMediaType, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "application/json"
+	}
+
+	media, ok := content[mediaType]
+	if !ok {
+		if media, ok = content["*/*"]; !ok {
+			return nil, false
+		}
+	}
+
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return nil, true
+	}
+	return &media, true
+}
+
+// contractRecorder captures a response for validation. Buffered recorders hold the response back
+// so it can be replaced; unbuffered ones write through and keep a copy.
+type contractRecorder struct {
+	http.ResponseWriter
+	buffered    bool
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// newContractRecorder records the response written to w. A buffered recorder keeps the handler's
+// headers apart from w, starting from a copy of the headers already set, until the response is flushed.
+func newContractRecorder(w http.ResponseWriter, buffered bool) *contractRecorder {
+	rec := &contractRecorder{ResponseWriter: w, buffered: buffered}
+	if buffered {
+		rec.header = w.Header().Clone()
+	}
+	return rec
+}
+
+func (rec *contractRecorder) Header() http.Header {
+	if rec.buffered {
+		return rec.header
+	}
+	return rec.ResponseWriter.Header()
+}
+
+func (rec *contractRecorder) WriteHeader(status int) {
+	if rec.wroteHeader {
+		return
+	}
+	rec.status = status
+	rec.wroteHeader = true
+	if !rec.buffered {
+		rec.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (rec *contractRecorder) Write(data []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(data)
+	if rec.buffered {
+		return len(data), nil
+	}
+	return rec.ResponseWriter.Write(data)
+}
+
+func (rec *contractRecorder) statusCode() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
+
+// discard drops a buffered response. Headers set on the underlying writer before the handler ran are kept.
+func (rec *contractRecorder) discard() {
+	rec.header = nil
+	rec.body.Reset()
+}
+
+// flush writes a buffered response, with the headers as the handler left them, to the underlying writer.
+func (rec *contractRecorder) flush() {
+	header := rec.ResponseWriter.Header()
+	for key := range header {
+		if _, ok := rec.header[key]; !ok {
+			delete(header, key)
+		}
+	}
+	for key, values := range rec.header {
+		header[key] = values
+	}
+	rec.ResponseWriter.WriteHeader(rec.statusCode())
+	rec.ResponseWriter.Write(rec.body.Bytes())
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (rec *contractRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
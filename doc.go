@@ -59,6 +59,6 @@ func (r *SteelRouter) addSwaggerUIEndpoint() {
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultOpenAPIJSONPath, r.specPaths().OpenAPIJSON))
 	})
 }
@@ -183,6 +183,23 @@ func Conflict(message string, details ...interface{}) *HTTPError {
 	}
 }
 
+func PayloadTooLarge(message string, details ...interface{}) *HTTPError {
+	if message == "" {
+		message = "Request body too large"
+	}
+	var detail interface{}
+	if len(details) > 0 {
+		detail = details[0]
+	}
+	return &HTTPError{
+		Status:    http.StatusRequestEntityTooLarge,
+		Code:      "PAYLOAD_TOO_LARGE",
+		Message:   message,
+		Detail:    detail,
+		Timestamp: time.Now(),
+	}
+}
+
 func UnprocessableEntity(message string, fields ...FieldError) *ValidationError {
 	if message == "" {
 		message = "Validation failed"
@@ -251,6 +268,23 @@ func ServiceUnavailable(message string, details ...interface{}) *HTTPError {
 	}
 }
 
+func GatewayTimeout(message string, details ...interface{}) *HTTPError {
+	if message == "" {
+		message = "Request timed out"
+	}
+	var detail interface{}
+	if len(details) > 0 {
+		detail = details[0]
+	}
+	return &HTTPError{
+		Status:    http.StatusGatewayTimeout,
+		Code:      "GATEWAY_TIMEOUT",
+		Message:   message,
+		Detail:    detail,
+		Timestamp: time.Now(),
+	}
+}
+
 // Custom error for business logic
 func NewBusinessError(status int, businessCode, message string, context interface{}) *BusinessError {
 	return &BusinessError{
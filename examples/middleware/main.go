@@ -173,7 +173,7 @@ func main() {
 			if len(path) > 10 && path[:10] == "/api/users" {
 				return "/api/users/{id}"
 			}
-			return path
+			return "unmatched"
 		},
 	}))
 
@@ -259,13 +259,7 @@ func main() {
 	})
 
 	// Metrics endpoint
-	router.GET("/metrics", func(w http.ResponseWriter, r *http.Request) {
-		stats := metrics.GetStats()
-		w.Header().Set("Content-Type", "application/json")
-
-		// Convert to JSON manually for simplicity
-		w.Write([]byte(`{"metrics": "available", "endpoints": ` + fmt.Sprintf("%d", len(stats)) + `}`))
-	})
+	router.GET("/metrics", metrics.Handler().ServeHTTP)
 
 	// ==========================================================================
 	// Authentication Routes
@@ -153,36 +153,10 @@ func main() {
 			},
 		})
 
-		// Keep connection alive and send periodic heartbeats
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-
-		// Listen for connection close
-		done := make(chan bool)
-		go func() {
-			select {
-			case <-conn.Request().Context().Done():
-				done <- true
-			}
-		}()
-
-		for {
-			select {
-			case <-ticker.C:
-				if conn.IsClosed() {
-					return nil
-				}
-				conn.SendMessage(steel.SSEMessage{
-					Event: "heartbeat",
-					Data: map[string]interface{}{
-						"timestamp": time.Now(),
-					},
-				})
-			case <-done:
-				log.Printf("SSE connection closed for user %s", params.UserID)
-				return nil
-			}
-		}
+		// Keep the stream open until the client disconnects; ": ping" heartbeats are sent automatically
+		<-conn.Done()
+		log.Printf("SSE connection closed for user %s", params.UserID)
+		return nil
 	},
 		steel.WithAsyncSummary("Real-time notifications"),
 		steel.WithAsyncDescription("Server-sent events for real-time notifications"),
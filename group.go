@@ -9,6 +9,7 @@ type RouteGroup struct {
 	router     *SteelRouter
 	prefix     string
 	middleware []MiddlewareFunc
+	rateLimit  HandlerOption
 }
 
 // Use RouteGroup implementation
@@ -22,6 +23,7 @@ func (g *RouteGroup) Group() Router {
 		router:     g.router,
 		prefix:     g.prefix,
 		middleware: append([]MiddlewareFunc{}, g.middleware...),
+		rateLimit:  g.rateLimit,
 	}
 }
 
@@ -31,6 +33,7 @@ func (g *RouteGroup) GroupFunc(fn func(r Router)) Router {
 		router:     g.router,
 		prefix:     g.prefix,
 		middleware: append([]MiddlewareFunc{}, g.middleware...),
+		rateLimit:  g.rateLimit,
 	}
 	fn(subgroup)
 	return subgroup
@@ -41,6 +44,7 @@ func (g *RouteGroup) Route(pattern string, fn func(r Router)) Router {
 		router:     g.router,
 		prefix:     g.prefix + pattern,
 		middleware: append([]MiddlewareFunc{}, g.middleware...),
+		rateLimit:  g.rateLimit,
 	}
 	fn(subgroup)
 	return subgroup
@@ -100,34 +104,51 @@ func (g *RouteGroup) HandleFunc(method, pattern string, handler http.HandlerFunc
 	})
 }
 
+// OpinionatedGET registers an opinionated GET route under the group's prefix. Like routes registered on
+// the router, it runs the router's opinionated middleware, including middleware added with UseOpinionated
+// on any group, and the group's rate limit policy.
 func (g *RouteGroup) OpinionatedGET(pattern string, handler interface{}, opts ...HandlerOption) {
-	g.router.registerOpinionatedHandler("GET", g.prefix+pattern, handler, opts...)
+	g.router.registerOpinionatedHandlerWithMiddleware("GET", g.prefix+pattern, handler, g.handlerOptions(opts)...)
 }
 
 func (g *RouteGroup) OpinionatedPOST(pattern string, handler interface{}, opts ...HandlerOption) {
-	g.router.registerOpinionatedHandler("POST", g.prefix+pattern, handler, opts...)
+	g.router.registerOpinionatedHandlerWithMiddleware("POST", g.prefix+pattern, handler, g.handlerOptions(opts)...)
 }
 
 func (g *RouteGroup) OpinionatedPUT(pattern string, handler interface{}, opts ...HandlerOption) {
-	g.router.registerOpinionatedHandler("PUT", g.prefix+pattern, handler, opts...)
+	g.router.registerOpinionatedHandlerWithMiddleware("PUT", g.prefix+pattern, handler, g.handlerOptions(opts)...)
 }
 
 func (g *RouteGroup) OpinionatedDELETE(pattern string, handler interface{}, opts ...HandlerOption) {
-	g.router.registerOpinionatedHandler("DELETE", g.prefix+pattern, handler, opts...)
+	g.router.registerOpinionatedHandlerWithMiddleware("DELETE", g.prefix+pattern, handler, g.handlerOptions(opts)...)
 }
 
 func (g *RouteGroup) OpinionatedPATCH(pattern string, handler interface{}, opts ...HandlerOption) {
-	g.router.registerOpinionatedHandler("PATCH", g.prefix+pattern, handler, opts...)
+	g.router.registerOpinionatedHandlerWithMiddleware("PATCH", g.prefix+pattern, handler, g.handlerOptions(opts)...)
+}
+
+// handlerOptions applies the group's rate limit policy before the options of a route
+func (g *RouteGroup) handlerOptions(opts []HandlerOption) []HandlerOption {
+	if g.rateLimit == nil {
+		return opts
+	}
+	return append([]HandlerOption{g.rateLimit}, opts...)
 }
 
 func (g *RouteGroup) WebSocket(pattern string, handler interface{}, opts ...AsyncHandlerOption) {
 	g.router.WebSocket(g.prefix+pattern, handler, opts...)
 }
 
+func (g *RouteGroup) JSONRPC(pattern string, opts ...AsyncHandlerOption) *JSONRPCEndpoint {
+	return g.router.JSONRPC(g.prefix+pattern, opts...)
+}
+
 func (g *RouteGroup) SSE(pattern string, handler interface{}, opts ...AsyncHandlerOption) {
 	g.router.SSE(g.prefix+pattern, handler, opts...)
 }
 
+// UseOpinionated adds opinionated middleware to the router's chain, which every opinionated route runs,
+// whether it is registered on a group or not.
 func (g *RouteGroup) UseOpinionated(middleware ...OpinionatedMiddleware) {
 	g.router.UseOpinionated(middleware...)
 }
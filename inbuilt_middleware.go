@@ -1,41 +1,87 @@
 package steel
 
 import (
-	"context"
+	"bufio"
+	"net"
 	"net/http"
 	"time"
 )
 
-// Logger Built-in middleware
+// Logger Built-in middleware, logging each request to its request-scoped logger, see RequestLogger
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
 		next.ServeHTTP(w, r)
-		duration := time.Since(start)
-		println(r.Method, r.URL.Path, duration.String())
+		RequestLogger(r).Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"duration", time.Since(start),
+		)
 	})
 }
 
+// Recoverer recovers panics of the handlers and middleware after it, reporting them with the
+// stack trace (see SteelRouter.OnPanic) and responding with the same JSON error as a handler
+// returning an error. When the response was already started only the report is made.
+// http.ErrAbortHandler panics are left to net/http.
 func Recoverer(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &recoveryResponseWriter{ResponseWriter: w}
+
 		defer func() {
-			if err := recover(); err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				w.Write([]byte("Internal Server Error"))
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			// Outside of a SteelRouter router is nil, which still reports and responds
+			router := ParamsFromContext(r.Context()).router
+			panicErr := router.reportPanic(r, value)
+			if recorder.started {
+				recordSpanError(r, panicErr, http.StatusInternalServerError)
+				return
 			}
+			router.handleError(w, r, panicErr)
 		}()
-		next.ServeHTTP(w, r)
+
+		next.ServeHTTP(recorder, r)
 	})
 }
 
-func Timeout(timeout time.Duration) func(next http.Handler) http.Handler {
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx, cancel := context.WithTimeout(r.Context(), timeout)
-			defer cancel()
+// recoveryResponseWriter records whether a response was started, keeping the flushing and
+// hijacking that SSE and WebSocket endpoints need
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
 
-			r = r.WithContext(ctx)
-			next.ServeHTTP(w, r)
-		})
+func (w *recoveryResponseWriter) WriteHeader(status int) {
+	// Informational responses do not start the final response
+	if status >= http.StatusOK || status == http.StatusSwitchingProtocols {
+		w.started = true
 	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recoveryResponseWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recoveryResponseWriter) Flush() {
+	w.started = true
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *recoveryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.started = true
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
 }
@@ -0,0 +1,71 @@
+package steel
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+type loggerKeyType struct{}
+type requestIDKeyType struct{}
+
+var (
+	loggerKey    = loggerKeyType{}
+	requestIDKey = requestIDKeyType{}
+)
+
+// WithLogger returns a copy of ctx carrying the logger of a request, see RequestLogger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey, logger)
+}
+
+// LoggerFromContext returns the logger carried by ctx, or slog.Default()
+func LoggerFromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of a request, for request ID middleware
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, or an empty string
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// RequestLogger returns the logger of a request, see WithLogger, with the request ID, route pattern,
+// principal and trace of the request as attributes. Attributes unknown at the time are left out,
+// so call it again after authentication rather than keeping the logger.
+func RequestLogger(req *http.Request) *slog.Logger {
+	ctx := req.Context()
+	attrs := make([]any, 0, 10)
+
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		id = req.Header.Get("X-Request-ID")
+	}
+	if id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if route := RoutePattern(req); route != "" {
+		attrs = append(attrs, slog.String("route", route))
+	}
+	if principal := principalID(ctx); principal != "" {
+		attrs = append(attrs, slog.String("principal", principal))
+	}
+	if sc := SpanFromContext(ctx).Context(); sc.IsValid() {
+		attrs = append(attrs, slog.String("trace_id", sc.TraceID.String()), slog.String("span_id", sc.SpanID.String()))
+	}
+
+	return LoggerFromContext(ctx).With(attrs...)
+}
+
+// Logger returns the request-scoped logger, see RequestLogger
+func (c *Context) Logger() *slog.Logger {
+	return RequestLogger(c.Request)
+}
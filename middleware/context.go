@@ -4,23 +4,33 @@ import (
 	"net/http"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/xraph/steel"
 )
 
 // =============================================================================
 // Context Helpers
 // =============================================================================
 
+// contextKey is the type of the request context keys set by this package
+type contextKey string
+
+const (
+	requestIDKey contextKey = "request_id"
+	jwtTokenKey  contextKey = "jwt_token"
+	jwtClaimsKey contextKey = "jwt_claims"
+)
+
 // GetRequestID retrieves request ID from context
 func GetRequestID(r *http.Request) string {
-	if id, ok := r.Context().Value("request_id").(string); ok {
+	if id, ok := r.Context().Value(requestIDKey).(string); ok {
 		return id
 	}
-	return ""
+	return steel.RequestIDFromContext(r.Context())
 }
 
 // GetJWTToken retrieves JWT token from context
 func GetJWTToken(r *http.Request) *jwt.Token {
-	if token, ok := r.Context().Value("jwt_token").(*jwt.Token); ok {
+	if token, ok := r.Context().Value(jwtTokenKey).(*jwt.Token); ok {
 		return token
 	}
 	return nil
@@ -28,31 +38,19 @@ func GetJWTToken(r *http.Request) *jwt.Token {
 
 // GetJWTClaims retrieves JWT claims from context
 func GetJWTClaims(r *http.Request) jwt.Claims {
-	if claims, ok := r.Context().Value("jwt_claims").(jwt.Claims); ok {
+	if claims, ok := r.Context().Value(jwtClaimsKey).(jwt.Claims); ok {
 		return claims
 	}
 	return nil
 }
 
+// GetTypedJWTClaims retrieves JWT claims of the type configured in JWTConfig.Claims
+func GetTypedJWTClaims[T jwt.Claims](r *http.Request) (T, bool) {
+	claims, ok := GetJWTClaims(r).(T)
+	return claims, ok
+}
+
 // GetUserID helper to extract user ID from JWT claims
 func GetUserID(r *http.Request) string {
-	claims := GetJWTClaims(r)
-	if claims == nil {
-		return ""
-	}
-
-	if mapClaims, ok := claims.(jwt.MapClaims); ok {
-		if userID, exists := mapClaims["user_id"]; exists {
-			if id, ok := userID.(string); ok {
-				return id
-			}
-		}
-		if sub, exists := mapClaims["sub"]; exists {
-			if id, ok := sub.(string); ok {
-				return id
-			}
-		}
-	}
-
-	return ""
+	return jwtUserID(GetJWTClaims(r))
 }
@@ -7,18 +7,23 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"math/rand/v2"
+	"mime"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	json "github.com/json-iterator/go"
 	"github.com/xraph/steel"
-	"golang.org/x/time/rate"
 )
 
 // Logger Built-in middleware
@@ -189,71 +194,23 @@ type RateLimitConfig struct {
 	OnLimitReached    func(w http.ResponseWriter, r *http.Request)
 	SkipFunc          func(*http.Request) bool
 	Store             RateLimitStore
+	// Rules apply their own limits to the requests they match instead of Store, the first match wins
+	Rules []RateLimitRule
+	// Cost returns the units a request spends, 1 when nil
+	Cost func(*http.Request) int
+	// PolicyStore creates the store of a policy declared on routes with steel.WithRateLimit,
+	// a GCRA store when nil
+	PolicyStore func(policy steel.RateLimitPolicy) RateLimitStore
 }
 
-type RateLimitStore interface {
-	GetLimiter(key string) *rate.Limiter
-	CleanupExpired()
-}
-
-type inMemoryRateLimitStore struct {
-	limiters map[string]*rateLimiterEntry
-	mu       sync.RWMutex
-	rps      rate.Limit
-	burst    int
-}
-
-type rateLimiterEntry struct {
-	limiter  *rate.Limiter
-	lastSeen time.Time
-}
-
-func NewInMemoryRateLimitStore(rps float64, burst int) RateLimitStore {
-	store := &inMemoryRateLimitStore{
-		limiters: make(map[string]*rateLimiterEntry),
-		rps:      rate.Limit(rps),
-		burst:    burst,
-	}
-
-	// Start cleanup goroutine
-	go func() {
-		ticker := time.NewTicker(time.Minute)
-		defer ticker.Stop()
-		for range ticker.C {
-			store.CleanupExpired()
-		}
-	}()
-
-	return store
-}
-
-func (s *inMemoryRateLimitStore) GetLimiter(key string) *rate.Limiter {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	entry, exists := s.limiters[key]
-	if !exists {
-		entry = &rateLimiterEntry{
-			limiter:  rate.NewLimiter(s.rps, s.burst),
-			lastSeen: time.Now(),
-		}
-		s.limiters[key] = entry
-	}
-
-	entry.lastSeen = time.Now()
-	return entry.limiter
-}
-
-func (s *inMemoryRateLimitStore) CleanupExpired() {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	expiry := time.Now().Add(-time.Hour) // Remove entries older than 1 hour
-	for key, entry := range s.limiters {
-		if entry.lastSeen.Before(expiry) {
-			delete(s.limiters, key)
-		}
-	}
+// RateLimitRule limits the requests it matches, such as the requests to a route or of principals with a role.
+// Unlike a steel.RateLimitPolicy, which declares a limit, a rule binds a store to the requests it matches.
+type RateLimitRule struct {
+	// Name prefixes the keys of the rule, so rules sharing a store are counted apart
+	Name    string
+	Match   func(*http.Request) bool
+	KeyFunc func(*http.Request) string // Defaults to RateLimitConfig.KeyFunc
+	Store   RateLimitStore
 }
 
 func defaultRateLimitKeyFunc(r *http.Request) string {
@@ -274,7 +231,17 @@ func defaultRateLimitKeyFunc(r *http.Request) string {
 	return host
 }
 
-func RateLimit(config RateLimitConfig) steel.MiddlewareFunc {
+// PrincipalRateLimitKey keys limits by the authenticated principal, see steel.Authentication,
+// so each user or API client gets its own limit. Anonymous requests are keyed by client IP.
+func PrincipalRateLimitKey(r *http.Request) string {
+	if principal := steel.FirstPrincipal(r.Context()); principal != nil {
+		return "principal:" + principal.ID()
+	}
+	return defaultRateLimitKeyFunc(r)
+}
+
+// prepareRateLimitConfig fills in defaults
+func prepareRateLimitConfig(config RateLimitConfig) RateLimitConfig {
 	if config.RequestsPerSecond <= 0 {
 		config.RequestsPerSecond = 10 // Default 10 RPS
 	}
@@ -287,6 +254,97 @@ func RateLimit(config RateLimitConfig) steel.MiddlewareFunc {
 	if config.Store == nil {
 		config.Store = NewInMemoryRateLimitStore(config.RequestsPerSecond, config.BurstSize)
 	}
+	if config.PolicyStore == nil {
+		config.PolicyStore = func(policy steel.RateLimitPolicy) RateLimitStore {
+			return NewGCRARateLimitStore(policy.Limit, policy.Period, policy.Burst)
+		}
+	}
+	return config
+}
+
+// declaredRateLimits holds the stores of the policies declared on routes, created on first use
+type declaredRateLimits struct {
+	mu       sync.Mutex
+	stores   map[string]RateLimitStore
+	newStore func(policy steel.RateLimitPolicy) RateLimitStore
+}
+
+func newDeclaredRateLimits(config RateLimitConfig) *declaredRateLimits {
+	return &declaredRateLimits{
+		stores:   make(map[string]RateLimitStore),
+		newStore: config.PolicyStore,
+	}
+}
+
+// allow spends the cost of a request from the policy declared on its route, or from the
+// configured rules when the route declares none
+func (d *declaredRateLimits) allow(r *http.Request, info *steel.HandlerInfo, config RateLimitConfig) (RateLimitDecision, error) {
+	if info == nil || info.RateLimit == nil {
+		return allowRequest(r, config)
+	}
+
+	key := info.RateLimit.Key(info)
+	return d.store(key, *info.RateLimit).Allow(key+":"+config.KeyFunc(r), requestCost(r, config))
+}
+
+func (d *declaredRateLimits) store(key string, policy steel.RateLimitPolicy) RateLimitStore {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	store, exists := d.stores[key]
+	if !exists {
+		store = d.newStore(policy)
+		d.stores[key] = store
+	}
+	return store
+}
+
+// requestCost returns the units a request spends
+func requestCost(r *http.Request, config RateLimitConfig) int {
+	if config.Cost != nil {
+		return max(1, config.Cost(r))
+	}
+	return 1
+}
+
+// allowRequest spends the cost of a request from the first matching rule, or from the default store
+func allowRequest(r *http.Request, config RateLimitConfig) (RateLimitDecision, error) {
+	cost := requestCost(r, config)
+
+	for _, rule := range config.Rules {
+		if rule.Match != nil && !rule.Match(r) {
+			continue
+		}
+		keyFunc := rule.KeyFunc
+		if keyFunc == nil {
+			keyFunc = config.KeyFunc
+		}
+		return rule.Store.Allow(rule.Name+":"+keyFunc(r), cost)
+	}
+
+	return config.Store.Allow(config.KeyFunc(r), cost)
+}
+
+// setRateLimitHeaders sets the RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers,
+// and Retry-After when the request is rejected
+func setRateLimitHeaders(header http.Header, decision RateLimitDecision) {
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.ResetAfter)))
+	if !decision.Allowed {
+		header.Set("Retry-After", strconv.Itoa(max(1, ceilSeconds(decision.RetryAfter))))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
+
+// RateLimit limits requests by the policy their route declares with steel.WithRateLimit, or by
+// the configured policies and store when it declares none
+func RateLimit(config RateLimitConfig) steel.MiddlewareFunc {
+	config = prepareRateLimitConfig(config)
+	declared := newDeclaredRateLimits(config)
 	if config.OnLimitReached == nil {
 		config.OnLimitReached = func(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
@@ -300,10 +358,16 @@ func RateLimit(config RateLimitConfig) steel.MiddlewareFunc {
 				return
 			}
 
-			key := config.KeyFunc(r)
-			limiter := config.Store.GetLimiter(key)
+			decision, err := declared.allow(r, steel.ParamsFromContext(r.Context()).HandlerInfo(), config)
+			if err != nil {
+				// Fail open so an unavailable store does not take the API down
+				log.Printf("rate limit: %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
 
-			if !limiter.Allow() {
+			setRateLimitHeaders(w.Header(), decision)
+			if !decision.Allowed {
 				config.OnLimitReached(w, r)
 				return
 			}
@@ -313,20 +377,10 @@ func RateLimit(config RateLimitConfig) steel.MiddlewareFunc {
 	}
 }
 
-// Opinionated Rate Limiting middleware
+// OpinionatedRateLimit limits requests like RateLimit, failing them with a structured 429 error
 func OpinionatedRateLimit(config RateLimitConfig) steel.OpinionatedMiddleware {
-	if config.RequestsPerSecond <= 0 {
-		config.RequestsPerSecond = 10
-	}
-	if config.BurstSize <= 0 {
-		config.BurstSize = int(config.RequestsPerSecond) * 2
-	}
-	if config.KeyFunc == nil {
-		config.KeyFunc = defaultRateLimitKeyFunc
-	}
-	if config.Store == nil {
-		config.Store = NewInMemoryRateLimitStore(config.RequestsPerSecond, config.BurstSize)
-	}
+	config = prepareRateLimitConfig(config)
+	declared := newDeclaredRateLimits(config)
 
 	return steel.NewMiddleware("rate_limit").
 		Description("Rate limiting middleware to prevent abuse").
@@ -335,11 +389,16 @@ func OpinionatedRateLimit(config RateLimitConfig) steel.OpinionatedMiddleware {
 				return nil
 			}
 
-			key := config.KeyFunc(ctx.Request)
-			limiter := config.Store.GetLimiter(key)
+			decision, err := declared.allow(ctx.Request, ctx.HandlerInfo, config)
+			if err != nil {
+				// Fail open so an unavailable store does not take the API down
+				log.Printf("rate limit: %v", err)
+				return nil
+			}
 
-			if !limiter.Allow() {
-				return steel.TooManyRequests("Rate limit exceeded")
+			setRateLimitHeaders(ctx.Response.Header(), decision)
+			if !decision.Allowed {
+				return steel.TooManyRequests("Rate limit exceeded", max(1, ceilSeconds(decision.RetryAfter)))
 			}
 
 			return nil
@@ -381,8 +440,8 @@ func RequestID(config ...RequestIDConfig) steel.MiddlewareFunc {
 			}
 
 			// Set request ID in context
-			ctx := context.WithValue(r.Context(), "request_id", requestID)
-			r = r.WithContext(ctx)
+			ctx := context.WithValue(r.Context(), requestIDKey, requestID)
+			r = r.WithContext(steel.WithRequestID(ctx, requestID))
 
 			// Set response header
 			w.Header().Set(cfg.HeaderName, requestID)
@@ -417,8 +476,8 @@ func OpinionatedRequestID(config ...RequestIDConfig) steel.OpinionatedMiddleware
 			ctx.Metadata["request_id"] = requestID
 
 			// Set in request context
-			reqCtx := context.WithValue(ctx.Request.Context(), "request_id", requestID)
-			ctx.Request = ctx.Request.WithContext(reqCtx)
+			reqCtx := context.WithValue(ctx.Request.Context(), requestIDKey, requestID)
+			ctx.Request = ctx.Request.WithContext(steel.WithRequestID(reqCtx, requestID))
 
 			// Set response header
 			ctx.Response.Header().Set(cfg.HeaderName, requestID)
@@ -435,14 +494,28 @@ func OpinionatedRequestID(config ...RequestIDConfig) steel.OpinionatedMiddleware
 // =============================================================================
 
 type JWTConfig struct {
-	SigningKey     interface{}
-	SigningMethod  jwt.SigningMethod
-	TokenLookup    string // "header:Authorization" or "query:token" or "cookie:jwt"
+	SigningKey    interface{}
+	SigningMethod jwt.SigningMethod
+	// KeyFunc resolves the verification key per token instead of SigningKey. ValidMethods must be set with it.
+	KeyFunc jwt.Keyfunc
+	// JWKS verifies tokens against a JSON Web Key Set, fetching keys with the request context
+	JWKS *JWKS
+	// ValidMethods lists the accepted "alg" values when KeyFunc or JWKS is set.
+	// With JWKS it defaults to the algorithms of the key types a key set can hold.
+	ValidMethods   []string
+	Issuer         string
+	Audience       string
+	Leeway         time.Duration // Clock skew tolerated when validating exp, nbf and iat
+	TokenLookup    string        // "header:Authorization" or "query:token" or "cookie:jwt"
 	AuthScheme     string
 	SkipFunc       func(*http.Request) bool
 	ErrorHandler   func(w http.ResponseWriter, r *http.Request, err error)
 	SuccessHandler func(w http.ResponseWriter, r *http.Request, token *jwt.Token)
-	Claims         jwt.Claims
+	// Claims is the claims type tokens are decoded into, copied for each request.
+	// Claims implementing steel.Principal are used as the principal of the request.
+	Claims jwt.Claims
+	// SchemeName is the security scheme the principal is recorded under for authorization
+	SchemeName string
 }
 
 func DefaultJWTConfig() JWTConfig {
@@ -451,9 +524,147 @@ func DefaultJWTConfig() JWTConfig {
 		TokenLookup:   "header:Authorization",
 		AuthScheme:    "Bearer",
 		Claims:        jwt.MapClaims{},
+		SchemeName:    "JWTAuth",
 	}
 }
 
+// prepareJWTConfig validates the configuration and fills in defaults
+func prepareJWTConfig(config JWTConfig) JWTConfig {
+	if config.SigningKey == nil && config.KeyFunc == nil && config.JWKS == nil {
+		panic("JWT middleware requires signing key, key func or JWKS")
+	}
+	if config.KeyFunc != nil && len(config.ValidMethods) == 0 {
+		panic("JWT middleware requires ValidMethods with a key func")
+	}
+	if config.JWKS != nil && len(config.ValidMethods) == 0 {
+		config.ValidMethods = jwksValidMethods
+	}
+	if config.SigningMethod == nil && config.KeyFunc == nil && config.JWKS == nil {
+		config.SigningMethod = jwt.SigningMethodHS256
+	}
+	if config.TokenLookup == "" {
+		config.TokenLookup = "header:Authorization"
+	}
+	if config.AuthScheme == "" {
+		config.AuthScheme = "Bearer"
+	}
+	if config.SchemeName == "" {
+		config.SchemeName = "JWTAuth"
+	}
+	return config
+}
+
+// parseJWT verifies a token and validates its registered claims. Keys are fetched with ctx.
+func parseJWT(ctx context.Context, tokenString string, config JWTConfig) (*jwt.Token, error) {
+	options := []jwt.ParserOption{jwt.WithLeeway(config.Leeway)}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+
+	keyFunc := config.KeyFunc
+	switch {
+	case config.JWKS != nil:
+		options = append(options, jwt.WithValidMethods(config.ValidMethods))
+		keyFunc = config.JWKS.KeyFuncContext(ctx)
+	case keyFunc != nil:
+		options = append(options, jwt.WithValidMethods(config.ValidMethods))
+	default:
+		options = append(options, jwt.WithValidMethods([]string{config.SigningMethod.Alg()}))
+		keyFunc = func(token *jwt.Token) (interface{}, error) {
+			return config.SigningKey, nil
+		}
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, newJWTClaims(config.Claims), keyFunc, options...)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return token, nil
+}
+
+// newJWTClaims returns an empty value of the configured claims type, so requests never share claims
+func newJWTClaims(prototype jwt.Claims) jwt.Claims {
+	switch prototype.(type) {
+	case nil, jwt.MapClaims:
+		return jwt.MapClaims{}
+	}
+	if t := reflect.TypeOf(prototype); t.Kind() == reflect.Ptr {
+		return reflect.New(t.Elem()).Interface().(jwt.Claims)
+	}
+	return prototype
+}
+
+// JWTPrincipal is the principal of a verified token. It reads scopes from the
+// "scope" or "scp" claim and roles from the "roles" claim of map claims.
+type JWTPrincipal struct {
+	Token *jwt.Token
+}
+
+// newJWTPrincipal returns the claims of a token when they implement steel.Principal, or a JWTPrincipal
+func newJWTPrincipal(token *jwt.Token) steel.Principal {
+	if principal, ok := token.Claims.(steel.Principal); ok {
+		return principal
+	}
+	return &JWTPrincipal{Token: token}
+}
+
+func (p *JWTPrincipal) ID() string {
+	return jwtUserID(p.Token.Claims)
+}
+
+func (p *JWTPrincipal) Scopes() []string {
+	if scopes := claimStrings(p.Token.Claims, "scope"); len(scopes) > 0 {
+		return scopes
+	}
+	return claimStrings(p.Token.Claims, "scp")
+}
+
+func (p *JWTPrincipal) Roles() []string {
+	return claimStrings(p.Token.Claims, "roles")
+}
+
+// claimStrings reads a space separated string or string array claim of map claims
+func claimStrings(claims jwt.Claims, name string) []string {
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+
+	switch value := mapClaims[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// jwtUserID returns the user_id claim of map claims, or the subject
+func jwtUserID(claims jwt.Claims) string {
+	if claims == nil {
+		return ""
+	}
+	if mapClaims, ok := claims.(jwt.MapClaims); ok {
+		if id, ok := mapClaims["user_id"].(string); ok {
+			return id
+		}
+	}
+	subject, _ := claims.GetSubject()
+	return subject
+}
+
 func extractTokenFromRequest(r *http.Request, config JWTConfig) (string, error) {
 	parts := strings.Split(config.TokenLookup, ":")
 	if len(parts) != 2 {
@@ -496,18 +707,7 @@ func extractTokenFromRequest(r *http.Request, config JWTConfig) (string, error)
 }
 
 func JWT(config JWTConfig) steel.MiddlewareFunc {
-	if config.SigningKey == nil {
-		panic("JWT middleware requires signing key")
-	}
-	if config.SigningMethod == nil {
-		config.SigningMethod = jwt.SigningMethodHS256
-	}
-	if config.TokenLookup == "" {
-		config.TokenLookup = "header:Authorization"
-	}
-	if config.AuthScheme == "" {
-		config.AuthScheme = "Bearer"
-	}
+	config = prepareJWTConfig(config)
 	if config.ErrorHandler == nil {
 		config.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 			http.Error(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
@@ -527,26 +727,16 @@ func JWT(config JWTConfig) steel.MiddlewareFunc {
 				return
 			}
 
-			token, err := jwt.ParseWithClaims(tokenString, config.Claims, func(token *jwt.Token) (interface{}, error) {
-				if token.Method != config.SigningMethod {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return config.SigningKey, nil
-			})
-
+			token, err := parseJWT(r.Context(), tokenString, config)
 			if err != nil {
 				config.ErrorHandler(w, r, err)
 				return
 			}
 
-			if !token.Valid {
-				config.ErrorHandler(w, r, fmt.Errorf("invalid token"))
-				return
-			}
-
 			// Store token in context
-			ctx := context.WithValue(r.Context(), "jwt_token", token)
-			ctx = context.WithValue(ctx, "jwt_claims", token.Claims)
+			ctx := context.WithValue(r.Context(), jwtTokenKey, token)
+			ctx = context.WithValue(ctx, jwtClaimsKey, token.Claims)
+			ctx = steel.WithPrincipal(ctx, config.SchemeName, newJWTPrincipal(token))
 			r = r.WithContext(ctx)
 
 			if config.SuccessHandler != nil {
@@ -560,18 +750,7 @@ func JWT(config JWTConfig) steel.MiddlewareFunc {
 
 // Opinionated JWT middleware
 func OpinionatedJWT(config JWTConfig) steel.OpinionatedMiddleware {
-	if config.SigningKey == nil {
-		panic("JWT middleware requires signing key")
-	}
-	if config.SigningMethod == nil {
-		config.SigningMethod = jwt.SigningMethodHS256
-	}
-	if config.TokenLookup == "" {
-		config.TokenLookup = "header:Authorization"
-	}
-	if config.AuthScheme == "" {
-		config.AuthScheme = "Bearer"
-	}
+	config = prepareJWTConfig(config)
 
 	return steel.NewMiddleware("jwt_auth").
 		Description("JWT token authentication middleware").
@@ -585,26 +764,19 @@ func OpinionatedJWT(config JWTConfig) steel.OpinionatedMiddleware {
 				return steel.Unauthorized("Missing or invalid authorization token: " + err.Error())
 			}
 
-			token, err := jwt.ParseWithClaims(tokenString, config.Claims, func(token *jwt.Token) (interface{}, error) {
-				if token.Method != config.SigningMethod {
-					return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-				}
-				return config.SigningKey, nil
-			})
-
+			token, err := parseJWT(ctx.Request.Context(), tokenString, config)
 			if err != nil {
 				return steel.Unauthorized("Invalid token: " + err.Error())
 			}
 
-			if !token.Valid {
-				return steel.Unauthorized("Token is not valid")
-			}
-
 			// Store token in context and middleware context
-			reqCtx := context.WithValue(ctx.Request.Context(), "jwt_token", token)
-			reqCtx = context.WithValue(reqCtx, "jwt_claims", token.Claims)
+			reqCtx := context.WithValue(ctx.Request.Context(), jwtTokenKey, token)
+			reqCtx = context.WithValue(reqCtx, jwtClaimsKey, token.Claims)
+			principal := newJWTPrincipal(token)
+			reqCtx = steel.WithPrincipal(reqCtx, config.SchemeName, principal)
 			ctx.Request = ctx.Request.WithContext(reqCtx)
 
+			ctx.UserID = principal.ID()
 			ctx.Metadata["jwt_token"] = token
 			ctx.Metadata["jwt_claims"] = token.Claims
 
@@ -615,12 +787,41 @@ func OpinionatedJWT(config JWTConfig) steel.OpinionatedMiddleware {
 			return nil
 		}).
 		RequiresAuth().
-		AddSecurityRequirement(steel.RequireBearer("JWTAuth")).
+		AddSecurityRequirement(steel.RequireBearer(config.SchemeName)).
 		AddResponse("401", "Unauthorized - Invalid or missing JWT token").
 		AddHeader("Authorization", "JWT Bearer token", true).
 		Build()
 }
 
+type jwtAuthenticator struct {
+	config JWTConfig
+}
+
+// JWTAuthenticator authenticates bearer tokens for the named security scheme, for use with
+// SteelRouter.Authentication. Requests without a token are left anonymous.
+func JWTAuthenticator(scheme string, config JWTConfig) steel.Authenticator {
+	config.SchemeName = scheme
+	return &jwtAuthenticator{config: prepareJWTConfig(config)}
+}
+
+func (a *jwtAuthenticator) Scheme() string {
+	return a.config.SchemeName
+}
+
+func (a *jwtAuthenticator) Authenticate(req *http.Request, scheme steel.OpenAPISecurityScheme) (steel.Principal, error) {
+	tokenString, err := extractTokenFromRequest(req, a.config)
+	if err != nil {
+		// No token, or credentials of another scheme in the same header
+		return nil, nil
+	}
+
+	token, err := parseJWT(req.Context(), tokenString, a.config)
+	if err != nil {
+		return nil, err
+	}
+	return newJWTPrincipal(token), nil
+}
+
 // =============================================================================
 // Compression Middleware
 // =============================================================================
@@ -811,15 +1012,44 @@ func SecureHeaders(config ...SecurityConfig) steel.MiddlewareFunc {
 // Request Logging Middleware
 // =============================================================================
 
+// LoggingConfig configures request logging. Entries go to Slog, or to the request's logger
+// (see steel.LoggerFromContext) when neither Slog nor Logger is set.
 type LoggingConfig struct {
-	Logger       *log.Logger
+	// Logger writes entries in Format as text lines instead of slog entries, as JSON when Format is empty
+	Logger *log.Logger
+	// Slog receives the entries and becomes the request-scoped logger of the request, see steel.RequestLogger
+	Slog         *slog.Logger
 	Skip         func(*http.Request) bool
 	Format       string
 	TimeFormat   string
 	UTC          bool
 	CustomFields map[string]func(*http.Request, time.Duration) interface{}
+
+	// Levels maps status classes (2 for 2xx and so on) to entry levels, by default
+	// Warn for 4xx, Error for 5xx and Info otherwise
+	Levels map[int]slog.Level
+	// SampleRate is the fraction of entries below Warn that are logged, all of them when zero
+	SampleRate float64
+
+	// LogHeaders logs the request headers, with RedactHeaders replaced by "[REDACTED]"
+	LogHeaders    bool
+	RedactHeaders []string
+	// LogBody logs JSON and form request bodies of up to MaxBodySize bytes, with the values of
+	// RedactFields replaced by "[REDACTED]". Larger bodies are logged as "[TRUNCATED]" and bodies
+	// that cannot be parsed as "[UNPARSEABLE]", with their size in body_size.
+	LogBody      bool
+	MaxBodySize  int64
+	RedactFields []string
 }
 
+// DefaultRedactHeaders are the headers redacted when LoggingConfig.RedactHeaders is nil
+var DefaultRedactHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization", "X-API-Key"}
+
+// DefaultRedactFields are the JSON fields redacted when LoggingConfig.RedactFields is nil
+var DefaultRedactFields = []string{"password", "secret", "token", "access_token", "refresh_token", "api_key"}
+
+const redacted = "[REDACTED]"
+
 type responseRecorder struct {
 	http.ResponseWriter
 	status int
@@ -837,16 +1067,47 @@ func (r *responseRecorder) Write(b []byte) (int, error) {
 	return size, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// RequestLogging logs a structured entry per request with its status, size, duration, request ID,
+// route pattern and trace ID, at a level chosen by status class:
+//
+//	router.Use(middleware.RequestLogging(middleware.LoggingConfig{
+//		Slog:       slog.New(slog.NewJSONHandler(os.Stdout, nil)),
+//		LogHeaders: true,
+//		SampleRate: 0.1,
+//	}))
 func RequestLogging(config ...LoggingConfig) steel.MiddlewareFunc {
 	cfg := LoggingConfig{
-		Logger:     log.Default(),
-		Format:     "${time} ${method} ${path} ${status} ${size} ${duration}",
 		TimeFormat: time.RFC3339,
-		UTC:        false,
 	}
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	if cfg.TimeFormat == "" {
+		cfg.TimeFormat = time.RFC3339
+	}
+	if cfg.RedactHeaders == nil {
+		cfg.RedactHeaders = DefaultRedactHeaders
+	}
+	if cfg.RedactFields == nil {
+		cfg.RedactFields = DefaultRedactFields
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = 4096
+	}
+
+	redactHeaders := make(map[string]bool, len(cfg.RedactHeaders))
+	for _, name := range cfg.RedactHeaders {
+		redactHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+	redactFields := make(map[string]bool, len(cfg.RedactFields))
+	for _, name := range cfg.RedactFields {
+		redactFields[strings.ToLower(name)] = true
+	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -855,6 +1116,20 @@ func RequestLogging(config ...LoggingConfig) steel.MiddlewareFunc {
 				return
 			}
 
+			if cfg.Slog != nil {
+				r = r.WithContext(steel.WithLogger(r.Context(), cfg.Slog))
+			}
+
+			var body []byte
+			if cfg.LogBody && r.Body != nil {
+				// Read one byte more than logged to tell whether the body was truncated
+				body, _ = io.ReadAll(io.LimitReader(r.Body, cfg.MaxBodySize+1))
+				r.Body = struct {
+					io.Reader
+					io.Closer
+				}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+			}
+
 			start := time.Now()
 			recorder := &responseRecorder{
 				ResponseWriter: w,
@@ -865,31 +1140,189 @@ func RequestLogging(config ...LoggingConfig) steel.MiddlewareFunc {
 
 			duration := time.Since(start)
 
-			// Build log entry
-			logData := map[string]interface{}{
+			level := logLevel(cfg.Levels, recorder.status)
+			if level < slog.LevelWarn && cfg.SampleRate > 0 && rand.Float64() >= cfg.SampleRate {
+				return
+			}
+
+			if cfg.UTC {
+				start = start.UTC()
+			}
+			fields := map[string]interface{}{
 				"time":     start.Format(cfg.TimeFormat),
 				"method":   r.Method,
 				"path":     r.URL.Path,
+				"route":    steel.RoutePattern(r),
 				"status":   recorder.status,
 				"size":     recorder.size,
 				"duration": duration.String(),
 				"ip":       defaultRateLimitKeyFunc(r),
 			}
 
+			// Correlate the entry with the request and its trace
+			if id := GetRequestID(r); id != "" {
+				fields["request_id"] = id
+			} else if id := recorder.Header().Get("X-Request-ID"); id != "" {
+				fields["request_id"] = id
+			}
+			traceID := steel.TraceIDFromContext(r.Context())
+			if traceID == "" {
+				traceID = traceIDFromResponse(recorder.Header())
+			}
+			if traceID != "" {
+				fields["trace_id"] = traceID
+			}
+
+			if cfg.LogHeaders {
+				fields["headers"] = redactHeaderValues(r.Header, redactHeaders)
+			}
+			if cfg.LogBody && len(body) > 0 {
+				if int64(len(body)) > cfg.MaxBodySize {
+					fields["body"] = "[TRUNCATED]"
+					if r.ContentLength > 0 {
+						fields["body_size"] = r.ContentLength
+					}
+				} else if value, ok := redactBody(r.Header.Get("Content-Type"), body, redactFields); ok {
+					fields["body"] = value
+				} else {
+					fields["body"] = "[UNPARSEABLE]"
+					fields["body_size"] = len(body)
+				}
+			}
+
 			// Add custom fields
 			if cfg.CustomFields != nil {
 				for key, fn := range cfg.CustomFields {
-					logData[key] = fn(r, duration)
+					fields[key] = fn(r, duration)
+				}
+			}
+
+			if cfg.Logger != nil {
+				if cfg.Format == "" {
+					logJSON, _ := json.Marshal(fields)
+					cfg.Logger.Println(string(logJSON))
+				} else {
+					cfg.Logger.Println(formatLogEntry(cfg.Format, fields))
+				}
+				return
+			}
+
+			attrs := make([]slog.Attr, 0, len(fields))
+			for key, value := range fields {
+				switch key {
+				case "time", "request_id", "route", "trace_id":
+					// Set by the record and the request logger
+				case "duration":
+					attrs = append(attrs, slog.Duration(key, duration))
+				default:
+					attrs = append(attrs, slog.Any(key, value))
 				}
 			}
+			sort.Slice(attrs, func(i, j int) bool { return attrs[i].Key < attrs[j].Key })
 
-			// Log as JSON
-			logJSON, _ := json.Marshal(logData)
-			cfg.Logger.Println(string(logJSON))
+			logger := steel.RequestLogger(r)
+			if _, traced := fields["trace_id"]; traced && steel.TraceIDFromContext(r.Context()) == "" {
+				logger = logger.With("trace_id", traceID)
+			}
+			if steel.RequestIDFromContext(r.Context()) == "" && r.Header.Get("X-Request-ID") == "" {
+				if id, ok := fields["request_id"]; ok {
+					logger = logger.With("request_id", id)
+				}
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
 		})
 	}
 }
 
+// logLevel returns the level of an entry for a response status
+func logLevel(levels map[int]slog.Level, status int) slog.Level {
+	if level, ok := levels[status/100]; ok {
+		return level
+	}
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// redactHeaderValues returns the request headers for logging with the redacted ones masked
+func redactHeaderValues(header http.Header, redact map[string]bool) map[string]string {
+	values := make(map[string]string, len(header))
+	for name, value := range header {
+		if redact[http.CanonicalHeaderKey(name)] {
+			values[name] = redacted
+		} else {
+			values[name] = strings.Join(value, ", ")
+		}
+	}
+	return values
+}
+
+// redactBody parses a JSON or form request body for logging, masking the redacted fields at any depth.
+// It reports false for bodies of other content types or that do not parse, which must not be logged.
+func redactBody(contentType string, body []byte, redact map[string]bool) (interface{}, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			return nil, false
+		}
+		return redactValue(value, redact), true
+
+	case mediaType == "application/x-www-form-urlencoded":
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, false
+		}
+		values := make(map[string]interface{}, len(form))
+		for key, value := range form {
+			if redact[strings.ToLower(key)] {
+				values[key] = redacted
+			} else {
+				values[key] = strings.Join(value, ", ")
+			}
+		}
+		return values, true
+
+	default:
+		return nil, false
+	}
+}
+
+func redactValue(value interface{}, redact map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(field, redact)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item, redact)
+		}
+	}
+	return value
+}
+
+// formatLogEntry replaces the ${field} placeholders of format, leaving unknown ones empty
+func formatLogEntry(format string, fields map[string]interface{}) string {
+	return os.Expand(format, func(key string) string {
+		value, ok := fields[key]
+		if !ok {
+			return ""
+		}
+		return fmt.Sprint(value)
+	})
+}
+
 // =============================================================================
 // Body Size Limit Middleware
 // =============================================================================
@@ -1166,71 +1599,25 @@ func CircuitBreakerMiddleware(config CircuitBreakerConfig) steel.MiddlewareFunc
 // =============================================================================
 
 type MetricsConfig struct {
-	Namespace   string
-	Subsystem   string
-	SkipFunc    func(*http.Request) bool
+	Namespace string
+	Subsystem string
+	// Buckets are the request duration histogram buckets in seconds, read by NewMetrics
+	Buckets  []float64
+	SkipFunc func(*http.Request) bool
+	// GroupedPath labels requests the router did not match to a route pattern, such as
+	// requests to mounted handlers. They are labelled "unmatched" when nil, since raw URL
+	// paths would give every path its own series.
 	GroupedPath func(string) string
 }
 
-type Metrics struct {
-	requestCount    map[string]*uint64
-	requestDuration map[string]*uint64
-	requestSize     map[string]*uint64
-	responseSize    map[string]*uint64
-	mutex           sync.RWMutex
-}
-
-func NewMetrics() *Metrics {
-	return &Metrics{
-		requestCount:    make(map[string]*uint64),
-		requestDuration: make(map[string]*uint64),
-		requestSize:     make(map[string]*uint64),
-		responseSize:    make(map[string]*uint64),
-	}
-}
-
-func (m *Metrics) Record(method, path string, status int, duration time.Duration, requestSize, responseSize int64) {
-	key := fmt.Sprintf("%s_%s_%d", method, path, status)
-
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
-
-	if m.requestCount[key] == nil {
-		m.requestCount[key] = new(uint64)
-		m.requestDuration[key] = new(uint64)
-		m.requestSize[key] = new(uint64)
-		m.responseSize[key] = new(uint64)
-	}
-
-	atomic.AddUint64(m.requestCount[key], 1)
-	atomic.AddUint64(m.requestDuration[key], uint64(duration.Nanoseconds()))
-	atomic.AddUint64(m.requestSize[key], uint64(requestSize))
-	atomic.AddUint64(m.responseSize[key], uint64(responseSize))
-}
-
-func (m *Metrics) GetStats() map[string]interface{} {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
-
-	stats := make(map[string]interface{})
-
-	for key, count := range m.requestCount {
-		stats[key] = map[string]interface{}{
-			"count":          atomic.LoadUint64(count),
-			"total_duration": atomic.LoadUint64(m.requestDuration[key]),
-			"total_req_size": atomic.LoadUint64(m.requestSize[key]),
-			"total_res_size": atomic.LoadUint64(m.responseSize[key]),
-		}
-	}
-
-	return stats
-}
-
+// MetricsMiddleware records request counts, durations, sizes and in-flight requests, labelled by the
+// matched route pattern. Serve metrics.Handler() on a route such as /metrics to expose them.
 func MetricsMiddleware(metrics *Metrics, config ...MetricsConfig) steel.MiddlewareFunc {
 	cfg := MetricsConfig{}
 	if len(config) > 0 {
 		cfg = config[0]
 	}
+	metrics.setNames(cfg.Namespace, cfg.Subsystem)
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -1239,23 +1626,44 @@ func MetricsMiddleware(metrics *Metrics, config ...MetricsConfig) steel.Middlewa
 				return
 			}
 
+			method, route := r.Method, steel.RoutePattern(r)
+			if route == "" {
+				route = unmatchedRoute
+				if cfg.GroupedPath != nil {
+					route = cfg.GroupedPath(r.URL.Path)
+				}
+				method = metricsMethod(method)
+			}
+
+			metrics.track(method, route, 1)
+			defer metrics.track(method, route, -1)
+
 			start := time.Now()
 			recorder := &responseRecorder{ResponseWriter: w, status: 200}
 
 			next.ServeHTTP(recorder, r)
 
 			duration := time.Since(start)
-			path := r.URL.Path
-			if cfg.GroupedPath != nil {
-				path = cfg.GroupedPath(path)
-			}
-
 			requestSize := r.ContentLength
 			if requestSize < 0 {
 				requestSize = 0
 			}
 
-			metrics.Record(r.Method, path, recorder.status, duration, requestSize, recorder.size)
+			metrics.Record(method, route, recorder.status, duration, requestSize, recorder.size)
 		})
 	}
 }
+
+// unmatchedRoute labels requests that did not match a route pattern
+const unmatchedRoute = "unmatched"
+
+// metricsMethod labels requests of unmatched routes by their method, grouping non-standard
+// methods under "OTHER" since clients can send any method
+func metricsMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
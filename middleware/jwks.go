@@ -0,0 +1,315 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	json "github.com/json-iterator/go"
+)
+
+// =============================================================================
+// JWKS Key Source
+// =============================================================================
+
+type JWKSConfig struct {
+	URL        string
+	HTTPClient *http.Client
+	// TTL is how long fetched keys are used before the set is fetched again
+	TTL time.Duration
+	// MinRefreshInterval limits how often a stale set or an unknown key ID triggers a fetch
+	MinRefreshInterval time.Duration
+}
+
+func DefaultJWKSConfig(url string) JWKSConfig {
+	return JWKSConfig{
+		URL:                url,
+		HTTPClient:         &http.Client{Timeout: 10 * time.Second},
+		TTL:                time.Hour,
+		MinRefreshInterval: time.Minute,
+	}
+}
+
+// JWKS fetches and caches the public keys of a JSON Web Key Set by key ID.
+// Keys are fetched on first use, again once the TTL expires, and when a token
+// names an unknown key ID, so keys rotated by the identity provider are picked up.
+type JWKS struct {
+	config JWKSConfig
+
+	mu        sync.RWMutex
+	keys      map[string]jwksKey
+	fetchedAt time.Time
+
+	// fetchMu serializes fetches so concurrent misses trigger a single request
+	fetchMu     sync.Mutex
+	lastAttempt time.Time
+}
+
+type jwksKey struct {
+	key interface{}
+	alg string
+}
+
+// jsonWebKey is the JSON representation of a key (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func NewJWKS(config JWKSConfig) *JWKS {
+	if config.URL == "" {
+		panic("JWKS requires a URL")
+	}
+	defaults := DefaultJWKSConfig(config.URL)
+	if config.HTTPClient == nil {
+		config.HTTPClient = defaults.HTTPClient
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaults.TTL
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = defaults.MinRefreshInterval
+	}
+
+	return &JWKS{
+		config: config,
+		keys:   make(map[string]jwksKey),
+	}
+}
+
+// jwksValidMethods are the algorithms of the RSA, EC and OKP keys a key set can hold
+var jwksValidMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+// KeyFunc returns the verification key for a token from its kid header. Fetches are not tied to
+// a request; the JWT middleware uses KeyFuncContext through JWTConfig.JWKS instead.
+func (k *JWKS) KeyFunc(token *jwt.Token) (interface{}, error) {
+	return k.KeyFuncContext(context.Background())(token)
+}
+
+// KeyFuncContext returns a key func that fetches the key set with ctx, so a cancelled request
+// abandons the fetch.
+func (k *JWKS) KeyFuncContext(ctx context.Context) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+
+		key, err := k.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		if key.alg != "" && key.alg != token.Method.Alg() {
+			return nil, fmt.Errorf("token algorithm %s does not match key algorithm %s", token.Method.Alg(), key.alg)
+		}
+		return key.key, nil
+	}
+}
+
+// Refresh fetches the key set, replacing the cached keys
+func (k *JWKS) Refresh(ctx context.Context) error {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+	return k.fetch(ctx)
+}
+
+func (k *JWKS) key(ctx context.Context, kid string) (jwksKey, error) {
+	key, ok, fresh := k.lookup(kid)
+	if ok && fresh {
+		return key, nil
+	}
+
+	// A stale key is served without waiting while another request refreshes the set
+	if ok {
+		if !k.fetchMu.TryLock() {
+			return key, nil
+		}
+	} else {
+		k.fetchMu.Lock()
+	}
+	defer k.fetchMu.Unlock()
+
+	// Another request may have fetched the set while this one was waiting
+	key, ok, fresh = k.lookup(kid)
+	if ok && fresh {
+		return key, nil
+	}
+
+	// Stale sets and unknown key IDs trigger a fetch at most once per interval, so an unavailable
+	// key set is not fetched on every request
+	if k.lastAttempt.IsZero() || time.Since(k.lastAttempt) >= k.config.MinRefreshInterval {
+		if err := k.fetch(ctx); err != nil {
+			if ok {
+				// Keep using the stale key while the key set is unavailable
+				return key, nil
+			}
+			return jwksKey{}, err
+		}
+		key, ok, _ = k.lookup(kid)
+	}
+
+	if !ok {
+		return jwksKey{}, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup returns the cached key for kid, and whether the cache is within its TTL.
+// Tokens without kid match when the set holds a single key.
+func (k *JWKS) lookup(kid string) (jwksKey, bool, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	fresh := !k.fetchedAt.IsZero() && time.Since(k.fetchedAt) < k.config.TTL
+
+	if kid == "" && len(k.keys) == 1 {
+		for _, key := range k.keys {
+			return key, true, fresh
+		}
+	}
+	key, ok := k.keys[kid]
+	return key, ok, fresh
+}
+
+// fetch downloads and parses the key set. Callers hold fetchMu.
+func (k *JWKS) fetch(ctx context.Context) error {
+	k.lastAttempt = time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, k.config.URL, nil)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("fetching JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching JWKS: unexpected status %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return fmt.Errorf("decoding JWKS: %w", err)
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped so one bad entry does not invalidate the set
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = jwksKey{key: key, alg: jwk.Alg}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS contains no usable signing keys")
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+
+	return nil
+}
+
+func (jwk jsonWebKey) publicKey() (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeJWKInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeJWKInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJWKInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", jwk.Crv)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decodeJWKBytes(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+}
+
+func decodeJWKBytes(value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+func decodeJWKInt(value string) (*big.Int, error) {
+	data, err := decodeJWKBytes(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,377 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	json "github.com/json-iterator/go"
+	"github.com/xraph/steel"
+)
+
+// testJWKSServer serves a key set that tests can rotate
+type testJWKSServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	keys     []map[string]string
+	requests atomic.Int32
+	failing  atomic.Bool
+}
+
+func newTestJWKSServer() *testJWKSServer {
+	s := &testJWKSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": s.keys})
+	}))
+	return s
+}
+
+func (s *testJWKSServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"alg": "RS256",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": "P-256",
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func signTestToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+type testClaims struct {
+	Role string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// TestJWTWithJWKS tests verification against a rotating key set with claim validation
+func TestJWTWithJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	server := newTestJWKSServer()
+	defer server.Close()
+	server.setKeys(rsaJWK("rsa-1", &rsaKey.PublicKey))
+
+	jwks := NewJWKS(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Millisecond})
+
+	config := DefaultJWTConfig()
+	config.JWKS = jwks
+	config.Issuer = "https://issuer.example.com"
+	config.Audience = "steel-api"
+	config.Leeway = 5 * time.Second
+	config.Claims = &testClaims{}
+
+	var claims *testClaims
+	handler := JWT(config)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, _ = GetTypedJWTClaims[*testClaims](r)
+		w.Write([]byte(GetUserID(r)))
+	}))
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	valid := func(subject string) *testClaims {
+		return &testClaims{
+			Role: "admin",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   subject,
+				Issuer:    "https://issuer.example.com",
+				Audience:  jwt.ClaimStrings{"steel-api"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+		}
+	}
+
+	w := serve(signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, valid("alice")))
+	if w.Code != http.StatusOK || w.Body.String() != "alice" {
+		t.Fatalf("Expected valid token to be accepted, got %d: %s", w.Code, w.Body.String())
+	}
+	if claims == nil || claims.Role != "admin" {
+		t.Errorf("Expected typed claims in the request context, got %+v", claims)
+	}
+
+	wrongAudience := valid("alice")
+	wrongAudience.Audience = jwt.ClaimStrings{"other-api"}
+	if w = serve(signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongAudience)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected wrong audience to be rejected, got %d", w.Code)
+	}
+
+	wrongIssuer := valid("alice")
+	wrongIssuer.Issuer = "https://evil.example.com"
+	if w = serve(signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongIssuer)); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected wrong issuer to be rejected, got %d", w.Code)
+	}
+
+	withinLeeway := valid("alice")
+	withinLeeway.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-2 * time.Second))
+	if w = serve(signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, withinLeeway)); w.Code != http.StatusOK {
+		t.Errorf("Expected token expired within leeway to be accepted, got %d", w.Code)
+	}
+
+	// Rotating keys is picked up on the first token signed with the new key ID
+	server.setKeys(ecJWK("ec-2", &ecKey.PublicKey))
+	before := server.requests.Load()
+	if w = serve(signTestToken(t, jwt.SigningMethodES256, "ec-2", ecKey, valid("bob"))); w.Code != http.StatusOK || w.Body.String() != "bob" {
+		t.Fatalf("Expected rotated key to be fetched, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := server.requests.Load() - before; got != 1 {
+		t.Errorf("Expected a single refresh, got %d", got)
+	}
+	if w = serve(signTestToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, valid("alice"))); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected retired key to be rejected, got %d", w.Code)
+	}
+
+	// Symmetric algorithms are outside the methods a key set verifies
+	hmacToken := signTestToken(t, jwt.SigningMethodHS256, "ec-2", []byte("secret"), valid("mallory"))
+	if w = serve(hmacToken); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected HS256 token to be rejected, got %d", w.Code)
+	}
+
+	// Fetches are made with the request context
+	cold := NewJWKS(JWKSConfig{URL: server.URL})
+	coldConfig := config
+	coldConfig.JWKS = cold
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	before = server.requests.Load()
+	req := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.SigningMethodES256, "ec-2", ecKey, valid("bob")))
+	w = httptest.NewRecorder()
+	JWT(coldConfig)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected fetch with a cancelled request context to fail, got %d", w.Code)
+	}
+	if got := server.requests.Load() - before; got != 0 {
+		t.Errorf("Expected no key set request after cancellation, got %d", got)
+	}
+
+	// Unknown key IDs do not trigger a fetch more than once per interval
+	throttled := NewJWKS(JWKSConfig{URL: server.URL, MinRefreshInterval: time.Hour})
+	if err := throttled.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to fetch key set: %v", err)
+	}
+	before = server.requests.Load()
+	for i := 0; i < 3; i++ {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, valid("mallory"))
+		token.Header["kid"] = "unknown"
+		if _, err := throttled.KeyFunc(token); err == nil {
+			t.Error("Expected unknown key ID to be rejected")
+		}
+	}
+	if got := server.requests.Load() - before; got != 0 {
+		t.Errorf("Expected refreshes on miss to be throttled, got %d requests", got)
+	}
+}
+
+// TestJWKSUnavailable tests that an unavailable key set is fetched once per interval while stale keys are served
+func TestJWKSUnavailable(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+	server := newTestJWKSServer()
+	defer server.Close()
+	server.setKeys(rsaJWK("rsa-1", &rsaKey.PublicKey))
+
+	interval := 100 * time.Millisecond
+	jwks := NewJWKS(JWKSConfig{URL: server.URL, TTL: 10 * time.Millisecond, MinRefreshInterval: interval})
+	if err := jwks.Refresh(context.Background()); err != nil {
+		t.Fatalf("Failed to fetch key set: %v", err)
+	}
+	server.failing.Store(true)
+
+	token := jwt.New(jwt.SigningMethodRS256)
+	token.Header["kid"] = "rsa-1"
+	unknown := jwt.New(jwt.SigningMethodRS256)
+	unknown.Header["kid"] = "unknown"
+
+	burst := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := jwks.KeyFunc(token); err != nil {
+					t.Errorf("Expected the stale key to be served, got %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		for i := 0; i < 5; i++ {
+			if _, err := jwks.KeyFunc(unknown); err == nil {
+				t.Error("Expected unknown key ID to be rejected")
+			}
+		}
+	}
+
+	for round := 1; round <= 2; round++ {
+		time.Sleep(interval + 20*time.Millisecond)
+		before := server.requests.Load()
+		burst()
+		if got := server.requests.Load() - before; got != 1 {
+			t.Errorf("Expected a single fetch in interval %d, got %d", round, got)
+		}
+	}
+}
+
+// TestJWTKeyFuncRequiresValidMethods tests that a custom key func must declare the accepted algorithms
+func TestJWTKeyFuncRequiresValidMethods(t *testing.T) {
+	keyFunc := func(token *jwt.Token) (interface{}, error) { return []byte("secret"), nil }
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected key func without ValidMethods to panic")
+		}
+	}()
+	JWT(JWTConfig{KeyFunc: keyFunc})
+}
+
+// TestOpinionatedJWTUserID tests that the opinionated middleware sets the user ID from the claims
+func TestOpinionatedJWTUserID(t *testing.T) {
+	secret := []byte("secret")
+
+	router := steel.NewRouter()
+	router.UseOpinionated(OpinionatedJWT(JWTConfig{SigningKey: secret, Audience: "steel-api"}))
+	router.UseOpinionated(steel.NewMiddleware("user").
+		Before(func(ctx *steel.MiddlewareContext) error {
+			ctx.Response.Header().Set("X-User-ID", ctx.UserID)
+			return nil
+		}).
+		Build())
+	router.OpinionatedGET("/me", func(ctx *steel.Context, req struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	})
+
+	serve := func(claims jwt.MapClaims) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.SigningMethodHS256, "", secret, claims))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(jwt.MapClaims{"sub": "alice", "aud": "steel-api"})
+	if w.Code != http.StatusOK || w.Header().Get("X-User-ID") != "alice" {
+		t.Errorf("Expected user ID from the subject, got %d with %q", w.Code, w.Header().Get("X-User-ID"))
+	}
+
+	if w = serve(jwt.MapClaims{"sub": "alice", "aud": "other-api"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected wrong audience to be rejected, got %d", w.Code)
+	}
+}
+
+// TestJWTAuthorizationScopes tests that token scopes satisfy the declared security requirements
+func TestJWTAuthorizationScopes(t *testing.T) {
+	secret := []byte("secret")
+
+	router := steel.NewRouter()
+	router.UseOpinionated(OpinionatedJWT(JWTConfig{SigningKey: secret, SchemeName: "oauth"}), router.Authorization())
+	router.OpinionatedPOST("/users", func(ctx *steel.Context, req struct{}) (*struct{}, error) {
+		return &struct{}{}, nil
+	}, steel.WithSecurity(steel.RequireOAuth2("oauth", "users:write")))
+
+	serve := func(claims jwt.MapClaims) int {
+		req := httptest.NewRequest("POST", "/users", nil)
+		req.Header.Set("Authorization", "Bearer "+signTestToken(t, jwt.SigningMethodHS256, "", secret, claims))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := serve(jwt.MapClaims{"sub": "alice", "scope": "users:read users:write"}); code != http.StatusOK {
+		t.Errorf("Expected granted scope to be accepted, got %d", code)
+	}
+	if code := serve(jwt.MapClaims{"sub": "alice", "scp": []string{"users:read"}}); code != http.StatusForbidden {
+		t.Errorf("Expected missing scope to be forbidden, got %d", code)
+	}
+}
+
+// TestJWTAuthenticator tests bearer tokens in the authenticator chain
+func TestJWTAuthenticator(t *testing.T) {
+	secret := []byte("secret")
+
+	router := steel.NewRouter()
+	router.RegisterSecurityScheme("bearer", steel.BearerAuth("JWT bearer token", "JWT"))
+	router.Use(router.Authentication(JWTAuthenticator("bearer", JWTConfig{SigningKey: secret})))
+	router.UseOpinionated(router.Authorization())
+	router.OpinionatedGET("/me", func(ctx *steel.Context, req struct{}) (*struct {
+		ID  string `json:"id"`
+		Alg string `json:"alg"`
+	}, error) {
+		principal := ctx.Principal().(*JWTPrincipal)
+		return &struct {
+			ID  string `json:"id"`
+			Alg string `json:"alg"`
+		}{ID: principal.ID(), Alg: principal.Token.Method.Alg()}, nil
+	}, steel.WithSecurity(steel.RequireBearer("bearer")))
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	token := signTestToken(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "alice"})
+	if w := serve("Bearer " + token); w.Code != http.StatusOK || w.Body.String() != "{\"id\":\"alice\",\"alg\":\"HS256\"}\n" {
+		t.Errorf("Expected the token principal, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serve(""); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected missing token to be unauthorized, got %d", w.Code)
+	}
+	if w := serve("Bearer " + token + "x"); w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected invalid token to be unauthorized, got %d", w.Code)
+	}
+}
@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xraph/steel"
+)
+
+// TestRequestLogging tests leveled, redacted and sampled request logging
+func TestRequestLogging(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	router := steel.NewRouter()
+	router.Use(RequestLogging(LoggingConfig{
+		Slog:       logger,
+		LogHeaders: true,
+		LogBody:    true,
+		Levels:     map[int]slog.Level{2: slog.LevelDebug},
+	}))
+	router.Use(RequestID())
+	router.POST("/sessions/:kind", func(w http.ResponseWriter, r *http.Request) {
+		steel.RequestLogger(r).Info("creating session")
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	req := httptest.NewRequest("POST", "/sessions/password", strings.NewReader(`{"user":"alice","password":"hunter2","nested":{"token":"t"}}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Request-ID", "req-1")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected a handler entry and a request entry, got %q", logs.String())
+	}
+
+	var handlerEntry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &handlerEntry); err != nil {
+		t.Fatal(err)
+	}
+	if handlerEntry["msg"] != "creating session" || handlerEntry["request_id"] != "req-1" {
+		t.Errorf("Expected the handler to log through the configured logger, got %v", handlerEntry)
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[1]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "WARN" || entry["status"] != float64(401) || entry["route"] != "/sessions/:kind" || entry["request_id"] != "req-1" {
+		t.Errorf("Expected a WARN entry for the 401, got %v", entry)
+	}
+	headers, _ := entry["headers"].(map[string]interface{})
+	if headers["Authorization"] != "[REDACTED]" || headers["X-Request-Id"] != "req-1" {
+		t.Errorf("Expected redacted headers, got %v", headers)
+	}
+	body, _ := entry["body"].(map[string]interface{})
+	nested, _ := body["nested"].(map[string]interface{})
+	if body["user"] != "alice" || body["password"] != "[REDACTED]" || nested["token"] != "[REDACTED]" {
+		t.Errorf("Expected redacted body fields, got %v", body)
+	}
+
+	// Sampling drops entries below Warn but keeps errors
+	logs.Reset()
+	sampled := RequestLogging(LoggingConfig{Slog: logger, SampleRate: 1e-9})
+	sampled(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if logs.Len() != 0 {
+		t.Errorf("Expected the 200 entry to be sampled out, got %q", logs.String())
+	}
+	sampled(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !strings.Contains(logs.String(), `"level":"ERROR"`) {
+		t.Errorf("Expected the 500 entry to be logged, got %q", logs.String())
+	}
+}
+
+// TestRequestLoggingBodies tests that bodies which cannot be parsed and redacted are not logged
+func TestRequestLoggingBodies(t *testing.T) {
+	var logs bytes.Buffer
+	handler := RequestLogging(LoggingConfig{
+		Slog:        slog.New(slog.NewJSONHandler(&logs, nil)),
+		LogBody:     true,
+		MaxBodySize: 64,
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}))
+
+	oversized := `{"password":"hunter2","padding":"` + strings.Repeat("x", 100) + `"}`
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        interface{}
+	}{
+		{"oversized JSON", "application/json", oversized, "[TRUNCATED]"},
+		{"invalid JSON", "application/json", `{"password":"hunter2"`, "[UNPARSEABLE]"},
+		{"text", "text/plain", "password=hunter2", "[UNPARSEABLE]"},
+		{"form", "application/x-www-form-urlencoded", "user=alice&password=hunter2",
+			map[string]interface{}{"user": "alice", "password": "[REDACTED]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest("POST", "/login", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if strings.Contains(logs.String(), "hunter2") {
+				t.Fatalf("Expected the secret not to be logged, got %q", logs.String())
+			}
+			var entry map[string]interface{}
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entry["body"], tt.want) {
+				t.Errorf("Expected body %v, got %v", tt.want, entry["body"])
+			}
+			if _, ok := tt.want.(string); ok && entry["body_size"] == nil {
+				t.Errorf("Expected the size of the unlogged body, got %v", entry)
+			}
+		})
+	}
+}
+
+// TestRequestLoggingFormat tests text entries in the configured format
+func TestRequestLoggingFormat(t *testing.T) {
+	var logs bytes.Buffer
+	handler := RequestLogging(LoggingConfig{
+		Logger:     log.New(&logs, "", 0),
+		Format:     "${time} ${method} ${path} ${status} ${user}",
+		TimeFormat: "2006",
+		UTC:        true,
+		CustomFields: map[string]func(*http.Request, time.Duration) interface{}{
+			"user": func(r *http.Request, _ time.Duration) interface{} { return "alice" },
+		},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/orders", nil))
+
+	want := time.Now().UTC().Format("2006") + " POST /orders 201 alice\n"
+	if logs.String() != want {
+		t.Errorf("Expected %q, got %q", want, logs.String())
+	}
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets are the upper bounds, in seconds, of the request duration histogram
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// OpenMetricsContentType is the content type of the OpenMetrics text format
+const OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// metricsKey identifies the series of a route and response status
+type metricsKey struct {
+	method string
+	route  string
+	status int
+}
+
+// inFlightKey identifies the in-flight gauge of a route
+type inFlightKey struct {
+	method string
+	route  string
+}
+
+type metricsSeries struct {
+	count        uint64
+	durationSum  float64
+	buckets      []uint64 // Observations per bucket, not cumulative; the last bucket is +Inf
+	requestSize  uint64
+	responseSize uint64
+}
+
+// Metrics collects HTTP request metrics labelled by method, route pattern and status,
+// and exposes them in the OpenMetrics text format
+type Metrics struct {
+	namespace string
+	subsystem string
+	buckets   []float64
+	series    map[metricsKey]*metricsSeries
+	inFlight  map[inFlightKey]int64
+	mutex     sync.RWMutex
+}
+
+// NewMetrics creates a metrics collector. The Namespace and Subsystem of the config prefix the metric
+// names and Buckets sets the request duration histogram buckets, DefaultMetricsBuckets when empty.
+func NewMetrics(config ...MetricsConfig) *Metrics {
+	cfg := MetricsConfig{}
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	buckets := cfg.Buckets
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		namespace: cfg.Namespace,
+		subsystem: cfg.Subsystem,
+		buckets:   buckets,
+		series:    make(map[metricsKey]*metricsSeries),
+		inFlight:  make(map[inFlightKey]int64),
+	}
+}
+
+// setNames sets the metric name prefix when given, for configs passed to MetricsMiddleware
+func (m *Metrics) setNames(namespace, subsystem string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if namespace != "" {
+		m.namespace = namespace
+	}
+	if subsystem != "" {
+		m.subsystem = subsystem
+	}
+}
+
+// Record adds a completed request to the metrics. route should be a route pattern rather than
+// the URL path, so that paths with IDs share a series.
+func (m *Metrics) Record(method, route string, status int, duration time.Duration, requestSize, responseSize int64) {
+	key := metricsKey{method: method, route: route, status: status}
+	seconds := duration.Seconds()
+	bucket := sort.SearchFloat64s(m.buckets, seconds)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	series := m.series[key]
+	if series == nil {
+		series = &metricsSeries{buckets: make([]uint64, len(m.buckets)+1)}
+		m.series[key] = series
+	}
+
+	series.count++
+	series.durationSum += seconds
+	series.buckets[bucket]++
+	series.requestSize += uint64(max(0, requestSize))
+	series.responseSize += uint64(max(0, responseSize))
+}
+
+// track adjusts the number of requests of a route in flight
+func (m *Metrics) track(method, route string, delta int64) {
+	key := inFlightKey{method: method, route: route}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Idle gauges stay at zero so their series continue across scrapes. Route labels are bounded,
+	// so the map is too.
+	m.inFlight[key] += delta
+}
+
+func (m *Metrics) GetStats() map[string]interface{} {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stats := make(map[string]interface{})
+
+	for key, series := range m.series {
+		stats[fmt.Sprintf("%s_%s_%d", key.method, key.route, key.status)] = map[string]interface{}{
+			"count":          series.count,
+			"total_duration": uint64(series.durationSum * float64(time.Second)),
+			"total_req_size": series.requestSize,
+			"total_res_size": series.responseSize,
+		}
+	}
+
+	return stats
+}
+
+// Handler returns a handler writing the metrics in the OpenMetrics text format, for a /metrics route
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", OpenMetricsContentType)
+		buf := bufio.NewWriter(w)
+		m.WriteOpenMetrics(buf)
+		buf.Flush()
+	})
+}
+
+// WriteOpenMetrics writes the metrics in the OpenMetrics text format
+func (m *Metrics) WriteOpenMetrics(w *bufio.Writer) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	keys := make([]metricsKey, 0, len(m.series))
+	for key := range m.series {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].route != keys[j].route {
+			return keys[i].route < keys[j].route
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	name := m.metricName("http_requests")
+	writeMetricHelp(w, name, "counter", "Total number of HTTP requests.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s_total{%s} %d\n", name, key.labels(), m.series[key].count)
+	}
+
+	name = m.metricName("http_request_duration_seconds")
+	writeMetricHelp(w, name, "histogram", "Duration of HTTP requests in seconds.")
+	for _, key := range keys {
+		series := m.series[key]
+		labels := key.labels()
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += series.buckets[i]
+			fmt.Fprintf(w, "%s_bucket{%s,le=\"%s\"} %d\n", name, labels, formatMetricFloat(bound), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, labels, series.count)
+		fmt.Fprintf(w, "%s_count{%s} %d\n", name, labels, series.count)
+		fmt.Fprintf(w, "%s_sum{%s} %s\n", name, labels, formatMetricFloat(series.durationSum))
+	}
+
+	name = m.metricName("http_request_size_bytes")
+	writeMetricHelp(w, name, "counter", "Total size of HTTP request bodies in bytes.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s_total{%s} %d\n", name, key.labels(), m.series[key].requestSize)
+	}
+
+	name = m.metricName("http_response_size_bytes")
+	writeMetricHelp(w, name, "counter", "Total size of HTTP response bodies in bytes.")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s_total{%s} %d\n", name, key.labels(), m.series[key].responseSize)
+	}
+
+	flights := make([]inFlightKey, 0, len(m.inFlight))
+	for key := range m.inFlight {
+		flights = append(flights, key)
+	}
+	sort.Slice(flights, func(i, j int) bool {
+		if flights[i].route != flights[j].route {
+			return flights[i].route < flights[j].route
+		}
+		return flights[i].method < flights[j].method
+	})
+
+	name = m.metricName("http_requests_in_flight")
+	writeMetricHelp(w, name, "gauge", "Number of HTTP requests being served.")
+	for _, key := range flights {
+		fmt.Fprintf(w, "%s{method=\"%s\",route=\"%s\"} %d\n", name,
+			escapeLabelValue(key.method), escapeLabelValue(key.route), m.inFlight[key])
+	}
+
+	w.WriteString("# EOF\n")
+}
+
+// metricName prefixes a metric name with the namespace and subsystem
+func (m *Metrics) metricName(name string) string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{m.namespace, m.subsystem, name} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+func (k metricsKey) labels() string {
+	return fmt.Sprintf("method=\"%s\",route=\"%s\",status=\"%d\"", escapeLabelValue(k.method), escapeLabelValue(k.route), k.status)
+}
+
+func writeMetricHelp(w *bufio.Writer, name, metricType, help string) {
+	fmt.Fprintf(w, "# TYPE %s %s\n# HELP %s %s\n", name, metricType, name, help)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func formatMetricFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
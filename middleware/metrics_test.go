@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xraph/steel"
+)
+
+// TestMetricsExposition tests route pattern labels, histograms and the OpenMetrics output
+func TestMetricsExposition(t *testing.T) {
+	metrics := NewMetrics(MetricsConfig{Buckets: []float64{0.1, 1}})
+	router := steel.NewRouter()
+	router.Use(MetricsMiddleware(metrics, MetricsConfig{Namespace: "api"}))
+
+	var inFlight string
+	router.GET("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		inFlight = scrape(metrics)
+		w.Write([]byte("user"))
+	})
+	router.GET("/missing", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "not found", http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if !strings.Contains(inFlight, `api_http_requests_in_flight{method="GET",route="/users/:id"} 1`) {
+		t.Errorf("Expected the in-flight gauge during the request, got:\n%s", inFlight)
+	}
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Header().Get("Content-Type") != OpenMetricsContentType {
+		t.Errorf("Expected the OpenMetrics content type, got %q", w.Header().Get("Content-Type"))
+	}
+
+	output := w.Body.String()
+	for _, line := range []string{
+		"# TYPE api_http_requests counter",
+		`api_http_requests_total{method="GET",route="/users/:id",status="200"} 2`,
+		`api_http_requests_total{method="GET",route="/missing",status="404"} 1`,
+		"# TYPE api_http_request_duration_seconds histogram",
+		`api_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="0.1"} 2`,
+		`api_http_request_duration_seconds_bucket{method="GET",route="/users/:id",status="200",le="+Inf"} 2`,
+		`api_http_request_duration_seconds_count{method="GET",route="/users/:id",status="200"} 2`,
+		`api_http_response_size_bytes_total{method="GET",route="/users/:id",status="200"} 8`,
+		`api_http_requests_in_flight{method="GET",route="/users/:id"} 0`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "/users/1") || !strings.HasSuffix(output, "# EOF\n") {
+		t.Errorf("Expected raw paths to be grouped and the output terminated, got:\n%s", output)
+	}
+}
+
+// TestMetricsUnmatchedLabels tests that requests outside the router get bounded labels
+func TestMetricsUnmatchedLabels(t *testing.T) {
+	metrics := NewMetrics()
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	plain := MetricsMiddleware(metrics)(handler)
+	grouped := MetricsMiddleware(metrics, MetricsConfig{
+		GroupedPath: func(path string) string {
+			return "/static"
+		},
+	})(handler)
+
+	plain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+	plain.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/users/2", nil))
+	grouped.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/static/app.js", nil))
+
+	output := scrape(metrics)
+	for _, line := range []string{
+		`http_requests_total{method="GET",route="unmatched",status="200"} 1`,
+		`http_requests_total{method="OTHER",route="unmatched",status="200"} 1`,
+		`http_requests_total{method="GET",route="/static",status="200"} 1`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("Expected line %q in:\n%s", line, output)
+		}
+	}
+	if strings.Contains(output, "/users/") {
+		t.Errorf("Expected raw paths not to be used as labels, got:\n%s", output)
+	}
+}
+
+func scrape(metrics *Metrics) string {
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	return w.Body.String()
+}
@@ -0,0 +1,342 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// =============================================================================
+// Rate Limit Stores
+// =============================================================================
+
+// RateLimitDecision is the outcome of spending units of a rate limit
+type RateLimitDecision struct {
+	Allowed bool
+	// Limit is the number of units available when the limit is fully replenished
+	Limit int
+	// Remaining is the number of units left after the decision
+	Remaining int
+	// ResetAfter is the time until the limit is fully replenished
+	ResetAfter time.Duration
+	// RetryAfter is the time until the request would be allowed, zero when allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps the state of a rate limit per key
+type RateLimitStore interface {
+	// Allow spends cost units of the limit of key if enough are available
+	Allow(key string, cost int) (RateLimitDecision, error)
+}
+
+// Token bucket store backed by process-local limiters
+
+type inMemoryRateLimitStore struct {
+	limiters map[string]*rateLimiterEntry
+	mu       sync.RWMutex
+	rps      rate.Limit
+	burst    int
+}
+
+type rateLimiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewInMemoryRateLimitStore returns a token bucket store refilling rps units per second up to burst.
+// Its state is local to the process, so each replica enforces the limit separately.
+func NewInMemoryRateLimitStore(rps float64, burst int) RateLimitStore {
+	store := &inMemoryRateLimitStore{
+		limiters: make(map[string]*rateLimiterEntry),
+		rps:      rate.Limit(rps),
+		burst:    burst,
+	}
+
+	// Start cleanup goroutine
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			store.CleanupExpired()
+		}
+	}()
+
+	return store
+}
+
+func (s *inMemoryRateLimitStore) Allow(key string, cost int) (RateLimitDecision, error) {
+	limiter := s.limiter(key)
+	now := time.Now()
+	fill := time.Duration(float64(s.burst) / float64(s.rps) * float64(time.Second))
+
+	decision := RateLimitDecision{Limit: s.burst}
+	reservation := limiter.ReserveN(now, cost)
+	switch {
+	case !reservation.OK():
+		// The cost exceeds the burst and can never be allowed
+		decision.RetryAfter = fill
+	case reservation.DelayFrom(now) > 0:
+		decision.RetryAfter = reservation.DelayFrom(now)
+		reservation.CancelAt(now)
+	default:
+		decision.Allowed = true
+	}
+
+	tokens := limiter.TokensAt(now)
+	decision.Remaining = max(0, int(tokens))
+	decision.ResetAfter = time.Duration((float64(s.burst) - tokens) / float64(s.rps) * float64(time.Second))
+	return decision, nil
+}
+
+func (s *inMemoryRateLimitStore) limiter(key string) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, exists := s.limiters[key]
+	if !exists {
+		entry = &rateLimiterEntry{
+			limiter:  rate.NewLimiter(s.rps, s.burst),
+			lastSeen: time.Now(),
+		}
+		s.limiters[key] = entry
+	}
+
+	entry.lastSeen = time.Now()
+	return entry.limiter
+}
+
+func (s *inMemoryRateLimitStore) CleanupExpired() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry := time.Now().Add(-time.Hour) // Remove entries older than 1 hour
+	for key, entry := range s.limiters {
+		if entry.lastSeen.Before(expiry) {
+			delete(s.limiters, key)
+		}
+	}
+}
+
+// Sliding window log store
+
+type slidingWindowRateLimitStore struct {
+	limit       int
+	window      time.Duration
+	mu          sync.Mutex
+	logs        map[string][]time.Time
+	lastCleanup time.Time
+}
+
+// NewSlidingWindowRateLimitStore returns a store allowing limit units within any window of time.
+// It keeps the time of every unit spent, so limits are exact without bursts at window boundaries.
+func NewSlidingWindowRateLimitStore(limit int, window time.Duration) RateLimitStore {
+	return &slidingWindowRateLimitStore{
+		limit:  limit,
+		window: window,
+		logs:   make(map[string][]time.Time),
+	}
+}
+
+func (s *slidingWindowRateLimitStore) Allow(key string, cost int) (RateLimitDecision, error) {
+	now := time.Now()
+	cutoff := now.Add(-s.window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastCleanup) > s.window {
+		for k, log := range s.logs {
+			if !log[len(log)-1].After(cutoff) {
+				delete(s.logs, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	log := s.logs[key]
+	log = log[sort.Search(len(log), func(i int) bool { return log[i].After(cutoff) }):]
+
+	decision := RateLimitDecision{Limit: s.limit}
+	switch {
+	case len(log)+cost <= s.limit:
+		for i := 0; i < cost; i++ {
+			log = append(log, now)
+		}
+		decision.Allowed = true
+	case cost <= s.limit:
+		// The request fits once enough of the oldest units leave the window
+		decision.RetryAfter = log[len(log)+cost-s.limit-1].Add(s.window).Sub(now)
+	default:
+		decision.RetryAfter = s.window
+	}
+
+	if len(log) == 0 {
+		delete(s.logs, key)
+	} else {
+		s.logs[key] = log
+		decision.ResetAfter = log[len(log)-1].Add(s.window).Sub(now)
+	}
+	decision.Remaining = s.limit - len(log)
+	return decision, nil
+}
+
+// GCRA stores
+
+// gcra applies the generic cell rate algorithm to the theoretical arrival time of a key, which is zero
+// for unknown keys. interval is the time to replenish one unit and burst the units available at once.
+// It returns the new theoretical arrival time, unchanged when the request is rejected.
+func gcra(now, tat time.Time, cost int, interval time.Duration, burst int) (time.Time, RateLimitDecision) {
+	if tat.Before(now) {
+		tat = now
+	}
+	tolerance := interval * time.Duration(burst)
+	newTat := tat.Add(interval * time.Duration(cost))
+
+	decision := RateLimitDecision{Limit: burst}
+	if allowAt := newTat.Add(-tolerance); allowAt.After(now) {
+		decision.RetryAfter = allowAt.Sub(now)
+		decision.Remaining = max(0, int((tolerance-tat.Sub(now))/interval))
+		decision.ResetAfter = tat.Sub(now)
+		return tat, decision
+	}
+
+	decision.Allowed = true
+	decision.Remaining = max(0, int((tolerance-newTat.Sub(now))/interval))
+	decision.ResetAfter = newTat.Sub(now)
+	return newTat, decision
+}
+
+// gcraParameters returns the emission interval and burst of limit units per period
+func gcraParameters(limit int, period time.Duration, burst int) (time.Duration, int) {
+	if limit <= 0 {
+		panic("rate limit must be positive")
+	}
+	if period <= 0 {
+		panic("rate limit period must be positive")
+	}
+	interval := period / time.Duration(limit)
+	if interval <= 0 {
+		panic(fmt.Sprintf("rate limit of %d per %s is finer than a nanosecond per request", limit, period))
+	}
+	if burst <= 0 {
+		burst = limit
+	}
+	return interval, burst
+}
+
+type gcraRateLimitStore struct {
+	interval    time.Duration
+	burst       int
+	mu          sync.Mutex
+	tats        map[string]time.Time
+	lastCleanup time.Time
+}
+
+// NewGCRARateLimitStore returns a store allowing limit units per period, spread evenly with bursts of up
+// to burst units (limit when zero). It keeps a single timestamp per key.
+func NewGCRARateLimitStore(limit int, period time.Duration, burst int) RateLimitStore {
+	interval, burst := gcraParameters(limit, period, burst)
+	return &gcraRateLimitStore{
+		interval: interval,
+		burst:    burst,
+		tats:     make(map[string]time.Time),
+	}
+}
+
+func (s *gcraRateLimitStore) Allow(key string, cost int) (RateLimitDecision, error) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if now.Sub(s.lastCleanup) > s.interval*time.Duration(s.burst) {
+		for k, tat := range s.tats {
+			if tat.Before(now) {
+				delete(s.tats, k)
+			}
+		}
+		s.lastCleanup = now
+	}
+
+	tat, decision := gcra(now, s.tats[key], cost, s.interval, s.burst)
+	s.tats[key] = tat
+	return decision, nil
+}
+
+// =============================================================================
+// Distributed Rate Limiting
+// =============================================================================
+
+// RateLimitKV is the part of an external key-value store, such as Redis or etcd,
+// that distributed rate limiting needs. CompareAndSwap must be atomic.
+type RateLimitKV interface {
+	// Get returns the value of key, or nil when it does not exist
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// CompareAndSwap sets key to value, expiring after ttl, if its current value equals old.
+	// A nil old value requires the key not to exist. It reports whether the value was set.
+	CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error)
+}
+
+// kvMaxAttempts bounds the retries of a store update racing with other replicas
+const kvMaxAttempts = 10
+
+type kvRateLimitStore struct {
+	kv       RateLimitKV
+	interval time.Duration
+	burst    int
+	timeout  time.Duration
+}
+
+// NewKVRateLimitStore returns a GCRA store keeping its state in an external key-value store,
+// so replicas share the limit instead of each allowing it. Keys are prefixed with "ratelimit:".
+// Replicas compare timestamps, so their clocks must be synchronized.
+func NewKVRateLimitStore(kv RateLimitKV, limit int, period time.Duration, burst int) RateLimitStore {
+	interval, burst := gcraParameters(limit, period, burst)
+	return &kvRateLimitStore{
+		kv:       kv,
+		interval: interval,
+		burst:    burst,
+		timeout:  time.Second,
+	}
+}
+
+func (s *kvRateLimitStore) Allow(key string, cost int) (RateLimitDecision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	key = "ratelimit:" + key
+	for attempt := 0; attempt < kvMaxAttempts; attempt++ {
+		old, err := s.kv.Get(ctx, key)
+		if err != nil {
+			return RateLimitDecision{}, fmt.Errorf("reading rate limit state: %w", err)
+		}
+
+		var tat time.Time
+		if nanos, err := strconv.ParseInt(string(old), 10, 64); err == nil {
+			tat = time.Unix(0, nanos)
+		}
+
+		now := time.Now()
+		newTat, decision := gcra(now, tat, cost, s.interval, s.burst)
+		if !decision.Allowed {
+			return decision, nil
+		}
+
+		value := []byte(strconv.FormatInt(newTat.UnixNano(), 10))
+		swapped, err := s.kv.CompareAndSwap(ctx, key, old, value, newTat.Sub(now))
+		if err != nil {
+			return RateLimitDecision{}, fmt.Errorf("writing rate limit state: %w", err)
+		}
+		if swapped {
+			return decision, nil
+		}
+	}
+
+	return RateLimitDecision{}, fmt.Errorf("rate limit state of %q changed concurrently %d times", key, kvMaxAttempts)
+}
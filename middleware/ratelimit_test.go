@@ -0,0 +1,266 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/xraph/steel"
+)
+
+// fakeKV is an in-memory RateLimitKV standing in for an external store
+type fakeKV struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newFakeKV() *fakeKV {
+	return &fakeKV{data: make(map[string][]byte)}
+}
+
+func (kv *fakeKV) Get(ctx context.Context, key string) ([]byte, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	return kv.data[key], nil
+}
+
+func (kv *fakeKV) CompareAndSwap(ctx context.Context, key string, old, value []byte, ttl time.Duration) (bool, error) {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+	current, exists := kv.data[key]
+	if exists != (old != nil) || !bytes.Equal(current, old) {
+		return false, nil
+	}
+	kv.data[key] = value
+	return true, nil
+}
+
+// TestRateLimitStores tests the decisions of each store algorithm
+func TestRateLimitStores(t *testing.T) {
+	stores := map[string]RateLimitStore{
+		"token bucket":   NewInMemoryRateLimitStore(1, 3),
+		"sliding window": NewSlidingWindowRateLimitStore(3, time.Minute),
+		"gcra":           NewGCRARateLimitStore(3, time.Minute, 0),
+		"kv":             NewKVRateLimitStore(newFakeKV(), 3, time.Minute, 0),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 3; i++ {
+				decision, err := store.Allow("client", 1)
+				if err != nil || !decision.Allowed {
+					t.Fatalf("Expected request %d to be allowed, got %+v, %v", i+1, decision, err)
+				}
+				if decision.Limit != 3 || decision.Remaining != 2-i {
+					t.Errorf("Expected limit 3 with %d remaining, got %+v", 2-i, decision)
+				}
+			}
+
+			decision, err := store.Allow("client", 1)
+			if err != nil || decision.Allowed {
+				t.Fatalf("Expected request over the limit to be rejected, got %+v, %v", decision, err)
+			}
+			if decision.RetryAfter <= 0 || decision.Remaining != 0 {
+				t.Errorf("Expected a retry delay and nothing remaining, got %+v", decision)
+			}
+
+			if decision, _ := store.Allow("other", 2); !decision.Allowed || decision.Remaining != 1 {
+				t.Errorf("Expected keys to be limited separately with cost, got %+v", decision)
+			}
+		})
+	}
+
+	window := NewSlidingWindowRateLimitStore(1, 50*time.Millisecond)
+	window.Allow("client", 1)
+	decision, _ := window.Allow("client", 1)
+	time.Sleep(decision.RetryAfter + 5*time.Millisecond)
+	if decision, _ := window.Allow("client", 1); !decision.Allowed {
+		t.Errorf("Expected the window to slide after the retry delay, got %+v", decision)
+	}
+}
+
+// TestGCRARateLimitStoreParameters tests that periods too short for the limit are rejected at construction
+func TestGCRARateLimitStoreParameters(t *testing.T) {
+	tests := map[string]func(){
+		"zero period":        func() { NewGCRARateLimitStore(1, 0, 0) },
+		"sub-nanosecond":     func() { NewGCRARateLimitStore(10, 5*time.Nanosecond, 0) },
+		"kv zero period":     func() { NewKVRateLimitStore(newFakeKV(), 1, 0, 0) },
+		"kv sub-nanosecond":  func() { NewKVRateLimitStore(newFakeKV(), 10, 5*time.Nanosecond, 0) },
+		"non-positive limit": func() { NewGCRARateLimitStore(0, time.Second, 0) },
+	}
+
+	for name, construct := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("Expected construction to panic")
+				}
+			}()
+			construct()
+		})
+	}
+}
+
+// TestKVRateLimitStoreShared tests that replicas sharing a key-value store share the limit
+func TestKVRateLimitStoreShared(t *testing.T) {
+	kv := newFakeKV()
+	replicas := []RateLimitStore{
+		NewKVRateLimitStore(kv, 5, time.Minute, 0),
+		NewKVRateLimitStore(kv, 5, time.Minute, 0),
+	}
+
+	var allowed atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(store RateLimitStore) {
+			defer wg.Done()
+			decision, err := store.Allow("client", 1)
+			if err != nil {
+				t.Error(err)
+			}
+			if decision.Allowed {
+				allowed.Add(1)
+			}
+		}(replicas[i%2])
+	}
+	wg.Wait()
+
+	if got := allowed.Load(); got != 5 {
+		t.Errorf("Expected 5 requests allowed across replicas, got %d", got)
+	}
+}
+
+// TestRateLimitRules tests headers, route rules and principal keys
+func TestRateLimitRules(t *testing.T) {
+	router := steel.NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user := r.Header.Get("X-User"); user != "" {
+				r = r.WithContext(steel.WithPrincipal(r.Context(), "test", steel.StaticPrincipal{Subject: user}))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Use(RateLimit(RateLimitConfig{
+		Store: NewGCRARateLimitStore(10, time.Minute, 0),
+		Rules: []RateLimitRule{{
+			Name:    "search",
+			Match:   func(r *http.Request) bool { return strings.HasPrefix(r.URL.Path, "/search") },
+			KeyFunc: PrincipalRateLimitKey,
+			Store:   NewGCRARateLimitStore(1, time.Minute, 0),
+		}},
+	}))
+	router.GET("/search", func(w http.ResponseWriter, r *http.Request) {})
+	router.GET("/health", func(w http.ResponseWriter, r *http.Request) {})
+
+	serve := func(path, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-User", user)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/health", "")
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "10" || w.Header().Get("RateLimit-Remaining") != "9" || w.Header().Get("RateLimit-Reset") != "6" {
+		t.Errorf("Expected rate limit headers on the response, got %d %v", w.Code, w.Header())
+	}
+
+	if w = serve("/search", "alice"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("Expected the search rule, got %d %v", w.Code, w.Header())
+	}
+	w = serve("/search", "alice")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected the search rule to reject with Retry-After, got %d %v", w.Code, w.Header())
+	}
+	if w = serve("/search", "bob"); w.Code != http.StatusOK {
+		t.Errorf("Expected each principal to get its own limit, got %d", w.Code)
+	}
+	if w = serve("/health", "alice"); w.Code != http.StatusOK {
+		t.Errorf("Expected other routes to use the default limit, got %d", w.Code)
+	}
+}
+
+// TestDeclaredRateLimitPolicies tests that policies declared on routes and groups are enforced
+func TestDeclaredRateLimitPolicies(t *testing.T) {
+	router := steel.NewRouter()
+	router.UseOpinionated(OpinionatedRateLimit(RateLimitConfig{Store: NewGCRARateLimitStore(10, time.Minute, 0)}))
+
+	type output struct{}
+	handler := func(ctx *steel.Context, req struct{}) (*output, error) {
+		return &output{}, nil
+	}
+	router.OpinionatedGET("/health", handler)
+	router.OpinionatedGET("/search", handler, steel.WithRateLimit(steel.RateLimitPolicy{Limit: 1, Period: time.Minute}))
+	router.Route("/reports", func(r steel.Router) {
+		r.RateLimit(steel.RateLimitPolicy{Name: "reports", Limit: 2, Period: time.Minute})
+		r.OpinionatedGET("/daily", handler)
+		r.OpinionatedGET("/weekly", handler)
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	if w := serve("/search"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("Expected the route policy, got %d %v", w.Code, w.Header())
+	}
+	w := serve("/search")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" || !strings.Contains(w.Body.String(), "retry_after_seconds") {
+		t.Errorf("Expected the route policy to reject with Retry-After, got %d %v %s", w.Code, w.Header(), w.Body)
+	}
+	if w := serve("/health"); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "10" {
+		t.Errorf("Expected routes without a policy to use the default limit, got %d %v", w.Code, w.Header())
+	}
+
+	serve("/reports/daily")
+	if w := serve("/reports/weekly"); w.Code != http.StatusOK {
+		t.Errorf("Expected the second request of the group policy to be allowed, got %d", w.Code)
+	}
+	if w := serve("/reports/daily"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected routes sharing a named policy to share its limit, got %d", w.Code)
+	}
+}
+
+// TestRateLimitDeclaredPolicies tests that the plain middleware enforces policies declared on routes and groups
+func TestRateLimitDeclaredPolicies(t *testing.T) {
+	router := steel.NewRouter()
+	router.Use(RateLimit(RateLimitConfig{Store: NewGCRARateLimitStore(10, time.Minute, 0)}))
+
+	type output struct{}
+	handler := func(ctx *steel.Context, req struct{}) (*output, error) {
+		return &output{}, nil
+	}
+	router.OpinionatedGET("/items/:id", handler, steel.WithRateLimit(steel.RateLimitPolicy{Limit: 1, Period: time.Minute}))
+	router.Route("/reports", func(r steel.Router) {
+		r.RateLimit(steel.RateLimitPolicy{Limit: 1, Period: time.Minute})
+		r.OpinionatedGET("/daily", handler)
+	})
+
+	serve := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/items/1", "/reports/daily"} {
+		if w := serve(path); w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "1" {
+			t.Errorf("Expected the declared policy on %s, got %d %v", path, w.Code, w.Header())
+		}
+	}
+	if w := serve("/items/2"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the route policy to apply to every path of the route, got %d", w.Code)
+	}
+	if w := serve("/reports/daily"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected the group policy to reject, got %d", w.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/xraph/steel"
+)
+
+// TracingConfig configures the tracing middleware
+type TracingConfig struct {
+	// Tracer starts the spans, see steel.NewTracer
+	Tracer   *steel.Tracer
+	SkipFunc func(*http.Request) bool
+	// SpanName names the server span of a request, "METHOD /route/:pattern" when nil
+	SpanName func(*http.Request) string
+}
+
+// Tracing starts a server span per request, continuing the trace of W3C traceparent and tracestate
+// request headers and returning the span's traceparent in the response. Spans are named by route
+// pattern rather than URL path. Errors handled by the router are recorded on the span, 5xx responses
+// fail it, and WebSocket messages and SSE streams get child spans.
+//
+// Register it first so the spans cover the other middleware:
+//
+//	tracer := steel.NewTracer("orders", steel.NewStdoutSpanExporter(nil))
+//	router.Use(middleware.Tracing(middleware.TracingConfig{Tracer: tracer}))
+func Tracing(config TracingConfig) steel.MiddlewareFunc {
+	if config.Tracer == nil {
+		panic("tracing middleware requires a tracer")
+	}
+	if config.SpanName == nil {
+		config.SpanName = func(r *http.Request) string {
+			if route := steel.RoutePattern(r); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if config.SkipFunc != nil && config.SkipFunc(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := steel.ExtractTraceContext(r.Context(), r.Header)
+			ctx, span := config.Tracer.Start(ctx, config.SpanName(r), steel.SpanKindServer)
+			defer span.End()
+
+			span.SetAttribute("http.request.method", r.Method)
+			span.SetAttribute("url.path", r.URL.Path)
+			if route := steel.RoutePattern(r); route != "" {
+				span.SetAttribute("http.route", route)
+			}
+			span.SetAttribute("client.address", defaultRateLimitKeyFunc(r))
+			if userAgent := r.UserAgent(); userAgent != "" {
+				span.SetAttribute("user_agent.original", userAgent)
+			}
+
+			steel.InjectTraceContext(ctx, w.Header())
+
+			recorder := &tracingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r.WithContext(ctx))
+
+			if !recorder.hijacked {
+				span.SetAttribute("http.response.status_code", recorder.status)
+				if recorder.status >= http.StatusInternalServerError {
+					span.SetStatus(steel.SpanStatusError, http.StatusText(recorder.status))
+				}
+			}
+		})
+	}
+}
+
+// tracingResponseWriter records the response status while keeping the flushing and hijacking
+// that SSE and WebSocket endpoints need
+type tracingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *tracingResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *tracingResponseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *tracingResponseWriter) Flush() {
+	w.wroteHeader = true
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *tracingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *tracingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// traceIDFromResponse returns the trace ID of the traceparent set on a response by Tracing,
+// for middleware that runs outside of it and so does not see its span
+func traceIDFromResponse(header http.Header) string {
+	traceparent := header.Get(steel.TraceparentHeader)
+	if sc, err := steel.ParseTraceparent(traceparent); err == nil {
+		return sc.TraceID.String()
+	}
+	return ""
+}
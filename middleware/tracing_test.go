@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/xraph/steel"
+)
+
+// TestTracingMiddleware tests server spans, response propagation and log correlation
+func TestTracingMiddleware(t *testing.T) {
+	exporter := steel.NewInMemorySpanExporter()
+	var logs bytes.Buffer
+
+	router := steel.NewRouter()
+	router.Use(RequestLogging(LoggingConfig{Logger: log.New(&logs, "", 0)}))
+	router.Use(Tracing(TracingConfig{Tracer: steel.NewTracer("catalog", exporter)}))
+	router.GET("/products/:id", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	req := httptest.NewRequest("GET", "/products/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	spans := exporter.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("Expected one span, got %d", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /products/:id" || span.Attributes["http.route"] != "/products/:id" || span.Attributes["url.path"] != "/products/42" {
+		t.Errorf("Expected a span named by route pattern, got %s %v", span.Name, span.Attributes)
+	}
+	if span.Attributes["http.response.status_code"] != http.StatusServiceUnavailable || span.StatusCode != steel.SpanStatusError {
+		t.Errorf("Expected the 503 to fail the span, got %v %s", span.Attributes["http.response.status_code"], span.StatusCode)
+	}
+	if w.Header().Get("traceparent") != span.SpanContext.Traceparent() {
+		t.Errorf("Expected the span's traceparent on the response, got %q", w.Header().Get("traceparent"))
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace ID in the log entry, got %v", entry)
+	}
+}
+
+// TestSpanExporters tests the stdout and OTLP exporters
+func TestSpanExporters(t *testing.T) {
+	var out bytes.Buffer
+	tracer := steel.NewTracer("billing", steel.NewStdoutSpanExporter(&out))
+	_, span := tracer.Start(context.Background(), "charge", steel.SpanKindInternal)
+	span.SetAttribute("amount", 42)
+	span.End()
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatal(err)
+	}
+	if record["name"] != "charge" || record["trace_id"] != span.SpanContext.TraceID.String() || record["service"] != "billing" {
+		t.Errorf("Unexpected stdout record %v", record)
+	}
+
+	requests := make(chan []byte, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path == "/v1/traces" && r.Header.Get("Authorization") == "Bearer key" {
+			requests <- body
+		}
+	}))
+	defer collector.Close()
+
+	otlp := steel.NewOTLPSpanExporter(steel.OTLPConfig{
+		Endpoint: collector.URL + "/v1/traces",
+		Headers:  map[string]string{"Authorization": "Bearer key"},
+	})
+	tracer = steel.NewTracer("billing", otlp)
+	_, span = tracer.Start(context.Background(), "refund", steel.SpanKindServer)
+	span.RecordError(io.ErrUnexpectedEOF)
+	span.SetStatus(steel.SpanStatusError, "failed")
+	span.End()
+	if err := otlp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	body := string(<-requests)
+	for _, fragment := range []string{
+		`"key":"service.name","value":{"stringValue":"billing"}`,
+		`"name":"refund"`,
+		`"kind":2`,
+		`"traceId":"` + span.SpanContext.TraceID.String() + `"`,
+		`"status":{"code":2,"message":"failed"}`,
+		`"name":"exception"`,
+	} {
+		if !strings.Contains(body, fragment) {
+			t.Errorf("Expected %s in the OTLP request %s", fragment, body)
+		}
+	}
+}
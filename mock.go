@@ -0,0 +1,366 @@
+package steel
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	json "github.com/json-iterator/go"
+)
+
+// mockMaxDepth bounds generated data for recursive schemas.
+const mockMaxDepth = 8
+
+// NewMockRouter returns a router answering every operation of spec with example or generated data,
+// for working against an API before it is implemented. Path, query and header parameters and JSON bodies
+// are validated against the operation, answering 400 on mismatch.
+//
+// The first documented 2xx response is returned by default. A Prefer header selects another one:
+// "Prefer: code=404" returns the documented 404 response and "Prefer: example=name" the named example
+// of the response media type or of the spec components. Both can be combined, separated by commas.
+//
+// Responses use the first example found on the media type, then on its schema, and otherwise data
+// generated from the schema that satisfies its types, formats, enums and bounds.
+func NewMockRouter(spec *OpenAPISpec) *SteelRouter {
+	r := NewRouter()
+	r.openAPISpec = spec
+
+	index := &contractIndex{validator: newSchemaValidator(spec.Components.Schemas)}
+
+	templates := make([]string, 0, len(spec.Paths))
+	for template := range spec.Paths {
+		templates = append(templates, template)
+	}
+	sort.Strings(templates)
+
+	for _, template := range templates {
+		pathItem := spec.Paths[template]
+		methods := make([]string, 0, len(pathItem))
+		for method := range pathItem {
+			methods = append(methods, method)
+		}
+		sort.Strings(methods)
+
+		for _, method := range methods {
+			route := &contractRoute{
+				method:    strings.ToUpper(method),
+				template:  template,
+				segments:  strings.Split(strings.Trim(template, "/"), "/"),
+				operation: pathItem[method],
+			}
+			r.Handle(route.method, template, r.mockHandler(spec, index, route))
+		}
+	}
+
+	return r
+}
+
+// mockHandler answers requests for one operation.
+func (r *SteelRouter) mockHandler(spec *OpenAPISpec, index *contractIndex, route *contractRoute) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		params := make(map[string]string)
+		for _, parameter := range route.operation.Parameters {
+			if parameter.In == "path" {
+				params[parameter.Name] = URLParam(req, parameter.Name)
+			}
+		}
+
+		violations, err := index.validateRequest(route, req, params, DefaultContractMaxBodyBytes)
+		if err != nil {
+			r.handleError(w, req, PayloadTooLarge(fmt.Sprintf("Request body exceeds %d bytes", DefaultContractMaxBodyBytes)))
+			return
+		}
+		if len(violations) > 0 {
+			r.handleError(w, req, BadRequest("Request does not match the API contract", violations))
+			return
+		}
+
+		prefer := parsePreferHeader(req.Header.Get("Prefer"))
+
+		code, response, ok := selectMockResponse(route.operation.Responses, prefer["code"])
+		if !ok {
+			r.handleError(w, req, BadRequest(fmt.Sprintf("Response %s is not documented for %s %s", prefer["code"], route.method, route.template)))
+			return
+		}
+
+		status := mockStatusCode(code)
+		if len(response.Content) == 0 {
+			w.WriteHeader(status)
+			return
+		}
+
+		mediaType := "application/json"
+		if _, ok := response.Content[mediaType]; !ok {
+			mediaType = unionKeys(response.Content, nil)[0]
+		}
+		media := response.Content[mediaType]
+
+		var body interface{}
+		if name := prefer["example"]; name != "" {
+			example, ok := media.Examples[name]
+			if !ok {
+				example, ok = spec.Components.Examples[name]
+			}
+			if !ok {
+				r.handleError(w, req, BadRequest(fmt.Sprintf("Example %q is not documented for response %s", name, code)))
+				return
+			}
+			body = example.Value
+		} else {
+			body = mockMediaValue(index.validator, media)
+		}
+
+		var data []byte
+		if text, ok := body.(string); ok && !strings.HasSuffix(mediaType, "json") {
+			data = []byte(text)
+		} else {
+			// Examples are decoded into maps; the standard library config keeps their keys sorted
+			encoded, err := json.ConfigCompatibleWithStandardLibrary.Marshal(body)
+			if err != nil {
+				r.handleError(w, req, InternalServerError("Failed to encode mock response"))
+				return
+			}
+			data = append(encoded, '\n')
+		}
+
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+}
+
+// parsePreferHeader parses the preferences of a Prefer header (RFC 7240), such as "code=404, example=missing".
+func parsePreferHeader(header string) map[string]string {
+	preferences := make(map[string]string)
+	for _, part := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ';' }) {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		preferences[strings.ToLower(key)] = strings.Trim(value, `"`)
+	}
+	return preferences
+}
+
+// selectMockResponse returns the preferred response, or the first documented success response.
+func selectMockResponse(responses map[string]OpenAPIResponse, preferred string) (string, OpenAPIResponse, bool) {
+	if preferred != "" {
+		status, err := strconv.Atoi(preferred)
+		if err != nil {
+			response, ok := responses[preferred]
+			return preferred, response, ok
+		}
+		response, ok := documentedResponse(responses, status)
+		return preferred, response, ok
+	}
+
+	codes := unionKeys(responses, nil)
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			return code, responses[code], true
+		}
+	}
+	if response, ok := responses["default"]; ok {
+		return "default", response, true
+	}
+	if len(codes) > 0 {
+		return codes[0], responses[codes[0]], true
+	}
+	return "204", OpenAPIResponse{}, true
+}
+
+// mockStatusCode converts a response key such as "404", "2XX" or "default" to a status code.
+func mockStatusCode(code string) int {
+	if status, err := strconv.Atoi(code); err == nil {
+		return status
+	}
+	if len(code) == 3 && code[0] >= '1' && code[0] <= '5' {
+		return int(code[0]-'0') * 100
+	}
+	return http.StatusOK
+}
+
+// mockMediaValue returns the first example of a media type, falling back to its schema.
+func mockMediaValue(v *schemaValidator, media OpenAPIMediaType) interface{} {
+	if media.Example != nil {
+		return media.Example
+	}
+	if len(media.Examples) > 0 {
+		return media.Examples[unionKeys(media.Examples, nil)[0]].Value
+	}
+	return mockValue(v, media.Schema, 0)
+}
+
+// mockValue generates a value satisfying a schema, preferring the examples, const, default and enum it documents.
+func mockValue(v *schemaValidator, schema OpenAPISchema, depth int) interface{} {
+	schema, ok := v.resolve(schema)
+	if !ok || depth > mockMaxDepth {
+		return nil
+	}
+
+	switch {
+	case len(schema.Examples) > 0:
+		return schema.Examples[0]
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Const != nil:
+		return schema.Const
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	}
+
+	switch {
+	case len(schema.AllOf) > 0:
+		merged := make(map[string]interface{})
+		for _, member := range schema.AllOf {
+			if object, ok := mockValue(v, member, depth+1).(map[string]interface{}); ok {
+				for key, value := range object {
+					merged[key] = value
+				}
+			}
+		}
+		for key, value := range mockObject(v, schema, depth) {
+			merged[key] = value
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return mockValue(v, schema.OneOf[0], depth+1)
+	case len(schema.AnyOf) > 0:
+		return mockValue(v, schema.AnyOf[0], depth+1)
+	}
+
+	types := schemaTypes(schema.Type)
+	nullable := containsString(types, "null")
+	for _, t := range types {
+		if t == "null" {
+			continue
+		}
+		// Stop optional recursion early so self-referencing schemas stay small
+		if nullable && depth > 2 {
+			return nil
+		}
+		return mockTypedValue(v, t, schema, depth)
+	}
+
+	if len(schema.Properties) > 0 {
+		return mockObject(v, schema, depth)
+	}
+	return nil
+}
+
+func mockTypedValue(v *schemaValidator, t string, schema OpenAPISchema, depth int) interface{} {
+	switch t {
+	case "object":
+		return mockObject(v, schema, depth)
+	case "array":
+		count := 1
+		if schema.MinItems != nil && *schema.MinItems > count {
+			count = *schema.MinItems
+		}
+		if schema.MaxItems != nil && *schema.MaxItems < count {
+			count = *schema.MaxItems
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			if i < len(schema.PrefixItems) {
+				items = append(items, mockValue(v, schema.PrefixItems[i], depth+1))
+				continue
+			}
+			itemSchema, _ := schemaFromValue(schema.Items)
+			items = append(items, mockValue(v, itemSchema, depth+1))
+		}
+		return items
+	case "string":
+		return mockString(schema)
+	case "integer":
+		return int64(mockNumber(schema, true))
+	case "number":
+		return mockNumber(schema, false)
+	case "boolean":
+		return true
+	}
+	return nil
+}
+
+func mockObject(v *schemaValidator, schema OpenAPISchema, depth int) map[string]interface{} {
+	object := make(map[string]interface{}, len(schema.Properties))
+	for name, property := range schema.Properties {
+		value := mockValue(v, property, depth+1)
+		if value == nil && !containsString(schema.Required, name) {
+			continue
+		}
+		object[name] = value
+	}
+	return object
+}
+
+// mockFormatValues are sample values of the string formats the schema validator checks.
+var mockFormatValues = map[string]string{
+	"date-time": "2024-01-01T00:00:00Z",
+	"date":      "2024-01-01",
+	"time":      "00:00:00Z",
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"email":     "user@example.com",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+	"byte":      "c3RyaW5n",
+	"password":  "********",
+}
+
+func mockString(schema OpenAPISchema) string {
+	value, ok := mockFormatValues[schema.Format]
+	if !ok {
+		value = "string"
+	}
+	// Lengths count characters, as the schema validator does
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		value += strings.Repeat("x", *schema.MinLength-length)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		value = string([]rune(value)[:*schema.MaxLength])
+	}
+	return value
+}
+
+// mockNumber returns zero moved into the bounds of a schema.
+func mockNumber(schema OpenAPISchema, integer bool) float64 {
+	step := 0.5
+	if integer {
+		step = 1
+	}
+
+	lower, upper := math.Inf(-1), math.Inf(1)
+	if schema.Minimum != nil {
+		lower = *schema.Minimum
+	}
+	if limit, ok := exclusiveLimit(schema.ExclusiveMinimum, schema.Minimum); ok {
+		lower = limit + step
+	}
+	if schema.Maximum != nil {
+		upper = *schema.Maximum
+	}
+	if limit, ok := exclusiveLimit(schema.ExclusiveMaximum, schema.Maximum); ok {
+		upper = limit - step
+	}
+
+	switch {
+	case lower > 0:
+		if integer {
+			return math.Ceil(lower)
+		}
+		return lower
+	case upper < 0:
+		if integer {
+			return math.Floor(upper)
+		}
+		return upper
+	}
+	return 0
+}
@@ -12,26 +12,38 @@ type node struct {
 	wildcard  bool
 	isParam   bool
 	methods   map[string]HandlerFunc
+	route     string       // pattern of the route whose handler the node holds
+	info      *HandlerInfo // metadata of the route when it is an opinionated handler
+	kind      endpointKind // protocol served by the route
 }
 
+// endpointKind is the protocol served by a route
+type endpointKind int
+
+const (
+	endpointHTTP endpointKind = iota
+	endpointWebSocket
+	endpointSSE
+)
+
 // Enhanced node findHandler with proper path matching
 func (n *node) findHandler(path string, params *Params) HandlerFunc {
 	// Root path handling
 	if path == "/" {
 		if n.path == "/" && n.handler != nil {
-			return n.handler
+			return params.match(n)
 		}
 		// Check for root handler in children
 		for _, child := range n.children {
 			if child.path == "/" && child.handler != nil {
-				return child.handler
+				return params.match(child)
 			}
 		}
 	}
 
 	// Empty path - current node has handler
 	if path == "" && n.handler != nil {
-		return n.handler
+		return params.match(n)
 	}
 
 	// If path is empty, no match
@@ -46,7 +58,7 @@ func (n *node) findHandler(path string, params *Params) HandlerFunc {
 
 	// If path is now empty after removing slash, check for handler
 	if path == "" && n.handler != nil {
-		return n.handler
+		return params.match(n)
 	}
 
 	// Find the next segment
@@ -73,7 +85,7 @@ func (n *node) findHandler(path string, params *Params) HandlerFunc {
 			if remaining == "" {
 				// This is the end of the path
 				if child.handler != nil {
-					return child.handler
+					return params.match(child)
 				}
 			} else {
 				// Continue with remaining path
@@ -96,7 +108,7 @@ func (n *node) findHandler(path string, params *Params) HandlerFunc {
 		if remaining == "" {
 			// This is the end of the path
 			if child.handler != nil {
-				return child.handler
+				return params.match(child)
 			}
 		} else {
 			// Continue with remaining path
@@ -112,25 +124,25 @@ func (n *node) findHandler(path string, params *Params) HandlerFunc {
 	// Try wildcard children
 	for _, child := range n.children {
 		if child.wildcard && child.handler != nil {
-			return child.handler
+			return params.match(child)
 		}
 	}
 
 	return nil
 }
 
-// Fixed addRoute method
-func (n *node) addRoute(path string, handler HandlerFunc) {
+// Fixed addRoute method, returning the node holding the handler
+func (n *node) addRoute(path string, handler HandlerFunc) *node {
 	// Handle empty path
 	if path == "" {
 		n.handler = handler
-		return
+		return n
 	}
 
 	// Handle root path
 	if path == "/" {
 		n.handler = handler
-		return
+		return n
 	}
 
 	// Remove leading slash for processing
@@ -141,7 +153,7 @@ func (n *node) addRoute(path string, handler HandlerFunc) {
 	// If path is empty after removing slash, set handler on current node
 	if path == "" {
 		n.handler = handler
-		return
+		return n
 	}
 
 	// Find the first segment
@@ -183,11 +195,10 @@ func (n *node) addRoute(path string, handler HandlerFunc) {
 		if remaining == "" {
 			// This is the end of the path
 			paramChild.handler = handler
-		} else {
-			// Continue with remaining path
-			paramChild.addRoute(remaining, handler)
+			return paramChild
 		}
-		return
+		// Continue with remaining path
+		return paramChild.addRoute(remaining, handler)
 	}
 
 	// Handle wildcard segment
@@ -197,7 +208,7 @@ func (n *node) addRoute(path string, handler HandlerFunc) {
 			handler:  handler,
 		}
 		n.children = append(n.children, wildcardChild)
-		return
+		return wildcardChild
 	}
 
 	// Handle static segment
@@ -220,10 +231,10 @@ func (n *node) addRoute(path string, handler HandlerFunc) {
 	if remaining == "" {
 		// This is the end of the path
 		staticChild.handler = handler
-	} else {
-		// Continue with remaining path
-		staticChild.addRoute(remaining, handler)
+		return staticChild
 	}
+	// Continue with remaining path
+	return staticChild.addRoute(remaining, handler)
 }
 
 // Helper function to find longest common prefix
@@ -0,0 +1,364 @@
+package steel
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+
+	json "github.com/json-iterator/go"
+	"github.com/modern-go/reflect2"
+)
+
+// OneOfVariant is a concrete type of a polymorphic type, identified by a discriminator value.
+type OneOfVariant struct {
+	Value string
+	Type  reflect.Type
+}
+
+// Variant returns the variant V of a polymorphic type, identified by the discriminator value.
+func Variant[V any](value string) OneOfVariant {
+	return OneOfVariant{Value: value, Type: reflect.TypeOf((*V)(nil)).Elem()}
+}
+
+// oneOfType describes a polymorphic type registered with RegisterOneOf.
+type oneOfType struct {
+	typ           reflect.Type
+	discriminator string
+	variants      []*oneOfVariant
+	byValue       map[string]*oneOfVariant
+	byType        map[reflect.Type]*oneOfVariant
+}
+
+type oneOfVariant struct {
+	value string
+	typ   reflect.Type
+	// pointer is set when *typ implements the interface, or when the tagged-union field is a pointer
+	pointer bool
+	// field is the index of the variant in a tagged-union struct, -1 for interfaces
+	field int
+	// property is the index of the discriminator field in the variant, nil when it has none
+	property []int
+}
+
+var (
+	oneOfMu    sync.RWMutex
+	oneOfTypes = make(map[reflect.Type]*oneOfType)
+)
+
+// RegisterOneOf registers the variants of a polymorphic type T, told apart by the discriminator property
+// of their JSON objects.
+//
+// T is either an interface implemented by every variant, by value or by pointer, or a tagged-union struct
+// with one field of type V or *V per variant, of which exactly one is set. The schema of T becomes a oneOf
+// of the variant components with a discriminator mapping, request bodies decode into the variant named by
+// the discriminator value, and responses encode the variant with its discriminator value. Variants without
+// a field for the discriminator property get it added to their schema and their encoding.
+//
+// Types are registered globally, like gob.Register, for every router and every jsoniter configuration,
+// and are identified by package path and name. Register them before the routes using them and before
+// they are first encoded or decoded, as encoders are cached on first use:
+//
+//	steel.RegisterOneOf[Shape]("kind", steel.Variant[Circle]("circle"), steel.Variant[Square]("square"))
+func RegisterOneOf[T any](discriminator string, variants ...OneOfVariant) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Interface && t.Kind() != reflect.Struct {
+		panic("RegisterOneOf type must be an interface or a tagged-union struct")
+	}
+	if t.Name() == "" {
+		panic("RegisterOneOf type must be a named type")
+	}
+
+	union := &oneOfType{
+		typ:           t,
+		discriminator: discriminator,
+		byValue:       make(map[string]*oneOfVariant),
+		byType:        make(map[reflect.Type]*oneOfVariant),
+	}
+
+	for _, v := range variants {
+		vt := v.Type
+		if vt.Kind() == reflect.Ptr {
+			vt = vt.Elem()
+		}
+		if vt.Kind() != reflect.Struct || vt.Name() == "" {
+			panic(fmt.Sprintf("variant %s of %s must be a named struct", v.Type, t))
+		}
+		if _, exists := union.byValue[v.Value]; exists {
+			panic(fmt.Sprintf("duplicate %s value %q for %s", discriminator, v.Value, t))
+		}
+
+		variant := &oneOfVariant{
+			value:    v.Value,
+			typ:      vt,
+			field:    -1,
+			property: jsonFieldIndex(vt, discriminator),
+		}
+
+		if t.Kind() == reflect.Interface {
+			switch {
+			case vt.Implements(t):
+			case reflect.PointerTo(vt).Implements(t):
+				variant.pointer = true
+			default:
+				panic(fmt.Sprintf("variant %s does not implement %s", vt, t))
+			}
+		} else {
+			for i := 0; i < t.NumField(); i++ {
+				switch t.Field(i).Type {
+				case vt:
+					variant.field = i
+				case reflect.PointerTo(vt):
+					variant.field, variant.pointer = i, true
+				}
+			}
+			if variant.field < 0 {
+				panic(fmt.Sprintf("tagged union %s has no field of type %s or *%s", t, vt, vt))
+			}
+		}
+
+		union.variants = append(union.variants, variant)
+		union.byValue[variant.value] = variant
+		union.byType[vt] = variant
+	}
+
+	oneOfMu.Lock()
+	oneOfTypes[t] = union
+	oneOfMu.Unlock()
+
+	oneOfExtensionOnce.Do(func() {
+		json.RegisterExtension(&oneOfExtension{})
+	})
+}
+
+var oneOfExtensionOnce sync.Once
+
+// oneOfExtension encodes and decodes registered polymorphic types. Types are matched by identity,
+// unlike jsoniter's type registry keyed by type name, so same-named types of different packages
+// never collide. It applies to every jsoniter configuration.
+type oneOfExtension struct {
+	json.DummyExtension
+}
+
+func (e *oneOfExtension) CreateDecoder(typ reflect2.Type) json.ValDecoder {
+	if union := lookupOneOf(typ.Type1()); union != nil {
+		return union
+	}
+	return nil
+}
+
+func (e *oneOfExtension) CreateEncoder(typ reflect2.Type) json.ValEncoder {
+	if union := lookupOneOf(typ.Type1()); union != nil {
+		return union
+	}
+	return nil
+}
+
+// Decode implements json.ValDecoder.
+func (o *oneOfType) Decode(ptr unsafe.Pointer, iter *json.Iterator) {
+	data := iter.SkipAndReturnBytes()
+	if iter.Error != nil {
+		return
+	}
+	value, err := o.decode(data)
+	if err != nil {
+		iter.ReportError("decode "+o.typ.String(), err.Error())
+		return
+	}
+	reflect.NewAt(o.typ, ptr).Elem().Set(value)
+}
+
+// Encode implements json.ValEncoder.
+func (o *oneOfType) Encode(ptr unsafe.Pointer, stream *json.Stream) {
+	data, err := o.encode(reflect.NewAt(o.typ, ptr).Elem())
+	if err != nil {
+		stream.Error = err
+		return
+	}
+	stream.Write(data)
+}
+
+// IsEmpty implements json.ValEncoder.
+func (o *oneOfType) IsEmpty(ptr unsafe.Pointer) bool {
+	return reflect.NewAt(o.typ, ptr).Elem().IsZero()
+}
+
+// lookupOneOf returns the registration of a polymorphic type, or nil.
+func lookupOneOf(t reflect.Type) *oneOfType {
+	oneOfMu.RLock()
+	defer oneOfMu.RUnlock()
+	return oneOfTypes[t]
+}
+
+// values returns the discriminator values in registration order.
+func (o *oneOfType) values() []string {
+	values := make([]string, len(o.variants))
+	for i, variant := range o.variants {
+		values[i] = variant.value
+	}
+	return values
+}
+
+// decode decodes a JSON object into the variant named by its discriminator value.
+func (o *oneOfType) decode(data []byte) (reflect.Value, error) {
+	value := reflect.New(o.typ).Elem()
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return value, nil
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return value, fmt.Errorf("%s must be a JSON object", o.typ.Name())
+	}
+	raw, ok := object[o.discriminator]
+	if !ok {
+		return value, fmt.Errorf("missing discriminator property %q", o.discriminator)
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err != nil {
+		return value, fmt.Errorf("discriminator property %q must be a string", o.discriminator)
+	}
+	variant, ok := o.byValue[name]
+	if !ok {
+		return value, fmt.Errorf("unknown %s %q, expected one of: %s", o.discriminator, name, strings.Join(o.values(), ", "))
+	}
+
+	concrete := reflect.New(variant.typ)
+	if err := json.Unmarshal(data, concrete.Interface()); err != nil {
+		return value, err
+	}
+	if !variant.pointer {
+		concrete = concrete.Elem()
+	}
+
+	if variant.field >= 0 {
+		value.Field(variant.field).Set(concrete)
+	} else {
+		value.Set(concrete)
+	}
+	return value, nil
+}
+
+// encode encodes the variant held by value, adding its discriminator value when missing.
+func (o *oneOfType) encode(value reflect.Value) ([]byte, error) {
+	var concrete reflect.Value
+	if o.typ.Kind() == reflect.Interface {
+		concrete = value.Elem()
+	} else {
+		for _, variant := range o.variants {
+			if field := value.Field(variant.field); !field.IsZero() {
+				concrete = field
+				break
+			}
+		}
+	}
+	for concrete.IsValid() && concrete.Kind() == reflect.Ptr {
+		if concrete.IsNil() {
+			return []byte("null"), nil
+		}
+		concrete = concrete.Elem()
+	}
+	if !concrete.IsValid() {
+		return []byte("null"), nil
+	}
+
+	variant, ok := o.byType[concrete.Type()]
+	if !ok {
+		return nil, fmt.Errorf("%s is not a registered variant of %s", concrete.Type(), o.typ)
+	}
+
+	// Encode a copy so the discriminator can be filled without touching the caller's value
+	copied := reflect.New(variant.typ)
+	copied.Elem().Set(concrete)
+
+	if variant.property != nil {
+		if field := copied.Elem().FieldByIndex(variant.property); field.Kind() == reflect.String && field.String() == "" {
+			field.SetString(variant.value)
+		}
+		return json.Marshal(copied.Interface())
+	}
+
+	data, err := json.Marshal(copied.Interface())
+	if err != nil {
+		return nil, err
+	}
+	key, _ := json.Marshal(o.discriminator)
+	name, _ := json.Marshal(variant.value)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	buf.Write(key)
+	buf.WriteByte(':')
+	buf.Write(name)
+	if object := bytes.TrimSpace(data); len(object) > 2 {
+		buf.WriteByte(',')
+		buf.Write(object[1:])
+	} else {
+		buf.WriteByte('}')
+	}
+	return buf.Bytes(), nil
+}
+
+// oneOfComponentSchema registers a polymorphic type in components as a oneOf of its variants with a
+// discriminator mapping, adding the discriminator property to each variant schema.
+func (r *SteelRouter) oneOfComponentSchema(union *oneOfType, components map[string]OpenAPISchema) OpenAPISchema {
+	schemaName := union.typ.Name()
+
+	if _, exists := components[schemaName]; !exists {
+		// Temporarily set a placeholder to prevent infinite recursion
+		components[schemaName] = OpenAPISchema{Type: "object"}
+
+		schema := OpenAPISchema{
+			Discriminator: &OpenAPIDiscriminator{
+				PropertyName: union.discriminator,
+				Mapping:      make(map[string]string, len(union.variants)),
+			},
+		}
+
+		for _, variant := range union.variants {
+			ref := r.typeToComponentSchema(variant.typ, components)
+
+			variantSchema := components[variant.typ.Name()]
+			if variantSchema.Properties == nil {
+				variantSchema.Properties = make(map[string]OpenAPISchema)
+			}
+			property := variantSchema.Properties[union.discriminator]
+			property.Type = "string"
+			property.Const = variant.value
+			variantSchema.Properties[union.discriminator] = property
+			if !containsString(variantSchema.Required, union.discriminator) {
+				variantSchema.Required = append(variantSchema.Required, union.discriminator)
+			}
+			components[variant.typ.Name()] = variantSchema
+
+			schema.OneOf = append(schema.OneOf, ref)
+			schema.Discriminator.Mapping[variant.value] = ref.Ref
+		}
+
+		components[schemaName] = schema
+	}
+
+	return OpenAPISchema{
+		Ref: "#/components/schemas/" + schemaName,
+	}
+}
+
+// jsonFieldIndex returns the index of the struct field encoded under a JSON name, or nil.
+func jsonFieldIndex(t reflect.Type, name string) []int {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Anonymous {
+			continue
+		}
+		tagName, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if tagName == "-" {
+			continue
+		}
+		if tagName == name || (tagName == "" && field.Name == name) {
+			return field.Index
+		}
+	}
+	return nil
+}
@@ -12,6 +12,7 @@ const (
 	SecurityTypeHTTP          = "http"
 	SecurityTypeOAuth2        = "oauth2"
 	SecurityTypeOpenIDConnect = "openIdConnect"
+	SecurityTypeMutualTLS     = "mutualTLS"
 )
 
 // API Key locations
@@ -255,6 +256,12 @@ func BasicAuth(description string) OpenAPISecurityScheme {
 		Build()
 }
 
+func MutualTLS(description string) OpenAPISecurityScheme {
+	return NewSecurityScheme(SecurityTypeMutualTLS).
+		Description(description).
+		Build()
+}
+
 func OAuth2AuthorizationCode(authURL, tokenURL string, scopes map[string]string, description string) OpenAPISecurityScheme {
 	flows := &OpenAPIOAuth2Flows{
 		AuthorizationCode: &OpenAPIOAuth2Flow{
@@ -286,37 +293,45 @@ func OAuth2ClientCredentials(tokenURL string, scopes map[string]string, descript
 
 // RegisterSecurityScheme Enhanced Router methods for security
 func (r *SteelRouter) RegisterSecurityScheme(name string, scheme OpenAPISecurityScheme) {
-	if r.securityProvider == nil {
-		r.securityProvider = NewDefaultSecurityProvider()
-	}
+	r.updateSpec(func() {
+		if r.securityProvider == nil {
+			r.securityProvider = NewDefaultSecurityProvider()
+		}
 
-	if defaultProvider, ok := r.securityProvider.(*DefaultSecurityProvider); ok {
-		defaultProvider.schemes[name] = scheme
-	}
+		if defaultProvider, ok := r.securityProvider.(*DefaultSecurityProvider); ok {
+			defaultProvider.schemes[name] = scheme
+		}
 
-	// Ensure components exist
-	if r.openAPISpec.Components.SecuritySchemes == nil {
-		r.openAPISpec.Components.SecuritySchemes = make(map[string]OpenAPISecurityScheme)
-	}
+		// Ensure components exist
+		if r.openAPISpec.Components.SecuritySchemes == nil {
+			r.openAPISpec.Components.SecuritySchemes = make(map[string]OpenAPISecurityScheme)
+		}
 
-	r.openAPISpec.Components.SecuritySchemes[name] = scheme
+		r.openAPISpec.Components.SecuritySchemes[name] = scheme
+	})
 }
 
 func (r *SteelRouter) SetSecurityProvider(provider SecurityProvider) {
-	r.securityProvider = provider
+	r.updateSpec(func() {
+		r.securityProvider = provider
 
-	// Re-register schemes with the new provider
-	if r.openAPISpec != nil {
-		provider.RegisterSecuritySchemes(r.openAPISpec)
-	}
+		// Re-register schemes with the new provider
+		if r.openAPISpec != nil {
+			provider.RegisterSecuritySchemes(r.openAPISpec)
+		}
+	})
 }
 
 func (r *SteelRouter) SetOpenAPIInfo(info OpenAPIInfo) {
-	r.openAPISpec.Info = info
+	r.updateSpec(func() {
+		r.openAPISpec.Info = info
+	})
 }
 
 func (r *SteelRouter) AddServer(server OpenAPIServer) {
-	r.openAPISpec.Servers = append(r.openAPISpec.Servers, server)
+	r.updateSpec(func() {
+		r.openAPISpec.Servers = append(r.openAPISpec.Servers, server)
+	})
 }
 
 func (r *SteelRouter) SetJSONSchemaDialect(dialect string) {
@@ -335,26 +350,35 @@ func (r *SteelRouter) SetJSONSchemaDialect(dialect string) {
 		return
 	}
 
-	r.openAPISpec.JSONSchemaDialect = dialect
+	r.updateSpec(func() {
+		r.openAPISpec.JSONSchemaDialect = dialect
+	})
 }
 
 // AddWebhook Add webhook support (new in OpenAPI 3.1.1)
 func (r *SteelRouter) AddWebhook(name string, pathItem OpenAPIPath) {
-	if r.openAPISpec.Webhooks == nil {
-		r.openAPISpec.Webhooks = make(map[string]OpenAPIPath)
-	}
-	r.openAPISpec.Webhooks[name] = pathItem
+	r.updateSpec(func() {
+		if r.openAPISpec.Webhooks == nil {
+			r.openAPISpec.Webhooks = make(map[string]OpenAPIPath)
+		}
+		r.openAPISpec.Webhooks[name] = pathItem
+	})
 }
 
 // SetGlobalSecurity Global security requirements (applied to all operations unless overridden)
 func (r *SteelRouter) SetGlobalSecurity(requirements ...OpenAPISecurityRequirement) {
-	r.globalSecurity = requirements
+	r.updateSpec(func() {
+		r.globalSecurity = requirements
+	})
 }
 
+// GetOpenAPISpec returns a deep copy of the OpenAPI specification, unaffected by later registrations.
 func (r *SteelRouter) GetOpenAPISpec() *OpenAPISpec {
-	// Return a copy to prevent external modifications
-	spec := *r.openAPISpec
-	return &spec
+	var spec *OpenAPISpec
+	r.readSpec(func() {
+		spec = copySpec(r.openAPISpec)
+	})
+	return spec
 }
 
 // generateBaseOperation creates the base OpenAPI operation from handler info
@@ -470,6 +494,8 @@ func (r *SteelRouter) generateBaseOperation(info *HandlerInfo) OpenAPIOperation
 func (r *SteelRouter) generateOpenAPIForHandlerWithSecurity(info *HandlerInfo) {
 	// Generate base operation
 	operation := r.generateBaseOperation(info)
+	r.addRateLimitDocumentation(&operation, info.RateLimit)
+	r.addTimeoutDocumentation(&operation, info.Timeout)
 
 	// Add security requirements
 	if len(info.SecurityRequirements) > 0 {
@@ -322,40 +322,57 @@ func (b *OpenAPIBuilder) WithEnterpriseDefaults(title, version string) *OpenAPIB
 
 // Build applies all the configuration to the router and enables OpenAPI
 func (b *OpenAPIBuilder) Build() *SteelRouter {
-	// Apply info configuration
-	if b.info.Title != "" || b.info.Version != "" || b.info.Description != "" {
-		// Merge with existing info, preserving any existing values for unset fields
-		currentInfo := b.router.openAPISpec.Info
+	b.router.updateSpec(func() {
+		spec := b.router.openAPISpec
+
+		// Apply info configuration, merging with existing info and preserving any existing values for unset fields
 		if b.info.Title != "" {
-			currentInfo.Title = b.info.Title
+			spec.Info.Title = b.info.Title
 		}
 		if b.info.Version != "" {
-			currentInfo.Version = b.info.Version
+			spec.Info.Version = b.info.Version
 		}
 		if b.info.Description != "" {
-			currentInfo.Description = b.info.Description
+			spec.Info.Description = b.info.Description
 		}
 		if b.info.Summary != "" {
-			currentInfo.Summary = b.info.Summary
+			spec.Info.Summary = b.info.Summary
 		}
 		if b.info.TermsOfService != "" {
-			currentInfo.TermsOfService = b.info.TermsOfService
+			spec.Info.TermsOfService = b.info.TermsOfService
 		}
 		if b.info.Contact != nil {
-			currentInfo.Contact = b.info.Contact
+			spec.Info.Contact = b.info.Contact
 		}
 		if b.info.License != nil {
-			currentInfo.License = b.info.License
+			spec.Info.License = b.info.License
 		}
 
-		b.router.openAPISpec.Info = currentInfo
-		b.router.EnableOpenAPI()
-	}
+		// Apply servers
+		if len(b.servers) > 0 {
+			spec.Servers = b.servers
+		}
 
-	// Apply servers
-	if len(b.servers) > 0 {
-		b.router.openAPISpec.Servers = b.servers
-	}
+		// Apply tags
+		if len(b.tags) > 0 {
+			spec.Tags = b.tags
+		}
+
+		// Apply external docs
+		if b.externalDocs != nil {
+			spec.ExternalDocs = b.externalDocs
+		}
+
+		// Apply webhooks
+		if len(b.webhooks) > 0 {
+			if spec.Webhooks == nil {
+				spec.Webhooks = make(map[string]OpenAPIPath)
+			}
+			for name, pathItem := range b.webhooks {
+				spec.Webhooks[name] = pathItem
+			}
+		}
+	})
 
 	// Apply security schemes
 	for name, scheme := range b.securitySchemes {
@@ -367,31 +384,11 @@ func (b *OpenAPIBuilder) Build() *SteelRouter {
 		b.router.SetGlobalSecurity(b.globalSecurity...)
 	}
 
-	// Apply tags
-	if len(b.tags) > 0 {
-		b.router.openAPISpec.Tags = b.tags
-	}
-
-	// Apply external docs
-	if b.externalDocs != nil {
-		b.router.openAPISpec.ExternalDocs = b.externalDocs
-	}
-
 	// Apply JSON Schema dialect
 	if b.jsonDialect != "" {
 		b.router.SetJSONSchemaDialect(b.jsonDialect)
 	}
 
-	// Apply webhooks
-	if len(b.webhooks) > 0 {
-		if b.router.openAPISpec.Webhooks == nil {
-			b.router.openAPISpec.Webhooks = make(map[string]OpenAPIPath)
-		}
-		for name, pathItem := range b.webhooks {
-			b.router.openAPISpec.Webhooks[name] = pathItem
-		}
-	}
-
 	// Enable OpenAPI
 	b.router.EnableOpenAPI()
 
@@ -405,7 +402,7 @@ func (b *OpenAPIBuilder) Build() *SteelRouter {
 // Preview returns the current OpenAPI specification without applying it
 func (b *OpenAPIBuilder) Preview() *OpenAPISpec {
 	// Create a temporary copy of the router's spec
-	preview := *b.router.openAPISpec
+	preview := *b.router.GetOpenAPISpec()
 
 	// Apply all configurations to the preview
 	if b.info.Title != "" {
@@ -0,0 +1,108 @@
+package steel
+
+import (
+	"time"
+)
+
+// RateLimitPolicy declares the rate limit of opinionated operations. Policies are enforced by the
+// rate limit middlewares and documented in the OpenAPI specification.
+type RateLimitPolicy struct {
+	// Name identifies the policy. Operations sharing a name share their limits; operations
+	// with an unnamed policy are limited separately.
+	Name string
+	// Limit is the number of requests allowed per period
+	Limit int
+	// Period is the time over which Limit requests are allowed
+	Period time.Duration
+	// Burst is the number of requests allowed at once, Limit when zero
+	Burst int
+}
+
+// Key returns the name the limits of the policy are kept under for an operation
+func (p RateLimitPolicy) Key(info *HandlerInfo) string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return info.Method + " " + info.Path
+}
+
+// RateLimitExtension is the OpenAPI extension documenting the rate limit policy of an operation,
+// with its limit, burst, optional name and periodMs, the period in milliseconds
+const RateLimitExtension = "x-ratelimit"
+
+// WithRateLimit sets the rate limit policy of an operation, overriding the policy of its group
+func WithRateLimit(policy RateLimitPolicy) HandlerOption {
+	if policy.Limit <= 0 || policy.Period <= 0 {
+		panic("rate limit policy requires a positive limit and period")
+	}
+	return func(h *HandlerInfo) {
+		h.RateLimit = &policy
+	}
+}
+
+// RateLimit sets the rate limit policy of the opinionated routes registered afterwards
+// that do not set their own with WithRateLimit
+func (r *SteelRouter) RateLimit(policy RateLimitPolicy) {
+	r.rateLimit = WithRateLimit(policy)
+}
+
+// RateLimit sets the rate limit policy of the opinionated routes registered afterwards on the
+// group and its subgroups that do not set their own with WithRateLimit
+func (g *RouteGroup) RateLimit(policy RateLimitPolicy) {
+	g.rateLimit = WithRateLimit(policy)
+}
+
+// addRateLimitDocumentation documents the rate limit policy of an operation with the
+// x-ratelimit extension and a 429 response
+func (r *SteelRouter) addRateLimitDocumentation(operation *OpenAPIOperation, policy *RateLimitPolicy) {
+	if policy == nil {
+		return
+	}
+
+	burst := policy.Burst
+	if burst <= 0 {
+		burst = policy.Limit
+	}
+	extension := map[string]interface{}{
+		"limit":    policy.Limit,
+		"periodMs": policy.Period.Milliseconds(),
+		"burst":    burst,
+	}
+	if policy.Name != "" {
+		extension["name"] = policy.Name
+	}
+	if operation.Extensions == nil {
+		operation.Extensions = make(map[string]interface{})
+	}
+	operation.Extensions[RateLimitExtension] = extension
+
+	r.ensureErrorSchemasRegistered()
+	integer := OpenAPISchema{Type: "integer"}
+	operation.Responses["429"] = OpenAPIResponse{
+		Description: "Too Many Requests - Rate limit exceeded",
+		Headers: map[string]OpenAPIHeader{
+			"Retry-After": {
+				Description: "Seconds to wait before retrying the request",
+				Required:    true,
+				Schema:      integer,
+			},
+			"RateLimit-Limit": {
+				Description: "Requests allowed when the limit is fully replenished",
+				Schema:      integer,
+			},
+			"RateLimit-Remaining": {
+				Description: "Requests remaining in the current limit",
+				Schema:      integer,
+			},
+			"RateLimit-Reset": {
+				Description: "Seconds until the limit is fully replenished",
+				Schema:      integer,
+			},
+		},
+		Content: map[string]OpenAPIMediaType{
+			"application/json": {
+				Schema: OpenAPISchema{Ref: "#/components/schemas/ErrorResponse"},
+			},
+		},
+	}
+}
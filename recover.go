@@ -0,0 +1,58 @@
+package steel
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicError is a panic recovered while serving a request, WebSocket message or SSE stream
+type PanicError struct {
+	// Value is the value passed to panic
+	Value interface{}
+	// Stack is the stack trace of the goroutine that panicked
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the panic value when it is an error
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// PanicReporter receives recovered panics, for instance to send them to an error tracker.
+// It is called from the goroutine that panicked and must not panic itself.
+type PanicReporter func(req *http.Request, err *PanicError)
+
+// OnPanic sets the reporter called with every panic recovered by Recoverer and by
+// WebSocket, JSON-RPC and SSE handlers
+func (r *SteelRouter) OnPanic(reporter PanicReporter) {
+	r.panicReporter = reporter
+}
+
+// reportPanic captures the stack of a recovered panic, logs it to the request logger and hands it to
+// the panic reporter. It must be called from the deferred function that recovered the panic.
+func (r *SteelRouter) reportPanic(req *http.Request, value interface{}) *PanicError {
+	// Panics re-raised from another goroutine, such as by Timeout, keep their original stack
+	panicErr, ok := value.(*PanicError)
+	if !ok {
+		panicErr = &PanicError{Value: value, Stack: debug.Stack()}
+	}
+
+	RequestLogger(req).Error("panic recovered", "panic", panicErr.Value, "stack", string(panicErr.Stack))
+	if r != nil && r.panicReporter != nil {
+		r.panicReporter(req, panicErr)
+	}
+	return panicErr
+}
+
+// isPanicError reports whether err is a recovered panic, whose value is not exposed in responses
+func isPanicError(err error) bool {
+	var panicErr *PanicError
+	return errors.As(err, &panicErr)
+}
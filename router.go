@@ -23,6 +23,9 @@ type SteelRouter struct {
 	options               RouterOptions
 	openAPISpec           *OpenAPISpec
 	asyncAPISpec          *AsyncAPISpec
+	asyncAPI3Spec         *AsyncAPI3Spec
+	asyncAPIChannelIDs    map[string]string
+	asyncAPICustomServers bool
 	handlers              map[string]*HandlerInfo
 	wsHandlers            map[string]*WSHandlerInfo
 	sseHandlers           map[string]*SSEHandlerInfo
@@ -30,6 +33,9 @@ type SteelRouter struct {
 	securityProvider      SecurityProvider
 	globalSecurity        []OpenAPISecurityRequirement
 	opinionatedMiddleware *MiddlewareChain
+	rateLimit             HandlerOption
+	panicReporter         PanicReporter
+	specs                 specState
 }
 
 // RouterOptions holds router configuration
@@ -41,6 +47,8 @@ type RouterOptions struct {
 	OpenAPITitle           string
 	OpenAPIVersion         string
 	OpenAPIDescription     string
+	AsyncAPIVersion        string
+	SpecPaths              SpecPaths
 }
 
 // OpinionatedHandler is the new handler type with automatic OpenAPI generation
@@ -59,6 +67,8 @@ type HandlerInfo struct {
 	SecurityRequirements []OpenAPISecurityRequirement
 	Deprecated           bool
 	OperationID          string
+	RateLimit            *RateLimitPolicy
+	Timeout              time.Duration
 }
 
 // OpenAPISpec OpenAPI Schema Types
@@ -118,6 +128,46 @@ type OpenAPIOperation struct {
 	OperationID  string                       `json:"operationId,omitempty"`
 	ExternalDocs *OpenAPIExternalDocs         `json:"externalDocs,omitempty"`
 	Servers      []OpenAPIServer              `json:"servers,omitempty"`
+	Extensions   map[string]interface{}       `json:"-"` // x- extensions, inlined when marshaled
+}
+
+// MarshalJSON writes the operation with its x- extensions as fields
+func (o OpenAPIOperation) MarshalJSON() ([]byte, error) {
+	type operation OpenAPIOperation
+	data, err := json.Marshal(operation(o))
+	if err != nil || len(o.Extensions) == 0 {
+		return data, err
+	}
+
+	extensions, err := json.Marshal(o.Extensions)
+	if err != nil {
+		return nil, err
+	}
+	data = append(data[:len(data)-1], ',')
+	return append(data, extensions[1:]...), nil
+}
+
+// UnmarshalJSON reads the operation, collecting its x- fields into Extensions
+func (o *OpenAPIOperation) UnmarshalJSON(data []byte) error {
+	type operation OpenAPIOperation
+	if err := json.Unmarshal(data, (*operation)(o)); err != nil {
+		return err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	o.Extensions = nil
+	for name, value := range fields {
+		if strings.HasPrefix(name, "x-") {
+			if o.Extensions == nil {
+				o.Extensions = make(map[string]interface{})
+			}
+			o.Extensions[name] = value
+		}
+	}
+	return nil
 }
 
 type OpenAPIParameter struct {
@@ -136,11 +186,14 @@ type OpenAPIRequestBody struct {
 
 type OpenAPIResponse struct {
 	Description string                      `json:"description"`
+	Headers     map[string]OpenAPIHeader    `json:"headers,omitempty"`
 	Content     map[string]OpenAPIMediaType `json:"content,omitempty"`
 }
 
 type OpenAPIMediaType struct {
-	Schema OpenAPISchema `json:"schema"`
+	Schema   OpenAPISchema             `json:"schema"`
+	Example  interface{}               `json:"example,omitempty"`
+	Examples map[string]OpenAPIExample `json:"examples,omitempty"`
 }
 
 type OpenAPISchema struct {
@@ -302,6 +355,10 @@ type HandlerFunc func(http.ResponseWriter, *http.Request)
 type Params struct {
 	keys   []string
 	values []string
+	route  string
+	info   *HandlerInfo
+	kind   endpointKind
+	router *SteelRouter
 }
 
 func (p *Params) Get(key string) string {
@@ -327,11 +384,43 @@ func (p *Params) Set(key, value string) {
 func (p *Params) Reset() {
 	p.keys = p.keys[:0]
 	p.values = p.values[:0]
+	p.route = ""
+	p.info = nil
+	p.kind = endpointHTTP
+	p.router = nil
+}
+
+// clone returns a copy of the parameters that does not share storage with the pooled value
+func (p *Params) clone() *Params {
+	c := *p
+	c.keys = append([]string(nil), p.keys...)
+	c.values = append([]string(nil), p.values...)
+	return &c
+}
+
+// Route returns the pattern of the matched route, such as "/users/:id"
+func (p *Params) Route() string {
+	return p.route
+}
+
+// HandlerInfo returns the metadata of the matched opinionated route, or nil for other routes
+func (p *Params) HandlerInfo() *HandlerInfo {
+	return p.info
+}
+
+// match records the route of a matched node and returns its handler
+func (p *Params) match(n *node) HandlerFunc {
+	if n.handler != nil {
+		p.route = n.route
+		p.info = n.info
+		p.kind = n.kind
+	}
+	return n.handler
 }
 
 // NewRouter creates a new SteelRouter instance
 func NewRouter() *SteelRouter {
-	return &SteelRouter{
+	r := &SteelRouter{
 		trees: make(map[string]*node),
 		pool: sync.Pool{
 			New: func() interface{} {
@@ -370,6 +459,10 @@ func NewRouter() *SteelRouter {
 		securityProvider:  NewDefaultSecurityProvider(),
 		globalSecurity:    make([]OpenAPISecurityRequirement, 0),
 	}
+
+	// Create the AsyncAPI documents up front so they are never assigned while a document is being built
+	r.initAsyncAPI()
+	return r
 }
 
 // Router interface for consistent API
@@ -379,6 +472,8 @@ type Router interface {
 	UseOpinionated(middleware ...OpinionatedMiddleware)
 	UseOpinionatedIf(condition bool, middleware ...OpinionatedMiddleware)
 
+	RateLimit(policy RateLimitPolicy)
+
 	Group() Router
 	GroupFunc(fn func(r Router)) Router
 	Route(pattern string, fn func(r Router)) Router
@@ -405,6 +500,7 @@ type Router interface {
 	// Async handlers with AsyncAPI generation
 	WebSocket(pattern string, handler interface{}, opts ...AsyncHandlerOption)
 	SSE(pattern string, handler interface{}, opts ...AsyncHandlerOption)
+	JSONRPC(pattern string, opts ...AsyncHandlerOption) *JSONRPCEndpoint
 }
 
 // HandlerOption for configuring opinionated handlers
@@ -518,7 +614,10 @@ func (r *SteelRouter) registerOpinionatedHandlerWithMiddleware(method, pattern s
 		Handler:    handler,
 	}
 
-	// Apply options
+	// Apply options after the router's default rate limit policy
+	if r.rateLimit != nil {
+		r.rateLimit(info)
+	}
 	for _, opt := range opts {
 		opt(info)
 	}
@@ -528,11 +627,16 @@ func (r *SteelRouter) registerOpinionatedHandlerWithMiddleware(method, pattern s
 	r.handlers[key] = info
 
 	// Generate OpenAPI spec for this handler with middleware enhancements
-	r.generateOpenAPIForHandlerWithMiddleware(info)
+	r.updateSpec(func() {
+		r.generateOpenAPIForHandlerWithMiddleware(info)
+	})
 
 	// Create wrapper with middleware support
 	wrapper := r.createOpinionatedWrapperWithMiddleware(handler, inputType, outputType, info)
-	r.addRoute(method, pattern, wrapper)
+	if info.Timeout > 0 {
+		wrapper = r.timeoutHandler(wrapper, info.Timeout)
+	}
+	r.addRoute(method, pattern, wrapper).info = info
 }
 
 func (r *SteelRouter) generateOpenAPIForHandlerWithMiddleware(info *HandlerInfo) {
@@ -567,6 +671,10 @@ func (r *SteelRouter) generateOpenAPIForHandlerWithMiddleware(info *HandlerInfo)
 		}
 	}
 
+	// Document the rate limit policy after the middleware responses it refines
+	r.addRateLimitDocumentation(&operation, info.RateLimit)
+	r.addTimeoutDocumentation(&operation, info.Timeout)
+
 	// Add security requirements from handler
 	if len(info.SecurityRequirements) > 0 {
 		if operation.Security == nil {
@@ -608,7 +716,8 @@ func (r *SteelRouter) createOpinionatedWrapperWithMiddleware(handler interface{}
 		ctx := &MiddlewareContext{
 			Context:         fastCtx,
 			StartTime:       time.Now(),
-			RequestID:       req.Header.Get("X-Request-ID"),
+			RequestID:       requestID(req),
+			UserID:          principalID(req.Context()),
 			Metadata:        make(map[string]interface{}),
 			HandlerInfo:     handlerInfo,
 			Headers:         make(map[string]string),
@@ -626,6 +735,8 @@ func (r *SteelRouter) createOpinionatedWrapperWithMiddleware(handler interface{}
 		finalHandler := func() error {
 			// Bind parameters to input struct
 			if err := r.bindParameters(fastCtx, input); err != nil {
+				RequestLogger(fastCtx.Request).Warn("request binding failed", "error", err)
+
 				var apiErr APIError
 				if strings.Contains(err.Error(), "body:") &&
 					(strings.Contains(err.Error(), "invalid character") ||
@@ -639,11 +750,11 @@ func (r *SteelRouter) createOpinionatedWrapperWithMiddleware(handler interface{}
 
 				if httpErr, ok := apiErr.(*HTTPError); ok {
 					httpErr.Path = req.URL.Path
-					httpErr.RequestID = req.Header.Get("X-Request-ID")
+					httpErr.RequestID = requestID(req)
 				}
 				if valErr, ok := apiErr.(*ValidationError); ok {
 					valErr.Path = req.URL.Path
-					valErr.RequestID = req.Header.Get("X-Request-ID")
+					valErr.RequestID = requestID(req)
 				}
 
 				ctx.Error = apiErr
@@ -693,18 +804,7 @@ func (r *SteelRouter) createOpinionatedWrapperWithMiddleware(handler interface{}
 		}
 
 		if chainErr != nil {
-			// Handle errors
-			if apiErr, ok := chainErr.(APIError); ok {
-				r.writeErrorResponse(w, req, apiErr)
-				return
-			}
-
-			// Handle standard Go errors
-			internalErr := InternalServerError("An unexpected error occurred")
-			internalErr.Path = req.URL.Path
-			internalErr.RequestID = req.Header.Get("X-Request-ID")
-			internalErr.Detail = chainErr.Error()
-			r.writeErrorResponse(w, req, internalErr)
+			r.handleError(w, req, chainErr)
 			return
 		}
 
@@ -755,148 +855,6 @@ func (r *SteelRouter) createOpinionatedWrapperWithMiddleware(handler interface{}
 	}
 }
 
-// Register opinionated handler with reflection and OpenAPI generation
-func (r *SteelRouter) registerOpinionatedHandler(method, pattern string, handler interface{}, opts ...HandlerOption) {
-	handlerType := reflect.TypeOf(handler)
-	if handlerType.Kind() != reflect.Func {
-		panic("handler must be a function")
-	}
-
-	if handlerType.NumIn() != 2 || handlerType.NumOut() != 2 {
-		panic("handler must have signature func(*Context, InputType) (*OutputType, error)")
-	}
-
-	inputType := handlerType.In(1)
-	outputType := handlerType.Out(0)
-
-	// Remove pointer from output type for reflection
-	if outputType.Kind() == reflect.Ptr {
-		outputType = outputType.Elem()
-	}
-
-	// Create handler info
-	info := &HandlerInfo{
-		Method:     method,
-		Path:       pattern,
-		InputType:  inputType,
-		OutputType: outputType,
-		Handler:    handler,
-	}
-
-	// Apply options
-	for _, opt := range opts {
-		opt(info)
-	}
-
-	// Register in handlers map
-	key := method + " " + pattern
-	r.handlers[key] = info
-
-	// Generate OpenAPI spec for this handler
-	r.generateOpenAPIForHandler(info)
-
-	// Create wrapper for standard router
-	wrapper := r.createOpinionatedWrapper(handler, inputType, outputType)
-	r.addRoute(method, pattern, wrapper)
-}
-
-// Create wrapper that handles parameter binding and validation
-func (r *SteelRouter) createOpinionatedWrapper(handler interface{}, inputType, outputType reflect.Type) HandlerFunc {
-	handlerValue := reflect.ValueOf(handler)
-
-	return func(w http.ResponseWriter, req *http.Request) {
-		// Get parameters from context, which were populated by ServeHTTP's findHandler
-		params := ParamsFromContext(req.Context())
-
-		// Create Context with enhanced error handling
-		ctx := &Context{
-			Request:  req,
-			Response: w,
-			router:   r,
-			params:   params,
-		}
-
-		// Create input instance
-		input := reflect.New(inputType).Interface()
-
-		// Bind parameters to input struct
-		if err := r.bindParameters(ctx, input); err != nil {
-			// Determine appropriate error status based on the error type
-			var apiErr APIError
-
-			// Check if it's a JSON parsing error (should be 400 Bad Request)
-			if strings.Contains(err.Error(), "body:") &&
-				(strings.Contains(err.Error(), "invalid character") ||
-					strings.Contains(err.Error(), "cannot unmarshal") ||
-					strings.Contains(err.Error(), "unexpected end of JSON input")) {
-				apiErr = BadRequest("Invalid JSON in request body", err.Error())
-			} else {
-				// Other binding errors are validation errors (422)
-				apiErr = UnprocessableEntity("Parameter binding failed",
-					NewFieldError("parameters", err.Error(), nil, "BINDING_ERROR"))
-			}
-
-			if httpErr, ok := apiErr.(*HTTPError); ok {
-				httpErr.Path = req.URL.Path
-				httpErr.RequestID = req.Header.Get("X-Request-ID")
-			}
-			if valErr, ok := apiErr.(*ValidationError); ok {
-				valErr.Path = req.URL.Path
-				valErr.RequestID = req.Header.Get("X-Request-ID")
-			}
-
-			r.writeErrorResponse(w, req, apiErr)
-			return
-		}
-
-		// Call the handler
-		results := handlerValue.Call([]reflect.Value{
-			reflect.ValueOf(ctx),
-			reflect.ValueOf(input).Elem(),
-		})
-
-		// Handle results
-		output := results[0]
-		err := results[1]
-
-		// Check for errors first
-		if !err.IsNil() {
-			errVal := err.Interface().(error)
-			r.handleError(w, req, errVal)
-			return
-		}
-
-		// Handle successful responses
-		if !output.IsNil() {
-			outputVal := output.Interface()
-
-			// Check if it's an APIResponse (custom status code)
-			if apiResp, ok := outputVal.(*APIResponse); ok {
-				// Set custom headers
-				for key, value := range apiResp.Headers {
-					w.Header().Set(key, value)
-				}
-
-				// Set status code
-				w.WriteHeader(apiResp.StatusCode)
-
-				// Write response body if data exists
-				if apiResp.Data != nil {
-					w.Header().Set("Content-Type", "application/json")
-					json.NewEncoder(w).Encode(apiResp.Data)
-				}
-				return
-			}
-
-			// Standard successful response
-			ctx.JSON(http.StatusOK, outputVal)
-		} else {
-			// No content response
-			w.WriteHeader(http.StatusNoContent)
-		}
-	}
-}
-
 // Enhanced error handling
 func (r *SteelRouter) handleError(w http.ResponseWriter, req *http.Request, err error) {
 	// Check if it's an APIError
@@ -907,9 +865,10 @@ func (r *SteelRouter) handleError(w http.ResponseWriter, req *http.Request, err
 				httpErr.Path = req.URL.Path
 			}
 			if httpErr.RequestID == "" {
-				httpErr.RequestID = req.Header.Get("X-Request-ID")
+				httpErr.RequestID = requestID(req)
 			}
 		}
+		recordSpanError(req, err, apiErr.StatusCode())
 		r.writeErrorResponse(w, req, apiErr)
 		return
 	}
@@ -917,12 +876,35 @@ func (r *SteelRouter) handleError(w http.ResponseWriter, req *http.Request, err
 	// Handle standard Go errors
 	internalErr := InternalServerError("An unexpected error occurred")
 	internalErr.Path = req.URL.Path
-	internalErr.RequestID = req.Header.Get("X-Request-ID")
-	internalErr.Detail = err.Error() // Include original error in detail for debugging
+	internalErr.RequestID = requestID(req)
+	if !isPanicError(err) {
+		internalErr.Detail = err.Error() // Include original error in detail for debugging
+	}
 
+	recordSpanError(req, err, internalErr.StatusCode())
 	r.writeErrorResponse(w, req, internalErr)
 }
 
+// requestID returns the request ID of a request, its X-Request-ID header, or the ID of its trace
+func requestID(req *http.Request) string {
+	if id := RequestIDFromContext(req.Context()); id != "" {
+		return id
+	}
+	if id := req.Header.Get("X-Request-ID"); id != "" {
+		return id
+	}
+	return TraceIDFromContext(req.Context())
+}
+
+// recordSpanError records an error on the span of a request, failing the span for server errors
+func recordSpanError(req *http.Request, err error, status int) {
+	span := SpanFromContext(req.Context())
+	span.RecordError(err)
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(SpanStatusError, err.Error())
+	}
+}
+
 // Write structured error response
 func (r *SteelRouter) writeErrorResponse(w http.ResponseWriter, req *http.Request, apiErr APIError) {
 	w.Header().Set("Content-Type", "application/json")
@@ -1059,139 +1041,6 @@ func (r *SteelRouter) setFieldValue(field reflect.Value, value string) error {
 	return nil
 }
 
-// Generate OpenAPI specification for handler
-func (r *SteelRouter) generateOpenAPIForHandler(info *HandlerInfo) {
-	operation := OpenAPIOperation{
-		Summary:     info.Summary,
-		Description: info.Description,
-		Tags:        info.Tags,
-		Parameters:  []OpenAPIParameter{},
-		Responses:   make(map[string]OpenAPIResponse),
-		Deprecated:  info.Deprecated,
-		OperationID: info.OperationID,
-	}
-
-	// Add security requirements
-	if len(info.SecurityRequirements) > 0 {
-		operation.Security = info.SecurityRequirements
-	} else if len(r.globalSecurity) > 0 {
-		// Apply global security if no specific requirements
-		operation.Security = r.globalSecurity
-	}
-
-	// Validate security requirements if provider exists
-	if r.securityProvider != nil {
-		for _, req := range operation.Security {
-			if err := r.securityProvider.ValidateSecurityRequirement(req); err != nil {
-				// Log warning - in production you might want to use a proper logger
-				fmt.Printf("Warning: Invalid security requirement for %s %s: %v\n",
-					info.Method, info.Path, err)
-			}
-		}
-	}
-
-	// Generate parameters from input struct (existing logic)
-	hasBodyParams := false
-	var bodySchema OpenAPISchema
-
-	if info.InputType.Kind() == reflect.Struct {
-		// Check for body parameters
-		for i := 0; i < info.InputType.NumField(); i++ {
-			field := info.InputType.Field(i)
-			if bodyTag := field.Tag.Get("body"); bodyTag != "" {
-				hasBodyParams = true
-				bodySchema = r.typeToSchema(info.InputType)
-				break
-			}
-		}
-
-		// Handle other parameter types
-		for i := 0; i < info.InputType.NumField(); i++ {
-			field := info.InputType.Field(i)
-
-			// Path parameters
-			if paramTag := field.Tag.Get("path"); paramTag != "" {
-				param := OpenAPIParameter{
-					Name:     paramTag,
-					In:       "path",
-					Required: true,
-					Schema:   r.typeToSchema(field.Type),
-				}
-				if desc := field.Tag.Get("description"); desc != "" {
-					param.Description = desc
-				}
-				operation.Parameters = append(operation.Parameters, param)
-			}
-
-			// Query parameters
-			if queryTag := field.Tag.Get("query"); queryTag != "" {
-				param := OpenAPIParameter{
-					Name:     queryTag,
-					In:       "query",
-					Required: field.Tag.Get("required") == "true",
-					Schema:   r.typeToSchema(field.Type),
-				}
-				if desc := field.Tag.Get("description"); desc != "" {
-					param.Description = desc
-				}
-				operation.Parameters = append(operation.Parameters, param)
-			}
-
-			// Header parameters
-			if headerTag := field.Tag.Get("header"); headerTag != "" {
-				param := OpenAPIParameter{
-					Name:     headerTag,
-					In:       "header",
-					Required: field.Tag.Get("required") == "true",
-					Schema:   r.typeToSchema(field.Type),
-				}
-				if desc := field.Tag.Get("description"); desc != "" {
-					param.Description = desc
-				}
-				operation.Parameters = append(operation.Parameters, param)
-			}
-		}
-	}
-
-	// Add request body if we have body parameters
-	if hasBodyParams {
-		operation.RequestBody = &OpenAPIRequestBody{
-			Required: true,
-			Content: map[string]OpenAPIMediaType{
-				"application/json": {
-					Schema: bodySchema,
-				},
-			},
-		}
-	}
-
-	// Generate success response schema
-	if info.OutputType.Kind() == reflect.Struct {
-		operation.Responses["200"] = OpenAPIResponse{
-			Description: "Success",
-			Content: map[string]OpenAPIMediaType{
-				"application/json": {
-					Schema: r.typeToSchema(info.OutputType),
-				},
-			},
-		}
-	} else {
-		operation.Responses["204"] = OpenAPIResponse{
-			Description: "No Content",
-		}
-	}
-
-	// Add standard error responses (including security-related ones)
-	r.addStandardErrorResponses(&operation, info.Method)
-
-	// Add to OpenAPI spec
-	openAPIPath := r.convertToOpenAPIPath(info.Path)
-	if r.openAPISpec.Paths[openAPIPath] == nil {
-		r.openAPISpec.Paths[openAPIPath] = make(OpenAPIPath)
-	}
-	r.openAPISpec.Paths[openAPIPath][strings.ToLower(info.Method)] = operation
-}
-
 // convertToOpenAPIPath Helper function to convert internal path format to OpenAPI format
 func (r *SteelRouter) convertToOpenAPIPath(path string) string {
 	// Convert :param to {param} format for OpenAPI
@@ -1469,9 +1318,20 @@ func (r *SteelRouter) ensureErrorSchemasRegistered() {
 
 // Convert Go type to OpenAPI schema
 func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
+	return r.typeToComponentSchema(t, r.openAPISpec.Components.Schemas)
+}
+
+// typeToComponentSchema converts a Go type to a JSON schema, registering named structs in components.
+// OpenAPI and AsyncAPI share this engine so a type documented in both specifications is identical.
+func (r *SteelRouter) typeToComponentSchema(t reflect.Type, components map[string]OpenAPISchema) OpenAPISchema {
 	// Handle pointer types by dereferencing
 	if t.Kind() == reflect.Ptr {
-		return r.typeToSchema(t.Elem())
+		return r.typeToComponentSchema(t.Elem(), components)
+	}
+
+	// Polymorphic types registered with RegisterOneOf
+	if union := lookupOneOf(t); union != nil {
+		return r.oneOfComponentSchema(union, components)
 	}
 
 	// Handle special named types first
@@ -1595,7 +1455,7 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 
 	// Collection types
 	case reflect.Slice, reflect.Array:
-		elemSchema := r.typeToSchema(t.Elem())
+		elemSchema := r.typeToComponentSchema(t.Elem(), components)
 		schema := OpenAPISchema{
 			Type:  "array",
 			Items: elemSchema,
@@ -1613,7 +1473,7 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 
 	case reflect.Map:
 		keyType := t.Key()
-		valueSchema := r.typeToSchema(t.Elem())
+		valueSchema := r.typeToComponentSchema(t.Elem(), components)
 
 		// OpenAPI 3.1.1 supports string keys in objects
 		if keyType.Kind() == reflect.String {
@@ -1629,7 +1489,7 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 				Items: OpenAPISchema{
 					Type: "object",
 					Properties: map[string]OpenAPISchema{
-						"key":   r.typeToSchema(keyType),
+						"key":   r.typeToComponentSchema(keyType, components),
 						"value": valueSchema,
 					},
 					Required: []string{"key", "value"},
@@ -1644,13 +1504,13 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 			schemaName := t.Name()
 
 			// Register the schema in components if not already present
-			if _, exists := r.openAPISpec.Components.Schemas[schemaName]; !exists {
+			if _, exists := components[schemaName]; !exists {
 				// Temporarily set a placeholder to prevent infinite recursion
-				r.openAPISpec.Components.Schemas[schemaName] = OpenAPISchema{Type: "object"}
+				components[schemaName] = OpenAPISchema{Type: "object"}
 
 				// Generate the actual schema
-				schema := r.generateStructSchema(t)
-				r.openAPISpec.Components.Schemas[schemaName] = schema
+				schema := r.generateComponentStructSchema(t, components)
+				components[schemaName] = schema
 			}
 
 			// Return a reference to the component
@@ -1660,7 +1520,7 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 		}
 
 		// For anonymous structs, generate inline schema
-		return r.generateStructSchema(t)
+		return r.generateComponentStructSchema(t, components)
 
 	case reflect.Interface:
 		// Handle interface{} and any types - OpenAPI 3.1.1 way
@@ -1684,8 +1544,41 @@ func (r *SteelRouter) typeToSchema(t reflect.Type) OpenAPISchema {
 	}
 }
 
+// enumValue converts a value of an enum tag to the JSON type of the field, keeping it as a string
+// when it does not parse as that type.
+func enumValue(t reflect.Type, value string) interface{} {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if parsed, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return parsed
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if parsed, err := strconv.ParseUint(value, 10, 64); err == nil {
+			return parsed
+		}
+	case reflect.Float32, reflect.Float64:
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			return parsed
+		}
+	case reflect.Bool:
+		if parsed, err := strconv.ParseBool(value); err == nil {
+			return parsed
+		}
+	}
+	return value
+}
+
 // Enhanced generateStructSchema with better field handling
 func (r *SteelRouter) generateStructSchema(t reflect.Type) OpenAPISchema {
+	return r.generateComponentStructSchema(t, r.openAPISpec.Components.Schemas)
+}
+
+// generateComponentStructSchema generates an object schema for a struct, registering nested named structs in components.
+func (r *SteelRouter) generateComponentStructSchema(t reflect.Type, components map[string]OpenAPISchema) OpenAPISchema {
 	schema := OpenAPISchema{
 		Type:       "object",
 		Properties: make(map[string]OpenAPISchema),
@@ -1703,7 +1596,7 @@ func (r *SteelRouter) generateStructSchema(t reflect.Type) OpenAPISchema {
 		// Handle embedded fields
 		if field.Anonymous {
 			if field.Type.Kind() == reflect.Struct || (field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct) {
-				embeddedSchema := r.typeToSchema(field.Type)
+				embeddedSchema := r.typeToComponentSchema(field.Type, components)
 
 				// In OpenAPI 3.1.1, we can use allOf for composition
 				if len(schema.Properties) == 0 && len(schema.Required) == 0 {
@@ -1757,7 +1650,7 @@ func (r *SteelRouter) generateStructSchema(t reflect.Type) OpenAPISchema {
 		}
 
 		// Generate schema for field type
-		fieldSchema := r.typeToSchema(field.Type)
+		fieldSchema := r.typeToComponentSchema(field.Type, components)
 
 		// Add description from tag if present
 		if desc := field.Tag.Get("description"); desc != "" {
@@ -1788,6 +1681,11 @@ func (r *SteelRouter) generateStructSchema(t reflect.Type) OpenAPISchema {
 		if pattern := field.Tag.Get("pattern"); pattern != "" {
 			fieldSchema.Pattern = pattern
 		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			for _, value := range strings.Split(enum, ",") {
+				fieldSchema.Enum = append(fieldSchema.Enum, enumValue(field.Type, strings.TrimSpace(value)))
+			}
+		}
 
 		// Handle nullable fields (OpenAPI 3.1.1 style)
 		if field.Type.Kind() == reflect.Ptr {
@@ -1815,11 +1713,11 @@ func (r *SteelRouter) generateStructSchema(t reflect.Type) OpenAPISchema {
 
 // EnableOpenAPI mount OpenAPI documentation
 func (r *SteelRouter) EnableOpenAPI() {
+	paths := r.specPaths()
+
 	// Serve OpenAPI spec
-	r.GET("/openapi.json", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(r.openAPISpec)
-	})
+	r.GET(paths.OpenAPIJSON, r.specHandler(specOpenAPI, SpecFormatJSON))
+	r.GET(paths.OpenAPIYAML, r.specHandler(specOpenAPI, SpecFormatYAML))
 
 	// Swagger UI
 	r.addSwaggerUIEndpoint()
@@ -1844,7 +1742,7 @@ func (r *SteelRouter) EnableOpenAPI() {
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultOpenAPIJSONPath, paths.OpenAPIJSON))
 	})
 
 	// Scalar
@@ -1872,7 +1770,7 @@ func (r *SteelRouter) EnableOpenAPI() {
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultOpenAPIJSONPath, paths.OpenAPIJSON))
 	})
 
 	// ReDoc
@@ -1897,7 +1795,7 @@ func (r *SteelRouter) EnableOpenAPI() {
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultOpenAPIJSONPath, paths.OpenAPIJSON))
 	})
 
 	// Documentation index page
@@ -2002,7 +1900,7 @@ func (r *SteelRouter) EnableOpenAPI() {
 </body>
 </html>`
 		w.Header().Set("Content-Type", "text/html")
-		w.Write([]byte(html))
+		w.Write(withSpecURL(html, DefaultOpenAPIJSONPath, paths.OpenAPIJSON))
 	})
 }
 
@@ -2162,6 +2060,7 @@ func (r *SteelRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	// Set parameters in request context
+	params.router = r
 	ctx := context.WithValue(req.Context(), paramsKey, params)
 	req = req.WithContext(ctx)
 
@@ -2240,7 +2139,9 @@ func (r *SteelRouter) findHandler(method, path string, params *Params) HandlerFu
 }
 
 // Enhanced addRoute with better path normalization
-func (r *SteelRouter) addRoute(method, path string, handler HandlerFunc) {
+// addRoute adds a route and returns the node holding its handler, for the caller to record
+// the route's metadata on
+func (r *SteelRouter) addRoute(method, path string, handler HandlerFunc) *node {
 	if path == "" {
 		panic("path cannot be empty")
 	}
@@ -2255,7 +2156,11 @@ func (r *SteelRouter) addRoute(method, path string, handler HandlerFunc) {
 	if r.trees[method] == nil {
 		r.trees[method] = &node{}
 	}
-	r.trees[method].addRoute(path, handler)
+	n := r.trees[method].addRoute(path, handler)
+	n.route = path
+	n.info = nil
+	n.kind = endpointHTTP
+	return n
 }
 
 // SetTrailingSlashRedirect Add configuration methods
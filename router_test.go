@@ -2,13 +2,36 @@ package steel
 
 import (
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
 	"io"
+	"log/slog"
+	"math/big"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	jsoniter "github.com/json-iterator/go"
 )
 
 // Test structs for opinionated handlers
@@ -380,8 +403,9 @@ func TestBuiltinMiddleware(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 
-	if body := w.Body.String(); body != "Internal Server Error" {
-		t.Errorf("Expected body 'Internal Server Error', got %q", body)
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error.Status != http.StatusInternalServerError {
+		t.Errorf("Expected a JSON error response, got %q", w.Body.String())
 	}
 }
 
@@ -543,6 +567,1548 @@ func TestOpenAPIGeneration(t *testing.T) {
 	}
 }
 
+// TestSpecYAMLEndpoints tests the YAML spec endpoints and WriteSpec export
+func TestSpecYAMLEndpoints(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedGET("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	}, WithSummary("Get user"))
+	router.EnableOpenAPI()
+	router.EnableAsyncAPI()
+
+	for path, prefix := range map[string]string{
+		"/openapi.yaml":  "openapi: 3.1.1",
+		"/asyncapi.yaml": "asyncapi: 2.6.0",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d", path, w.Code)
+		}
+		if contentType := w.Header().Get("Content-Type"); contentType != "application/yaml" {
+			t.Errorf("%s: expected application/yaml, got %q", path, contentType)
+		}
+		if !strings.HasPrefix(w.Body.String(), prefix) {
+			t.Errorf("%s: expected YAML starting with %q, got %q", path, prefix, w.Body.String()[:40])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := router.WriteSpec(&buf, SpecFormatYAML); err != nil {
+		t.Fatalf("WriteSpec failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "\n        \"200\":\n") {
+		t.Errorf("Expected quoted status code keys in YAML, got:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	if err := router.WriteSpec(&buf, SpecFormatJSON); err != nil {
+		t.Fatalf("WriteSpec failed: %v", err)
+	}
+	var spec map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &spec); err != nil {
+		t.Fatalf("Expected valid JSON: %v", err)
+	}
+
+	if err := router.WriteSpec(&buf, "toml"); !errors.Is(err, ErrUnsupportedSpecFormat) {
+		t.Errorf("Expected ErrUnsupportedSpecFormat, got %v", err)
+	}
+}
+
+// TestSpecCaching tests ETag and Last-Modified handling on spec endpoints
+func TestSpecCaching(t *testing.T) {
+	router := NewRouter()
+	router.EnableOpenAPI()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("Expected ETag and Last-Modified headers, got %v", w.Header())
+	}
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("Expected 304 for matching ETag, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.yaml", nil))
+	if w.Header().Get("ETag") == etag {
+		t.Error("Expected JSON and YAML documents to have different ETags")
+	}
+}
+
+// TestSpecRebuiltAfterChange tests that routes and settings changed while serving are documented
+func TestSpecRebuiltAfterChange(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedGET("/early", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	})
+	router.EnableOpenAPI()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	etag := w.Header().Get("ETag")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if w.Header().Get("ETag") != etag {
+		t.Error("Expected an unchanged specification to keep its ETag")
+	}
+
+	router.OpinionatedGET("/late", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	})
+	router.RegisterSecurityScheme("apiKey", OpenAPISecurityScheme{Type: "apiKey", Name: "X-API-Key", In: "header"})
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected the changed specification with a new ETag, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "/late") || !strings.Contains(w.Body.String(), "X-API-Key") {
+		t.Error("Expected the late route and security scheme to be documented")
+	}
+}
+
+// TestSpecConcurrentChanges tests that documents are built safely while routes and settings are registered
+func TestSpecConcurrentChanges(t *testing.T) {
+	router := NewRouter()
+	router.EnableOpenAPI()
+	router.EnableAsyncAPI()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			router.OpinionatedGET(fmt.Sprintf("/items%d", i), func(ctx *Context, req struct{}) (*TestResponse3, error) {
+				return &TestResponse3{}, nil
+			})
+			router.SSE(fmt.Sprintf("/events%d", i), func(conn *SSEConnection, params struct{}) error {
+				return nil
+			})
+			router.RegisterSecurityScheme(fmt.Sprintf("key%d", i), OpenAPISecurityScheme{Type: "apiKey", Name: "X-Key", In: "header"})
+			router.AddServer(OpenAPIServer{URL: fmt.Sprintf("https://api%d.example.com", i)})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			if err := router.WriteSpec(io.Discard, SpecFormatJSON); err != nil {
+				t.Error(err)
+			}
+			if err := router.WriteAsyncAPISpec(io.Discard, SpecFormatYAML); err != nil {
+				t.Error(err)
+			}
+
+			// Returned specifications are copies, safe to read while routes are registered
+			spec := router.GetOpenAPISpec()
+			for path, operations := range spec.Paths {
+				for method := range operations {
+					_ = path + method
+				}
+			}
+			for name, schema := range spec.Components.Schemas {
+				_, _ = name, schema.Properties["id"]
+			}
+			for name := range router.AsyncAPIV3().Channels {
+				_ = name
+			}
+		}
+	}()
+	wg.Wait()
+
+	spec := router.GetOpenAPISpec()
+	delete(spec.Paths, "/items0")
+	if _, ok := router.GetOpenAPISpec().Paths["/items0"]; !ok {
+		t.Error("Expected changes to a returned specification not to reach the router")
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	if !strings.Contains(w.Body.String(), "/items19") {
+		t.Error("Expected every route to be documented")
+	}
+}
+
+// TestSpecPaths tests custom mount paths for spec endpoints and documentation pages
+func TestSpecPaths(t *testing.T) {
+	router := NewRouter()
+	router.SetSpecPaths(SpecPaths{OpenAPIJSON: "/docs/v1/openapi.json", AsyncAPIYAML: "/docs/v1/asyncapi.yaml"})
+	router.EnableOpenAPI()
+	router.EnableAsyncAPI()
+
+	for _, path := range []string{"/docs/v1/openapi.json", "/openapi.yaml", "/asyncapi", "/docs/v1/asyncapi.yaml"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, w.Code)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi/swagger", nil))
+	if !strings.Contains(w.Body.String(), "'/docs/v1/openapi.json'") {
+		t.Error("Expected Swagger UI to load the configured spec path")
+	}
+}
+
+type diffOrderV1 struct {
+	ID       int      `path:"id" description:"Order ID"`
+	Limit    int      `query:"limit" description:"Maximum items"`
+	Status   string   `json:"status" enum:"open,closed,archived"`
+	Quantity float64  `json:"quantity"`
+	Body     struct{} `body:"body"`
+}
+
+type diffOrderV2 struct {
+	OrderID  int      `path:"orderId" description:"Order ID"`
+	Limit    int      `query:"limit" required:"true" description:"Maximum items"`
+	Status   string   `json:"status" enum:"open,closed"`
+	Quantity int      `json:"quantity"`
+	Body     struct{} `body:"body"`
+}
+
+type diffOrderResponseV1 struct {
+	ID   int    `json:"id"`
+	Note string `json:"note"`
+}
+
+type diffOrderResponseV2 struct {
+	ID      int    `json:"id"`
+	Comment string `json:"comment"`
+}
+
+// TestDiffOpenAPISpecs tests breaking change detection between a committed and a current specification
+func TestDiffOpenAPISpecs(t *testing.T) {
+	v1 := NewRouter()
+	v1.OpinionatedPOST("/orders/:id", func(ctx *Context, req diffOrderV1) (*diffOrderResponseV1, error) {
+		return nil, nil
+	})
+	v1.OpinionatedGET("/legacy", func(ctx *Context, req struct{}) (*diffOrderResponseV1, error) {
+		return nil, nil
+	})
+
+	var buf bytes.Buffer
+	if err := v1.WriteSpec(&buf, SpecFormatYAML); err != nil {
+		t.Fatalf("WriteSpec failed: %v", err)
+	}
+	committed, err := LoadOpenAPISpec(buf.Bytes())
+	if err != nil {
+		t.Fatalf("LoadOpenAPISpec failed: %v", err)
+	}
+
+	if diff := DiffOpenAPISpecs(committed, v1.GetOpenAPISpec()); len(diff.Changes) != 0 {
+		t.Fatalf("Expected no changes after a round trip, got:\n%s", diff)
+	}
+
+	v2 := NewRouter()
+	v2.OpinionatedPOST("/orders/:orderId", func(ctx *Context, req diffOrderV2) (*diffOrderResponseV2, error) {
+		return nil, nil
+	})
+	v2.OpinionatedGET("/health", func(ctx *Context, req struct{}) (*diffOrderResponseV2, error) {
+		return nil, nil
+	})
+
+	diff := DiffOpenAPISpecs(committed, v2.GetOpenAPISpec())
+	if !diff.HasBreakingChanges() {
+		t.Fatal("Expected breaking changes")
+	}
+
+	changes := make(map[string]SpecChange)
+	for _, change := range diff.Changes {
+		changes[change.Operation+" "+string(change.Kind)+" "+change.Location] = change
+		if strings.Contains(change.Location, "path parameter") {
+			t.Errorf("Expected the renamed path parameter to match, got %s", change)
+		}
+	}
+
+	for key, breaking := range map[string]bool{
+		"GET /legacy operation-removed ":                                                 true,
+		"GET /health operation-added ":                                                   false,
+		`POST /orders/{orderId} required-changed query parameter "limit"`:                true,
+		"POST /orders/{orderId} enum-changed request body (application/json) /status":    true,
+		"POST /orders/{orderId} type-changed request body (application/json) /quantity":  true,
+		"POST /orders/{orderId} property-removed response 200 (application/json) /note":  true,
+		"POST /orders/{orderId} property-added response 200 (application/json) /comment": false,
+	} {
+		change, ok := changes[key]
+		if !ok {
+			t.Errorf("Expected change %q, got:\n%s", key, diff)
+			continue
+		}
+		if change.Breaking != breaking {
+			t.Errorf("Expected %q to have breaking=%v", key, breaking)
+		}
+	}
+
+	reverse := DiffOpenAPISpecs(v2.GetOpenAPISpec(), v2.GetOpenAPISpec())
+	if reverse.HasBreakingChanges() {
+		t.Errorf("Expected identical specifications to have no breaking changes, got:\n%s", reverse)
+	}
+}
+
+type clientTestItem struct {
+	Name    string    `json:"name"`
+	Created time.Time `json:"created"`
+}
+
+// TestGenerateClient tests that the generated client has typed methods for every handler
+func TestGenerateClient(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedGET("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	}, WithSummary("Get user"))
+	router.OpinionatedPOST("/users", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	}, WithOperationID("createUser"))
+	router.OpinionatedGET("/items", func(ctx *Context, req struct{}) (*clientTestItem, error) {
+		return &clientTestItem{}, nil
+	})
+	router.SSE("/events/:userId", func(conn *SSEConnection, params struct{}) error {
+		return nil
+	})
+
+	var buf bytes.Buffer
+	if err := router.GenerateClient(&buf, ClientConfig{PackageName: "api"}); err != nil {
+		t.Fatalf("GenerateClient failed: %v", err)
+	}
+	source := buf.String()
+
+	file := typeCheckClient(t, source)
+	if file.Name.Name != "api" {
+		t.Errorf("Expected package api, got %s", file.Name.Name)
+	}
+
+	for _, expected := range []string{
+		"func (c *Client) GetUsersByID(ctx context.Context, in steel.TestRequest2) (*steel.TestResponse3, error)",
+		"func (c *Client) CreateUser(ctx context.Context, in steel.TestRequest2) (*steel.TestResponse3, error)",
+		"func (c *Client) SubscribeEventsByUserID(ctx context.Context, params SubscribeEventsByUserIDParams, lastEventID string)",
+		"UserID string `path:\"userId\"`",
+		"func (c *Client) GetItems(ctx context.Context) (*ClientTestItem, error)",
+		"Created time.Time `json:\"created\"`",
+	} {
+		if !strings.Contains(source, expected) {
+			t.Errorf("Expected generated client to contain %q, got:\n%s", expected, source)
+		}
+	}
+
+	// Types of package main cannot be imported by the client
+	if isStdlibPackage("main") {
+		t.Error("Expected package main not to be treated as standard library")
+	}
+}
+
+// typeCheckClient parses and type-checks a generated client. The steel import resolves to this package
+// including its test files, which declare the handler types; other imports are checked from source.
+func typeCheckClient(t *testing.T, source string) *ast.File {
+	t.Helper()
+
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "client_generated.go"), source, 0)
+	if err != nil {
+		t.Fatalf("Generated client does not parse: %v\n%s", err, source)
+	}
+
+	sources := importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	config := &types.Config{Importer: clientTestImporter{ImporterFrom: sources, fset: fset, dir: dir}}
+	if _, err := config.Check("api", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("Generated client does not type-check: %v\n%s", err, source)
+	}
+	return file
+}
+
+// clientTestImporter imports steel from the package directory with its test files
+type clientTestImporter struct {
+	types.ImporterFrom
+	fset *token.FileSet
+	dir  string
+}
+
+func (i clientTestImporter) Import(path string) (*types.Package, error) {
+	return i.ImportFrom(path, i.dir, 0)
+}
+
+func (i clientTestImporter) ImportFrom(path, dir string, mode types.ImportMode) (*types.Package, error) {
+	if path != steelImportPath {
+		return i.ImporterFrom.ImportFrom(path, dir, mode)
+	}
+
+	packages, err := parser.ParseDir(i.fset, i.dir, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, file := range packages["steel"].Files {
+		files = append(files, file)
+	}
+	return (&types.Config{Importer: i.ImporterFrom}).Check(steelImportPath, i.fset, files, nil)
+}
+
+// TestAPIClient tests request mapping and error decoding of the generated client runtime
+func TestAPIClient(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedPOST("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		if req.ID == 0 {
+			return nil, NotFound("User")
+		}
+		return &TestResponse3{ID: req.ID, Name: req.Name + ":" + req.Body.Email}, nil
+	})
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	client := NewAPIClient(server.URL)
+
+	var out TestResponse3
+	in := TestRequest2{ID: 7, Name: "bob", Body: TestBody{Email: "bob@example.com"}}
+	if err := client.Do(context.Background(), "POST", "/users/:id", &in, &out); err != nil {
+		t.Fatalf("Do failed: %v", err)
+	}
+	if out.ID != 7 || out.Name != "bob:bob@example.com" {
+		t.Errorf("Expected path, query and body to be mapped, got %+v", out)
+	}
+
+	err := client.Do(context.Background(), "POST", "/users/:id", &TestRequest2{}, &out)
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		t.Fatalf("Expected *HTTPError, got %T: %v", err, err)
+	}
+	if httpErr.Status != http.StatusNotFound || httpErr.Code != "NOT_FOUND" {
+		t.Errorf("Expected decoded 404 error, got %+v", httpErr)
+	}
+}
+
+type mockUserInput struct {
+	ID      int  `path:"id" description:"User ID"`
+	Verbose bool `query:"verbose" description:"Include details"`
+}
+
+// TestMockRouter tests generated responses, input validation and Prefer header selection
+func TestMockRouter(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedGET("/users/:id", func(ctx *Context, req mockUserInput) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	})
+	spec := router.GetOpenAPISpec()
+
+	operation := spec.Paths["/users/{id}"]["get"]
+	media := operation.Responses["200"].Content["application/json"]
+	media.Examples = map[string]OpenAPIExample{
+		"admin": {Summary: "An admin", Value: map[string]interface{}{"id": 1, "name": "root"}},
+	}
+	operation.Responses["200"].Content["application/json"] = media
+
+	mock := NewMockRouter(spec)
+	validator := newSchemaValidator(spec.Components.Schemas)
+
+	serve := func(target, prefer string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", target, nil)
+		if prefer != "" {
+			req.Header.Set("Prefer", prefer)
+		}
+		w := httptest.NewRecorder()
+		mock.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/users/5", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body["name"] != "root" {
+		t.Errorf("Expected the media type example, got %s", w.Body.String())
+	}
+
+	media.Examples = nil
+	operation.Responses["200"].Content["application/json"] = media
+	w = serve("/users/5", "")
+	value, err := decodeJSONValue(w.Body.Bytes())
+	if err != nil {
+		t.Fatalf("Expected JSON, got %s", w.Body.String())
+	}
+	if violations := validator.Validate(media.Schema, value); len(violations) > 0 {
+		t.Errorf("Expected generated data to match the schema, got %v for %s", violations, w.Body.String())
+	}
+
+	if w = serve("/users/abc", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid path parameter to be rejected, got %d", w.Code)
+	}
+	if w = serve("/users/5?verbose=maybe", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected invalid query parameter to be rejected, got %d", w.Code)
+	}
+
+	w = serve("/users/5", "code=404")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Expected status 404, got %d", w.Code)
+	}
+	var errorBody ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &errorBody); err != nil || errorBody.Error.Code == "" {
+		t.Errorf("Expected an ErrorResponse body, got %s", w.Body.String())
+	}
+
+	spec.Components.Examples = map[string]OpenAPIExample{"missing": {Value: map[string]interface{}{"id": 0}}}
+	if w = serve("/users/5", "example=missing"); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"id":0`) {
+		t.Errorf("Expected the named component example, got %d: %s", w.Code, w.Body.String())
+	}
+
+	if w = serve("/users/5", "code=418"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an undocumented status to be rejected, got %d", w.Code)
+	}
+	if w = serve("/users/5", `example="nope"`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown example to be rejected, got %d", w.Code)
+	}
+}
+
+// TestMockStringLength tests that generated strings respect length bounds counted in characters
+func TestMockStringLength(t *testing.T) {
+	mockFormatValues["x-greeting"] = "héllo wörld"
+	defer delete(mockFormatValues, "x-greeting")
+
+	three, twenty := 3, 20
+	if value := mockString(OpenAPISchema{Format: "x-greeting", MaxLength: &three}); value != "hél" {
+		t.Errorf("Expected truncation to 3 characters, got %q", value)
+	}
+	if value := mockString(OpenAPISchema{Format: "x-greeting", MinLength: &twenty}); utf8.RuneCountInString(value) != 20 || !utf8.ValidString(value) {
+		t.Errorf("Expected padding to 20 characters, got %q", value)
+	}
+}
+
+type shape interface {
+	Area() float64
+}
+
+type circle struct {
+	Radius float64 `json:"radius"`
+}
+
+func (c circle) Area() float64 { return 3 * c.Radius * c.Radius }
+
+type square struct {
+	Kind string  `json:"kind"`
+	Side float64 `json:"side"`
+}
+
+func (s *square) Area() float64 { return s.Side * s.Side }
+
+type shapeInput struct {
+	Shape shape `json:"shape"`
+}
+
+type shapeOutput struct {
+	Shape shape   `json:"shape"`
+	Area  float64 `json:"area"`
+}
+
+type cardPayment struct {
+	Last4 string `json:"last4"`
+}
+
+type bankPayment struct {
+	IBAN string `json:"iban"`
+}
+
+type paymentMethod struct {
+	Card *cardPayment
+	Bank *bankPayment
+}
+
+// TestOneOf tests polymorphic schemas and discriminator based binding and encoding
+func TestOneOf(t *testing.T) {
+	RegisterOneOf[shape]("kind", Variant[circle]("circle"), Variant[square]("square"))
+	RegisterOneOf[paymentMethod]("type", Variant[cardPayment]("card"), Variant[bankPayment]("bank"))
+
+	router := NewRouter()
+	router.OpinionatedPOST("/shapes", func(ctx *Context, req shapeInput) (*shapeOutput, error) {
+		return &shapeOutput{Shape: req.Shape, Area: req.Shape.Area()}, nil
+	})
+	router.OpinionatedPOST("/payments", func(ctx *Context, req paymentMethod) (*paymentMethod, error) {
+		return &req, nil
+	})
+	spec := router.GetOpenAPISpec()
+
+	schema := spec.Components.Schemas["shape"]
+	if len(schema.OneOf) != 2 || schema.Discriminator == nil || schema.Discriminator.PropertyName != "kind" {
+		t.Fatalf("Expected a oneOf with discriminator, got %+v", schema)
+	}
+	if schema.Discriminator.Mapping["circle"] != "#/components/schemas/circle" || schema.Discriminator.Mapping["square"] != "#/components/schemas/square" {
+		t.Errorf("Expected discriminator mapping to the variants, got %v", schema.Discriminator.Mapping)
+	}
+	if property := spec.Components.Schemas["circle"].Properties["kind"]; property.Const != "circle" {
+		t.Errorf("Expected the discriminator property added to the variant, got %+v", property)
+	}
+	if output := spec.Components.Schemas["shapeOutput"].Properties["shape"]; output.Ref != "#/components/schemas/shape" {
+		t.Errorf("Expected the field to reference the union, got %+v", output)
+	}
+	if payment := spec.Components.Schemas["paymentMethod"]; payment.Discriminator == nil || payment.Discriminator.Mapping["bank"] != "#/components/schemas/bankPayment" {
+		t.Errorf("Expected the tagged union to map its variants, got %+v", payment)
+	}
+
+	serve := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/shapes", `{"shape":{"kind":"circle","radius":2}}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"shape":{"kind":"circle","radius":2}`) || !strings.Contains(w.Body.String(), `"area":12`) {
+		t.Errorf("Expected the circle variant, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("/shapes", `{"shape":{"kind":"square","side":3}}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"area":9`) {
+		t.Errorf("Expected the square variant, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("/shapes", `{"shape":{"kind":"triangle"}}`)
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "triangle") {
+		t.Errorf("Expected an unknown discriminator to be rejected, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serve("/payments", `{"type":"bank","iban":"DE89"}`)
+	if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != `{"type":"bank","iban":"DE89"}` {
+		t.Errorf("Expected the bank variant, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A type of the same name is not affected by the registration
+	{
+		type paymentMethod struct {
+			Card *cardPayment `json:"card"`
+		}
+		data, err := jsoniter.Marshal(paymentMethod{Card: &cardPayment{Last4: "4242"}})
+		if err != nil || string(data) != `{"card":{"last4":"4242"}}` {
+			t.Errorf("Expected the unregistered type to encode as a plain struct, got %s (%v)", data, err)
+		}
+	}
+
+	validator := newSchemaValidator(spec.Components.Schemas)
+	value, _ := decodeJSONValue([]byte(`{"kind":"square","side":1}`))
+	if violations := validator.Validate(OpenAPISchema{Ref: "#/components/schemas/shape"}, value); len(violations) > 0 {
+		t.Errorf("Expected the variant to match exactly one schema, got %v", violations)
+	}
+}
+
+type testPrincipal struct {
+	id     string
+	scopes []string
+	roles  []string
+}
+
+func (p testPrincipal) ID() string       { return p.id }
+func (p testPrincipal) Scopes() []string { return p.scopes }
+func (p testPrincipal) Roles() []string  { return p.roles }
+
+// TestAuthorization tests enforcement of route and global security requirements
+func TestAuthorization(t *testing.T) {
+	router := NewRouter()
+	router.SetGlobalSecurity(RequireBearer("bearer"))
+
+	// Authenticate "scheme=scope1,scope2" entries of a test header
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx := req.Context()
+			for _, entry := range req.Header.Values("X-Test-Auth") {
+				scheme, scopes, _ := strings.Cut(entry, "=")
+				ctx = WithPrincipal(ctx, scheme, testPrincipal{id: "alice", scopes: strings.Split(scopes, ","), roles: []string{"admin"}})
+			}
+			next.ServeHTTP(w, req.WithContext(ctx))
+		})
+	})
+
+	var userID string
+	router.UseOpinionated(router.Authorization(), NewMiddleware("user").
+		Before(func(ctx *MiddlewareContext) error {
+			userID = ctx.UserID
+			return nil
+		}).
+		Build())
+
+	handler := func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	}
+	router.OpinionatedGET("/profile", handler)
+	router.OpinionatedPOST("/users", handler, WithSecurity(RequireOAuth2("oauth", "users:write"), RequireAPIKey("key")))
+	router.OpinionatedDELETE("/users", handler, WithSecurity(RequireOAuth2("oauth", "users:write", "admin")))
+	router.OpinionatedGET("/public", handler, WithSecurity(OpenAPISecurityRequirement{}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		auth   []string
+		status int
+	}{
+		{"global security without principal", "GET", "/profile", nil, http.StatusUnauthorized},
+		{"global security", "GET", "/profile", []string{"bearer="}, http.StatusOK},
+		{"route security overrides global", "POST", "/users", []string{"bearer="}, http.StatusUnauthorized},
+		{"missing scope", "POST", "/users", []string{"oauth=users:read"}, http.StatusForbidden},
+		{"scope", "POST", "/users", []string{"oauth=users:read,users:write"}, http.StatusOK},
+		{"alternative requirement", "POST", "/users", []string{"key="}, http.StatusOK},
+		{"scope and role", "DELETE", "/users", []string{"oauth=users:write"}, http.StatusOK},
+		{"anonymous requirement", "GET", "/public", nil, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID = ""
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for _, auth := range tt.auth {
+				req.Header.Add("X-Test-Auth", auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.status == http.StatusOK && len(tt.auth) > 0 && userID != "alice" {
+				t.Errorf("Expected user ID from the principal, got %q", userID)
+			}
+		})
+	}
+}
+
+// TestPrincipalLookup tests that principals are looked up by exact scheme, apart from FirstPrincipal
+func TestPrincipalLookup(t *testing.T) {
+	ctx := context.Background()
+	if FirstPrincipal(ctx) != nil || PrincipalFromContext(ctx, "") != nil {
+		t.Fatal("Expected no principal on an anonymous context")
+	}
+
+	ctx = WithPrincipal(ctx, "apiKey", StaticPrincipal{Subject: "service"})
+	ctx = WithPrincipal(ctx, "bearer", StaticPrincipal{Subject: "alice"})
+
+	if principal := FirstPrincipal(ctx); principal == nil || principal.ID() != "service" {
+		t.Errorf("Expected the first principal recorded, got %v", principal)
+	}
+	if principal := PrincipalFromContext(ctx, "bearer"); principal == nil || principal.ID() != "alice" {
+		t.Errorf("Expected the bearer principal, got %v", principal)
+	}
+	if principal := PrincipalFromContext(ctx, ""); principal != nil {
+		t.Errorf("Expected an empty scheme to match no principal, got %v", principal)
+	}
+}
+
+// TestAuthentication tests the authenticator chain with API key, basic and mutual TLS schemes
+func TestAuthentication(t *testing.T) {
+	router := NewRouter()
+	router.RegisterSecurityScheme("apiKey", APIKeyInHeader("X-API-Key", "API key"))
+	router.RegisterSecurityScheme("queryKey", APIKeyInQuery("api_key", "API key in query"))
+	router.RegisterSecurityScheme("basic", BasicAuth("Basic authentication"))
+	router.RegisterSecurityScheme("mtls", MutualTLS("Client certificates"))
+
+	keys := StaticAPIKeys{"secret-key": StaticPrincipal{Subject: "service", GrantedScopes: []string{"reports:read"}}}
+	router.Use(router.Authentication(
+		APIKeyAuthenticator("apiKey", keys),
+		APIKeyAuthenticator("queryKey", keys),
+		BasicAuthenticator("basic", func(ctx context.Context, username, password string) (Principal, error) {
+			if username == "alice" && password == "wonderland" {
+				return StaticPrincipal{Subject: "alice"}, nil
+			}
+			return nil, nil
+		}),
+		MutualTLSAuthenticator("mtls", nil),
+	))
+	router.UseOpinionated(router.Authorization())
+
+	router.OpinionatedGET("/reports", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{Name: ctx.Principal().ID()}, nil
+	}, WithSecurity(RequireOAuth2("apiKey", "reports:read"), RequireOAuth2("queryKey", "reports:read"), RequireBasicAuth("basic"), RequireOAuth2("mtls", "ops")))
+
+	cert := testClientCertificate(t, "device-7", "ops")
+
+	tests := []struct {
+		name    string
+		prepare func(req *http.Request)
+		status  int
+		user    string
+	}{
+		{"anonymous", func(req *http.Request) {}, http.StatusUnauthorized, ""},
+		{"api key header", func(req *http.Request) { req.Header.Set("X-API-Key", "secret-key") }, http.StatusOK, "service"},
+		{"api key query", func(req *http.Request) { req.URL.RawQuery = "api_key=secret-key" }, http.StatusOK, "service"},
+		{"unknown api key", func(req *http.Request) { req.Header.Set("X-API-Key", "nope") }, http.StatusUnauthorized, ""},
+		{"basic", func(req *http.Request) { req.SetBasicAuth("alice", "wonderland") }, http.StatusOK, "alice"},
+		{"wrong password", func(req *http.Request) { req.SetBasicAuth("alice", "nope") }, http.StatusUnauthorized, ""},
+		{"client certificate", func(req *http.Request) {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}, http.StatusOK, "device-7"},
+		{"unverified client certificate", func(req *http.Request) {
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}, http.StatusUnauthorized, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/reports", nil)
+			tt.prepare(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.status {
+				t.Fatalf("Expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.user != "" && !strings.Contains(w.Body.String(), `"name":"`+tt.user+`"`) {
+				t.Errorf("Expected principal %q, got %s", tt.user, w.Body.String())
+			}
+		})
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected an authenticator for an unregistered scheme to panic")
+		}
+	}()
+	router.Authentication(APIKeyAuthenticator("missing", keys))
+}
+
+// testClientCertificate creates a self-signed client certificate
+func testClientCertificate(t *testing.T, commonName, unit string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, OrganizationalUnit: []string{unit}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// TestRateLimitDocumentation tests that route and group rate limit policies are documented
+func TestRateLimitDocumentation(t *testing.T) {
+	router := NewRouter()
+	handler := func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	}
+
+	router.OpinionatedGET("/health", handler)
+	router.OpinionatedGET("/search", handler, WithRateLimit(RateLimitPolicy{Name: "search", Limit: 5, Period: time.Minute}))
+	router.Route("/reports", func(r Router) {
+		r.RateLimit(RateLimitPolicy{Limit: 100, Period: time.Hour, Burst: 10})
+		r.OpinionatedGET("/daily", handler)
+		r.OpinionatedGET("/export", handler, WithRateLimit(RateLimitPolicy{Limit: 1, Period: time.Minute}))
+	})
+	router.OpinionatedGET("/poll", handler, WithRateLimit(RateLimitPolicy{Limit: 2, Period: 500 * time.Millisecond}))
+	router.EnableOpenAPI()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+	var spec struct {
+		Paths map[string]map[string]struct {
+			RateLimit map[string]interface{} `json:"x-ratelimit"`
+			Responses map[string]struct {
+				Headers map[string]interface{} `json:"headers"`
+			} `json:"responses"`
+		} `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatal(err)
+	}
+
+	if operation := spec.Paths["/health"]["get"]; operation.RateLimit != nil || operation.Responses["429"].Headers != nil {
+		t.Errorf("Expected no rate limit on /health, got %+v", operation)
+	}
+
+	tests := map[string]map[string]interface{}{
+		"/search":         {"name": "search", "limit": 5.0, "periodMs": 60000.0, "burst": 5.0},
+		"/reports/daily":  {"limit": 100.0, "periodMs": 3600000.0, "burst": 10.0},
+		"/reports/export": {"limit": 1.0, "periodMs": 60000.0, "burst": 1.0},
+		"/poll":           {"limit": 2.0, "periodMs": 500.0, "burst": 2.0},
+	}
+	for path, expected := range tests {
+		operation := spec.Paths[path]["get"]
+		if !reflect.DeepEqual(operation.RateLimit, expected) {
+			t.Errorf("Expected x-ratelimit %v on %s, got %v", expected, path, operation.RateLimit)
+		}
+		if _, ok := operation.Responses["429"].Headers["Retry-After"]; !ok {
+			t.Errorf("Expected a 429 response with Retry-After on %s, got %+v", path, operation.Responses["429"])
+		}
+	}
+
+	var operation OpenAPIOperation
+	data, _ := json.Marshal(router.GetOpenAPISpec().Paths["/search"]["get"])
+	if err := json.Unmarshal(data, &operation); err != nil || operation.Extensions[RateLimitExtension] == nil {
+		t.Errorf("Expected extensions to survive a round trip, got %v, %v", operation.Extensions, err)
+	}
+}
+
+// TestRoutePattern tests that the matched route pattern is exposed to middleware and handlers
+func TestRoutePattern(t *testing.T) {
+	router := NewRouter()
+	var seen []string
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, RoutePattern(r))
+			next.ServeHTTP(w, r)
+		})
+	})
+
+	router.GET("/users/:id/posts/:postId", func(w http.ResponseWriter, r *http.Request) {})
+	router.GET("/users/me", func(w http.ResponseWriter, r *http.Request) {})
+	router.OpinionatedGET("/items/{id}", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{Name: ctx.RoutePattern()}, nil
+	})
+
+	for _, path := range []string{"/users/1/posts/2", "/users/me", "/items/42"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	expected := []string{"/users/:id/posts/:postId", "/users/me", "/items/:id"}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Errorf("Expected patterns %v, got %v", expected, seen)
+	}
+
+	req := httptest.NewRequest("GET", "/items/42", nil)
+	if pattern := RoutePattern(req); pattern != "" {
+		t.Errorf("Expected no pattern outside the router, got %q", pattern)
+	}
+}
+
+// TestParseTraceparent tests W3C traceparent parsing and formatting
+func TestParseTraceparent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc, err := ParseTraceparent(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sc.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanID.String() != "00f067aa0ba902b7" || !sc.Sampled || !sc.Remote {
+		t.Errorf("Unexpected span context %+v", sc)
+	}
+	if sc.Traceparent() != valid {
+		t.Errorf("Expected %s, got %s", valid, sc.Traceparent())
+	}
+
+	if sc, err := ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil || sc.Sampled {
+		t.Errorf("Expected later versions with extra fields to parse, got %+v, %v", sc, err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+	} {
+		if _, err := ParseTraceparent(invalid); err == nil {
+			t.Errorf("Expected %q to be rejected", invalid)
+		}
+	}
+}
+
+// tracingTestMiddleware starts a server span per request like middleware.Tracing
+func tracingTestMiddleware(tracer *Tracer) MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, span := tracer.Start(ExtractTraceContext(r.Context(), r.Header), r.Method+" "+RoutePattern(r), SpanKindServer)
+			defer span.End()
+			InjectTraceContext(ctx, w.Header())
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// TestTracing tests trace propagation and the errors recorded on request spans
+func TestTracing(t *testing.T) {
+	exporter := NewInMemorySpanExporter()
+	router := NewRouter()
+	router.Use(tracingTestMiddleware(NewTracer("orders", exporter)))
+
+	router.OpinionatedGET("/orders/:id", func(ctx *Context, req struct {
+		ID int `path:"id"`
+	}) (*TestResponse3, error) {
+		_, span := StartSpan(ctx.Request.Context(), "load order", SpanKindInternal)
+		span.End()
+		if req.ID == 0 {
+			return nil, NotFound("order")
+		}
+		return nil, errors.New("database unavailable")
+	})
+
+	req := httptest.NewRequest("GET", "/orders/7", nil)
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(TracestateHeader, "vendor=opaque")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a child and a server span, got %d", len(spans))
+	}
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /orders/:id" || server.Kind != SpanKindServer || server.ServiceName != "orders" {
+		t.Errorf("Expected a server span named by route pattern, got %s %s", server.Name, server.Kind)
+	}
+	if server.SpanContext.TraceID.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || server.ParentSpanID.String() != "00f067aa0ba902b7" || server.SpanContext.TraceState != "vendor=opaque" {
+		t.Errorf("Expected the server span to continue the remote trace, got %+v", server.SpanContext)
+	}
+	if child.SpanContext.TraceID != server.SpanContext.TraceID || child.ParentSpanID != server.SpanContext.SpanID {
+		t.Errorf("Expected the handler span to be a child of the server span")
+	}
+	if server.StatusCode != SpanStatusError || len(server.Events) != 1 || server.Events[0].Attributes["exception.message"] != "database unavailable" {
+		t.Errorf("Expected the handled error on the span, got %s %+v", server.StatusCode, server.Events)
+	}
+
+	if got := w.Header().Get(TraceparentHeader); got != server.SpanContext.Traceparent() {
+		t.Errorf("Expected the response traceparent %s, got %s", server.SpanContext.Traceparent(), got)
+	}
+	var response ErrorResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	if response.Error.RequestID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the trace ID as request ID, got %q", response.Error.RequestID)
+	}
+
+	exporter.Reset()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/orders/0", nil))
+	spans = exporter.Spans()
+	if len(spans) != 2 || spans[1].StatusCode != SpanStatusUnset || len(spans[1].Events) != 1 || spans[1].ParentSpanID.IsValid() {
+		t.Errorf("Expected client errors to be recorded without failing a new trace, got %+v", spans[len(spans)-1])
+	}
+}
+
+// TestRequestLogger tests request-scoped loggers and the logging of binding failures
+func TestRequestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	router := NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := WithLogger(r.Context(), logger)
+			ctx = WithRequestID(ctx, "req-1")
+			ctx = WithPrincipal(ctx, "bearer", StaticPrincipal{Subject: "alice"})
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	router.OpinionatedGET("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		ctx.Logger().Info("loading user", "id", req.ID)
+		return &TestResponse3{ID: req.ID}, nil
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/42", nil))
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "loading user" || entry["request_id"] != "req-1" || entry["route"] != "/users/:id" || entry["principal"] != "alice" {
+		t.Errorf("Expected a request-scoped entry, got %v", entry)
+	}
+
+	logs.Reset()
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/users/abc", nil))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status 422, got %d", w.Code)
+	}
+	entry = nil
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "request binding failed" || entry["level"] != "WARN" || entry["request_id"] != "req-1" {
+		t.Errorf("Expected the binding failure to be logged, got %v", entry)
+	}
+
+	logs.Reset()
+	handler := Recoverer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	req := httptest.NewRequest("GET", "/panic", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(WithLogger(req.Context(), logger)))
+
+	entry = nil
+	if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["msg"] != "panic recovered" || entry["panic"] != "boom" || entry["stack"] == "" {
+		t.Errorf("Expected the panic to be logged, got %v", entry)
+	}
+}
+
+// TestRecoverer tests panic recovery with structured errors and reports
+func TestRecoverer(t *testing.T) {
+	var reports []*PanicError
+	router := NewRouter()
+	router.Use(Recoverer)
+	router.OnPanic(func(req *http.Request, err *PanicError) {
+		reports = append(reports, err)
+	})
+	router.OpinionatedGET("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		var users map[int]*TestResponse3
+		users[req.ID] = &TestResponse3{}
+		return users[req.ID], nil
+	})
+	router.GET("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("partial"))
+		panic("stream failed")
+	})
+	router.GET("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+
+	req := httptest.NewRequest("GET", "/users/1", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected status 500, got %d", w.Code)
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error.Code != "INTERNAL_ERROR" || response.Error.RequestID != "req-1" || response.Error.Path != "/users/1" || response.Error.Detail != nil {
+		t.Errorf("Expected a structured error without the panic value, got %+v", response.Error)
+	}
+	if len(reports) != 1 || !strings.Contains(reports[0].Error(), "assignment to entry in nil map") || !bytes.Contains(reports[0].Stack, []byte("TestRecoverer")) {
+		t.Fatalf("Expected the panic to be reported with its stack, got %v", reports)
+	}
+	var runtimeErr interface{ RuntimeError() }
+	if !errors.As(reports[0], &runtimeErr) {
+		t.Error("Expected the panic error to unwrap to the runtime error")
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/stream", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "partial" {
+		t.Errorf("Expected the started response to be left alone, got %d %q", w.Code, w.Body.String())
+	}
+	if len(reports) != 2 || reports[1].Value != "stream failed" {
+		t.Errorf("Expected the panic after the response started to be reported, got %v", reports)
+	}
+
+	func() {
+		defer func() {
+			if value := recover(); value != http.ErrAbortHandler {
+				t.Errorf("Expected http.ErrAbortHandler to be re-panicked, got %v", value)
+			}
+		}()
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/abort", nil))
+	}()
+	if len(reports) != 2 {
+		t.Errorf("Expected http.ErrAbortHandler not to be reported, got %d reports", len(reports))
+	}
+}
+
+// TestTimeout tests buffered timeouts, per-route overrides and the documented 504 response
+// TestTimeoutParamsAfterDeadline tests that a handler outliving its timeout keeps its own URL params
+func TestTimeoutParamsAfterDeadline(t *testing.T) {
+	seen := make(chan string, 1)
+
+	router := NewRouter()
+	router.Use(Timeout(10 * time.Millisecond))
+	router.GET("/slow/:id", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		seen <- URLParam(r, "id")
+	})
+	router.GET("/fast/:id", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(80 * time.Millisecond)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/slow/AAA", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("Expected a 504, got %d", w.Code)
+	}
+
+	// The next request reuses the pooled params while the timed out handler is still running
+	go router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fast/BBB", nil))
+
+	if id := <-seen; id != "AAA" {
+		t.Errorf("Expected the timed out handler to read its own param AAA, got %q", id)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	lateWrites := make(chan error, 1)
+	var reports []*PanicError
+
+	router := NewRouter()
+	router.Use(Recoverer)
+	router.Use(Timeout(20 * time.Millisecond))
+	router.OnPanic(func(req *http.Request, err *PanicError) {
+		reports = append(reports, err)
+	})
+	router.GET("/slow", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Partial", "true")
+		time.Sleep(60 * time.Millisecond)
+		_, err := w.Write([]byte("late"))
+		lateWrites <- err
+	})
+	router.GET("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Fast", "true")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("done"))
+	})
+	router.GET("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("timed handler failed")
+	})
+	router.OpinionatedGET("/reports/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		select {
+		case <-time.After(40 * time.Millisecond):
+			return &TestResponse3{ID: req.ID}, nil
+		case <-ctx.Request.Context().Done():
+			return nil, ctx.Request.Context().Err()
+		}
+	}, WithTimeout(200*time.Millisecond))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/slow", nil))
+	if w.Code != http.StatusGatewayTimeout || w.Header().Get("X-Partial") != "" {
+		t.Fatalf("Expected a 504 without the handler's headers, got %d %v", w.Code, w.Header())
+	}
+	var response ErrorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.Error.Code != "GATEWAY_TIMEOUT" {
+		t.Errorf("Expected a structured timeout error, got %q", w.Body.String())
+	}
+	if err := <-lateWrites; err != http.ErrHandlerTimeout {
+		t.Errorf("Expected the late write to fail with http.ErrHandlerTimeout, got %v", err)
+	}
+	if strings.Contains(w.Body.String(), "late") {
+		t.Errorf("Expected the late write to be discarded, got %q", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/fast", nil))
+	if w.Code != http.StatusCreated || w.Header().Get("X-Fast") != "true" || w.Body.String() != "done" {
+		t.Errorf("Expected the buffered response, got %d %v %q", w.Code, w.Header(), w.Body.String())
+	}
+
+	// The route's own timeout replaces the middleware's
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/reports/7", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the route timeout to override the middleware, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+	if w.Code != http.StatusInternalServerError || len(reports) != 1 || !bytes.Contains(reports[0].Stack, []byte("TestTimeout")) {
+		t.Errorf("Expected the panic to be recovered with the handler's stack, got %d %v", w.Code, reports)
+	}
+
+	operation := router.openAPISpec.Paths["/reports/{id}"]["get"]
+	if response, ok := operation.Responses["504"]; !ok || response.Content["application/json"].Schema.Ref != "#/components/schemas/ErrorResponse" {
+		t.Errorf("Expected a documented 504 response, got %+v", operation.Responses["504"])
+	}
+
+	// Route timeouts apply without the middleware, and the status is configurable
+	standalone := NewRouter()
+	standalone.OpinionatedGET("/reports/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		<-ctx.Request.Context().Done()
+		return nil, ctx.Request.Context().Err()
+	}, WithTimeout(10*time.Millisecond))
+	w = httptest.NewRecorder()
+	standalone.ServeHTTP(w, httptest.NewRequest("GET", "/reports/7", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected the route timeout to be enforced, got %d", w.Code)
+	}
+
+	unavailable := Timeout(10*time.Millisecond, TimeoutConfig{Status: http.StatusServiceUnavailable, Message: "busy"})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		}))
+	w = httptest.NewRecorder()
+	unavailable.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "busy") {
+		t.Errorf("Expected the configured 503, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Routes registered with {param} patterns keep their overrides, and SSE streams are not buffered
+	lateReports := make(chan *PanicError, 1)
+	braces := NewRouter()
+	braces.Use(Timeout(20 * time.Millisecond))
+	braces.OnPanic(func(req *http.Request, err *PanicError) {
+		lateReports <- err
+	})
+	braces.OpinionatedGET("/items/{id}", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		time.Sleep(40 * time.Millisecond)
+		return &TestResponse3{ID: req.ID}, nil
+	}, WithTimeout(200*time.Millisecond))
+	braces.SSE("/events/{topic}", func(conn *SSEConnection, params struct{}) error {
+		time.Sleep(40 * time.Millisecond)
+		return conn.SendMessage(SSEMessage{Event: "tick", Data: "a"})
+	})
+	braces.GET("/late-panic", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		panic("after the deadline")
+	})
+
+	w = httptest.NewRecorder()
+	braces.ServeHTTP(w, httptest.NewRequest("GET", "/items/7", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the {id} route timeout to override the middleware, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	braces.ServeHTTP(w, httptest.NewRequest("GET", "/events/news", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event: tick") {
+		t.Errorf("Expected the SSE stream to bypass the timeout, got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	braces.ServeHTTP(w, httptest.NewRequest("GET", "/late-panic", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected a 504 before the panic, got %d", w.Code)
+	}
+	select {
+	case report := <-lateReports:
+		if report.Value != "after the deadline" {
+			t.Errorf("Unexpected report %v", report)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the panic after the deadline to be reported")
+	}
+}
+
+// TestGroupOpinionatedMiddleware tests that opinionated routes of groups run and document the global opinionated middleware
+func TestGroupOpinionatedMiddleware(t *testing.T) {
+	router := NewRouter()
+	router.UseOpinionated(NewMiddleware("tag").
+		Before(func(ctx *MiddlewareContext) error {
+			ctx.Response.Header().Set("X-Tag", "applied")
+			return nil
+		}).
+		AddResponse("418", "Tagged").
+		Build())
+	router.Route("/api", func(r Router) {
+		r.OpinionatedGET("/items", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+			return &TestResponse3{}, nil
+		})
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/items", nil))
+	if w.Code != http.StatusOK || w.Header().Get("X-Tag") != "applied" {
+		t.Errorf("Expected the opinionated middleware to run, got %d %v", w.Code, w.Header())
+	}
+
+	if _, ok := router.GetOpenAPISpec().Paths["/api/items"]["get"].Responses["418"]; !ok {
+		t.Error("Expected the middleware response to be documented")
+	}
+
+	// Middleware added through a group joins the router's chain, so routes outside the group run it too
+	router.Route("/admin", func(r Router) {
+		r.UseOpinionated(NewMiddleware("audit").
+			Before(func(ctx *MiddlewareContext) error {
+				ctx.Response.Header().Set("X-Audit", "true")
+				return nil
+			}).
+			Build())
+	})
+	router.OpinionatedGET("/status", func(ctx *Context, req struct{}) (*TestResponse3, error) {
+		return &TestResponse3{}, nil
+	})
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/status", nil))
+	if w.Header().Get("X-Tag") != "applied" || w.Header().Get("X-Audit") != "true" {
+		t.Errorf("Expected the whole opinionated chain to run, got %v", w.Header())
+	}
+}
+
+// contractTestRouter registers a documented route for the contract validation tests
+func contractTestRouter(config ContractValidationConfig) (*SteelRouter, *[]ContractReport) {
+	var reports []ContractReport
+	config.OnViolation = func(r *http.Request, report ContractReport) {
+		reports = append(reports, report)
+	}
+
+	router := NewRouter()
+	router.Use(router.ContractValidation(config))
+	router.OpinionatedPOST("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		if req.Name == "teapot" {
+			return nil, &HTTPError{Status: http.StatusTeapot, Code: "TEAPOT", Message: "I'm a teapot"}
+		}
+		return &TestResponse3{ID: req.ID, Name: req.Name}, nil
+	})
+	router.GET("/undocumented", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	return router, &reports
+}
+
+// TestContractValidationLogOnly tests that violations are reported without changing responses
+func TestContractValidationLogOnly(t *testing.T) {
+	router, reports := contractTestRouter(DefaultContractValidationConfig())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users/1?name=teapot", strings.NewReader(`{"email":"a@b.c","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTeapot {
+		t.Errorf("Expected status 418 to pass through, got %d", w.Code)
+	}
+	if len(*reports) != 1 {
+		t.Fatalf("Expected 1 report, got %d", len(*reports))
+	}
+	violation := (*reports)[0].Violations[0]
+	if violation.Direction != ContractDirectionResponse || violation.In != "status" {
+		t.Errorf("Expected undocumented status violation, got %+v", violation)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/undocumented", nil))
+	if w.Code != http.StatusTeapot || len(*reports) != 1 {
+		t.Errorf("Expected undocumented routes to be skipped, got status %d and %d reports", w.Code, len(*reports))
+	}
+}
+
+// TestContractValidationEnforce tests that invalid requests are rejected with JSON pointers to the violations
+func TestContractValidationEnforce(t *testing.T) {
+	config := DefaultContractValidationConfig()
+	config.Mode = ContractModeEnforce
+	router, reports := contractTestRouter(config)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/users/abc", strings.NewReader(`[]`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	pointers := make(map[string]bool)
+	for _, violation := range (*reports)[0].Violations {
+		pointers[violation.In+" "+violation.Pointer] = true
+	}
+	for _, expected := range []string{"path /id", "body "} {
+		if !pointers[expected] {
+			t.Errorf("Expected violation at %q, got %v", expected, pointers)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/users/1?name=teapot", strings.NewReader(`{"email":"a@b.c","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("Expected undocumented response to be replaced with 500, got %d", w.Code)
+	}
+	if strings.Contains(w.Body.String(), "teapot") {
+		t.Errorf("Expected original response to be discarded, got %s", w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/users/7?name=bob", strings.NewReader(`{"email":"a@b.c","password":"x"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected valid request to pass, got %d: %s", w.Code, w.Body.String())
+	}
+	var response TestResponse3
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil || response.ID != 7 {
+		t.Errorf("Expected buffered response to be written, got %s", w.Body.String())
+	}
+}
+
+// TestContractValidationEnforceLimits tests that replaced responses keep the headers set by outer
+// middleware, and that oversized request bodies are rejected
+func TestContractValidationEnforceLimits(t *testing.T) {
+	config := DefaultContractValidationConfig()
+	config.Mode = ContractModeEnforce
+	config.MaxBodyBytes = 64
+	config.OnViolation = func(r *http.Request, report ContractReport) {}
+
+	router := NewRouter()
+	router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Request-ID", "req-1")
+			next.ServeHTTP(w, r)
+		})
+	})
+	router.Use(router.ContractValidation(config))
+	router.OpinionatedPOST("/users/:id", func(ctx *Context, req TestRequest2) (*TestResponse3, error) {
+		ctx.Response.Header().Set("X-Handler", "true")
+		if req.Name == "teapot" {
+			return nil, &HTTPError{Status: http.StatusTeapot, Code: "TEAPOT", Message: "I'm a teapot"}
+		}
+		return &TestResponse3{ID: req.ID, Name: req.Name}, nil
+	})
+
+	serve := func(target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve("/users/1?name=teapot", `{"email":"a@b.c","password":"x"}`)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected the response to be replaced with 500, got %d", w.Code)
+	}
+	if w.Header().Get("X-Request-ID") != "req-1" || w.Header().Get("X-Handler") != "" {
+		t.Errorf("Expected outer headers kept and handler headers dropped, got %v", w.Header())
+	}
+
+	w = serve("/users/7?name=bob", `{"email":"a@b.c","password":"x"}`)
+	if w.Code != http.StatusOK || w.Header().Get("X-Request-ID") != "req-1" || w.Header().Get("X-Handler") != "true" {
+		t.Errorf("Expected the valid response with all headers, got %d %v", w.Code, w.Header())
+	}
+
+	w = serve("/users/7?name=bob", `{"email":"`+strings.Repeat("a", 100)+`@b.c","password":"x"}`)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected an oversized body to be rejected with 413, got %d", w.Code)
+	}
+}
+
+// TestContractValidationSampling tests that requests outside the sample rate are not validated
+func TestContractValidationSampling(t *testing.T) {
+	config := DefaultContractValidationConfig()
+	config.Mode = ContractModeEnforce
+	config.SampleRate = 0
+	router, reports := contractTestRouter(config)
+
+	for i := 0; i < 10; i++ {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "/users/1?name=teapot", strings.NewReader(`{}`)))
+		if w.Code != http.StatusTeapot {
+			t.Fatalf("Expected unsampled request to pass through, got %d", w.Code)
+		}
+	}
+	if len(*reports) != 0 {
+		t.Errorf("Expected no reports, got %d", len(*reports))
+	}
+}
+
 // TestContextHelpers tests Context helper methods
 func TestContextHelpers(t *testing.T) {
 	router := NewRouter()
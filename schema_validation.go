@@ -0,0 +1,496 @@
+package steel
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// SchemaViolation describes a value that does not match its schema.
+// Pointer is a JSON pointer (RFC 6901) to the offending value.
+type SchemaViolation struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+// schemaValidator validates decoded JSON values against OpenAPI schemas, resolving references
+// against the component schemas of a specification.
+type schemaValidator struct {
+	components map[string]OpenAPISchema
+	patterns   sync.Map // pattern string -> *regexp.Regexp
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// newSchemaValidator creates a validator resolving references against components.
+func newSchemaValidator(components map[string]OpenAPISchema) *schemaValidator {
+	return &schemaValidator{components: components}
+}
+
+// decodeJSONValue decodes a JSON document keeping numbers as json.Number so integers can be told apart from floats.
+func decodeJSONValue(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Validate checks value against schema and returns every violation found.
+func (v *schemaValidator) Validate(schema OpenAPISchema, value interface{}) []SchemaViolation {
+	var violations []SchemaViolation
+	v.validate(schema, value, "", &violations)
+	return violations
+}
+
+// resolve follows component references until a schema without one is reached.
+func (v *schemaValidator) resolve(schema OpenAPISchema) (OpenAPISchema, bool) {
+	for depth := 0; schema.Ref != ""; depth++ {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		resolved, ok := v.components[name]
+		if !ok || depth > 32 {
+			return schema, false
+		}
+		schema = resolved
+	}
+	return schema, true
+}
+
+func (v *schemaValidator) validate(schema OpenAPISchema, value interface{}, pointer string, violations *[]SchemaViolation) {
+	report := func(format string, args ...interface{}) {
+		*violations = append(*violations, SchemaViolation{Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+	}
+
+	schema, ok := v.resolve(schema)
+	if !ok {
+		report("unresolved schema reference %s", schema.Ref)
+		return
+	}
+
+	types := schemaTypes(schema.Type)
+
+	if value == nil {
+		// Go encodes nil slices and maps as null, so arrays and objects accept it like explicit null types.
+		if len(types) == 0 || containsString(types, "null") || containsString(types, "array") ||
+			containsString(types, "object") || (schema.Nullable != nil && *schema.Nullable) {
+			return
+		}
+		report("expected %s, got null", strings.Join(types, " or "))
+		return
+	}
+
+	if len(types) > 0 && !valueMatchesTypes(value, types) {
+		report("expected %s, got %s", strings.Join(types, " or "), jsonTypeName(value))
+		return
+	}
+
+	if len(schema.Enum) > 0 && !valueInEnum(value, schema.Enum) {
+		report("value %v is not one of %v", value, schema.Enum)
+	}
+	if schema.Const != nil && !jsonValuesEqual(value, schema.Const) {
+		report("value %v does not equal %v", value, schema.Const)
+	}
+
+	switch typed := value.(type) {
+	case string:
+		v.validateString(schema, typed, report)
+	case json.Number:
+		validateNumber(schema, typed, report)
+	case map[string]interface{}:
+		v.validateObject(schema, typed, pointer, violations, report)
+	case []interface{}:
+		v.validateArray(schema, typed, pointer, violations, report)
+	}
+
+	v.validateComposition(schema, value, pointer, violations, report)
+}
+
+func (v *schemaValidator) validateString(schema OpenAPISchema, value string, report func(string, ...interface{})) {
+	length := utf8.RuneCountInString(value)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		report("length %d is shorter than %d", length, *schema.MinLength)
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		report("length %d is longer than %d", length, *schema.MaxLength)
+	}
+
+	if schema.Pattern != "" {
+		if pattern, err := v.pattern(schema.Pattern); err == nil && !pattern.MatchString(value) {
+			report("value %q does not match pattern %s", value, schema.Pattern)
+		}
+	}
+
+	if err := validateFormat(schema.Format, value); err != nil {
+		report("value %q is not a valid %s", value, schema.Format)
+	}
+}
+
+// pattern compiles and caches a schema pattern.
+func (v *schemaValidator) pattern(expr string) (*regexp.Regexp, error) {
+	if cached, ok := v.patterns.Load(expr); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns.Store(expr, compiled)
+	return compiled, nil
+}
+
+// validateFormat checks the string formats the generator emits. Unknown formats are accepted.
+func validateFormat(format, value string) error {
+	var err error
+	switch format {
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, value)
+	case "date":
+		_, err = time.Parse("2006-01-02", value)
+	case "uuid":
+		if !uuidPattern.MatchString(value) {
+			err = fmt.Errorf("invalid uuid")
+		}
+	case "email":
+		if at := strings.LastIndex(value, "@"); at <= 0 || at == len(value)-1 {
+			err = fmt.Errorf("invalid email")
+		}
+	case "uri":
+		var parsed *url.URL
+		if parsed, err = url.Parse(value); err == nil && parsed.Scheme == "" {
+			err = fmt.Errorf("missing scheme")
+		}
+	case "ipv4":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			err = fmt.Errorf("invalid ipv4")
+		}
+	case "ipv6":
+		if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+			err = fmt.Errorf("invalid ipv6")
+		}
+	}
+	return err
+}
+
+func validateNumber(schema OpenAPISchema, value json.Number, report func(string, ...interface{})) {
+	number, err := value.Float64()
+	if err != nil {
+		report("invalid number %s", value)
+		return
+	}
+
+	if schema.Minimum != nil && number < *schema.Minimum {
+		report("value %v is less than minimum %v", number, *schema.Minimum)
+	}
+	if schema.Maximum != nil && number > *schema.Maximum {
+		report("value %v is greater than maximum %v", number, *schema.Maximum)
+	}
+
+	// exclusiveMinimum/exclusiveMaximum are numbers in 3.1 and flags on minimum/maximum in 3.0.
+	if limit, ok := exclusiveLimit(schema.ExclusiveMinimum, schema.Minimum); ok && number <= limit {
+		report("value %v must be greater than %v", number, limit)
+	}
+	if limit, ok := exclusiveLimit(schema.ExclusiveMaximum, schema.Maximum); ok && number >= limit {
+		report("value %v must be less than %v", number, limit)
+	}
+
+	if schema.MultipleOf != nil && *schema.MultipleOf != 0 {
+		if quotient := number / *schema.MultipleOf; math.Abs(quotient-math.Round(quotient)) > 1e-9 {
+			report("value %v is not a multiple of %v", number, *schema.MultipleOf)
+		}
+	}
+}
+
+func exclusiveLimit(exclusive interface{}, bound *float64) (float64, bool) {
+	switch limit := exclusive.(type) {
+	case bool:
+		if limit && bound != nil {
+			return *bound, true
+		}
+	case float64:
+		return limit, true
+	case int:
+		return float64(limit), true
+	}
+	return 0, false
+}
+
+func (v *schemaValidator) validateObject(schema OpenAPISchema, object map[string]interface{}, pointer string,
+	violations *[]SchemaViolation, report func(string, ...interface{})) {
+	required := make(map[string]bool, len(schema.Required))
+	for _, name := range schema.Required {
+		required[name] = true
+		if _, ok := object[name]; !ok {
+			*violations = append(*violations, SchemaViolation{
+				Pointer: pointer + "/" + escapeJSONPointer(name),
+				Message: "required property is missing",
+			})
+		}
+	}
+
+	if schema.MinProperties != nil && len(object) < *schema.MinProperties {
+		report("object has fewer than %d properties", *schema.MinProperties)
+	}
+	if schema.MaxProperties != nil && len(object) > *schema.MaxProperties {
+		report("object has more than %d properties", *schema.MaxProperties)
+	}
+
+	for name, property := range object {
+		propertyPointer := pointer + "/" + escapeJSONPointer(name)
+
+		if propertySchema, ok := schema.Properties[name]; ok {
+			// Optional properties may be null, which is how Go encodes nil pointers.
+			if property == nil && !required[name] {
+				continue
+			}
+			v.validate(propertySchema, property, propertyPointer, violations)
+			continue
+		}
+
+		switch additional := schema.AdditionalProperties.(type) {
+		case bool:
+			if !additional {
+				*violations = append(*violations, SchemaViolation{Pointer: propertyPointer, Message: "additional property is not allowed"})
+			}
+		case OpenAPISchema:
+			v.validate(additional, property, propertyPointer, violations)
+		case *OpenAPISchema:
+			if additional != nil {
+				v.validate(*additional, property, propertyPointer, violations)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateArray(schema OpenAPISchema, array []interface{}, pointer string,
+	violations *[]SchemaViolation, report func(string, ...interface{})) {
+	if schema.MinItems != nil && len(array) < *schema.MinItems {
+		report("array has fewer than %d items", *schema.MinItems)
+	}
+	if schema.MaxItems != nil && len(array) > *schema.MaxItems {
+		report("array has more than %d items", *schema.MaxItems)
+	}
+
+	if schema.UniqueItems != nil && *schema.UniqueItems {
+		for i := range array {
+			for j := i + 1; j < len(array); j++ {
+				if jsonValuesEqual(array[i], array[j]) {
+					report("items %d and %d are equal", i, j)
+				}
+			}
+		}
+	}
+
+	for i, item := range array {
+		itemPointer := pointer + "/" + strconv.Itoa(i)
+		if i < len(schema.PrefixItems) {
+			v.validate(schema.PrefixItems[i], item, itemPointer, violations)
+			continue
+		}
+
+		switch items := schema.Items.(type) {
+		case OpenAPISchema:
+			v.validate(items, item, itemPointer, violations)
+		case *OpenAPISchema:
+			if items != nil {
+				v.validate(*items, item, itemPointer, violations)
+			}
+		}
+	}
+}
+
+func (v *schemaValidator) validateComposition(schema OpenAPISchema, value interface{}, pointer string,
+	violations *[]SchemaViolation, report func(string, ...interface{})) {
+	for _, sub := range schema.AllOf {
+		v.validate(sub, value, pointer, violations)
+	}
+
+	if len(schema.AnyOf) > 0 {
+		matched := false
+		for _, sub := range schema.AnyOf {
+			if len(v.Validate(sub, value)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report("value does not match any of the allowed schemas")
+		}
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, sub := range schema.OneOf {
+			if len(v.Validate(sub, value)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			report("value matches %d schemas, expected exactly one", matches)
+		}
+	}
+
+	if schema.Not != nil && len(v.Validate(*schema.Not, value)) == 0 {
+		report("value matches a schema it must not match")
+	}
+}
+
+// coerceParameter converts a raw path, query or header value to the JSON type its schema declares,
+// so it can be validated like a body value.
+func (v *schemaValidator) coerceParameter(schema OpenAPISchema, raw string) interface{} {
+	schema, _ = v.resolve(schema)
+	types := schemaTypes(schema.Type)
+
+	switch {
+	case containsString(types, "integer"), containsString(types, "number"):
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case containsString(types, "boolean"):
+		if parsed, err := strconv.ParseBool(raw); err == nil {
+			return parsed
+		}
+	case containsString(types, "array"):
+		var items []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			item := interface{}(part)
+			if itemSchema, ok := schema.Items.(OpenAPISchema); ok {
+				item = v.coerceParameter(itemSchema, part)
+			}
+			items = append(items, item)
+		}
+		return items
+	}
+	return raw
+}
+
+// schemaTypes normalizes the type keyword, which is a string or a list of strings in OpenAPI 3.1.
+func schemaTypes(t interface{}) []string {
+	switch typed := t.(type) {
+	case string:
+		return []string{typed}
+	case []string:
+		return typed
+	case []interface{}:
+		types := make([]string, 0, len(typed))
+		for _, item := range typed {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+func valueMatchesTypes(value interface{}, types []string) bool {
+	for _, t := range types {
+		switch t {
+		case "integer":
+			if number, ok := value.(json.Number); ok {
+				if _, err := number.Int64(); err == nil {
+					return true
+				}
+				if f, err := number.Float64(); err == nil && f == math.Trunc(f) {
+					return true
+				}
+			}
+		case "number":
+			if _, ok := value.(json.Number); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func valueInEnum(value interface{}, enum []interface{}) bool {
+	for _, allowed := range enum {
+		if jsonValuesEqual(value, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonValuesEqual compares a decoded JSON value with a value from a schema, which may use Go types.
+func jsonValuesEqual(a, b interface{}) bool {
+	if an, ok := a.(json.Number); ok {
+		af, _ := an.Float64()
+		switch bn := b.(type) {
+		case json.Number:
+			bf, _ := bn.Float64()
+			return af == bf
+		case float64, float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			bf, _ := strconv.ParseFloat(fmt.Sprint(bn), 64)
+			return af == bf
+		}
+	}
+	if _, ok := b.(json.Number); ok {
+		return jsonValuesEqual(b, a)
+	}
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func containsString(values []string, target string) bool {
+	for _, value := range values {
+		if value == target {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeJSONPointer escapes a reference token of a JSON pointer.
+func escapeJSONPointer(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
@@ -0,0 +1,500 @@
+package steel
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SpecChangeKind classifies a difference between two OpenAPI specifications.
+type SpecChangeKind string
+
+// Kinds of specification changes.
+const (
+	SpecChangeOperationAdded      SpecChangeKind = "operation-added"
+	SpecChangeOperationRemoved    SpecChangeKind = "operation-removed"
+	SpecChangeOperationDeprecated SpecChangeKind = "operation-deprecated"
+	SpecChangeParameterAdded      SpecChangeKind = "parameter-added"
+	SpecChangeParameterRemoved    SpecChangeKind = "parameter-removed"
+	SpecChangeRequestBodyAdded    SpecChangeKind = "request-body-added"
+	SpecChangeRequestBodyRemoved  SpecChangeKind = "request-body-removed"
+	SpecChangeResponseAdded       SpecChangeKind = "response-added"
+	SpecChangeResponseRemoved     SpecChangeKind = "response-removed"
+	SpecChangeMediaTypeAdded      SpecChangeKind = "media-type-added"
+	SpecChangeMediaTypeRemoved    SpecChangeKind = "media-type-removed"
+	SpecChangePropertyAdded       SpecChangeKind = "property-added"
+	SpecChangePropertyRemoved     SpecChangeKind = "property-removed"
+	SpecChangeRequiredChanged     SpecChangeKind = "required-changed"
+	SpecChangeTypeChanged         SpecChangeKind = "type-changed"
+	SpecChangeFormatChanged       SpecChangeKind = "format-changed"
+	SpecChangeEnumChanged         SpecChangeKind = "enum-changed"
+)
+
+// SpecChange is a single difference between two specifications. Operation is "METHOD /path" and
+// Location names the parameter, request body or response, followed by a pointer into its schema
+// where "*" stands for array items and additional properties.
+type SpecChange struct {
+	Kind      SpecChangeKind `json:"kind"`
+	Breaking  bool           `json:"breaking"`
+	Operation string         `json:"operation"`
+	Location  string         `json:"location,omitempty"`
+	Message   string         `json:"message"`
+}
+
+func (c SpecChange) String() string {
+	severity := "non-breaking"
+	if c.Breaking {
+		severity = "BREAKING"
+	}
+	if c.Location == "" {
+		return fmt.Sprintf("%s %s: %s", severity, c.Operation, c.Message)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", severity, c.Operation, c.Location, c.Message)
+}
+
+// SpecDiff lists the changes between two specifications, ordered by operation.
+type SpecDiff struct {
+	Changes []SpecChange `json:"changes"`
+}
+
+// Breaking returns the changes that can break existing clients.
+func (d *SpecDiff) Breaking() []SpecChange {
+	var breaking []SpecChange
+	for _, change := range d.Changes {
+		if change.Breaking {
+			breaking = append(breaking, change)
+		}
+	}
+	return breaking
+}
+
+// HasBreakingChanges reports whether any change can break existing clients.
+func (d *SpecDiff) HasBreakingChanges() bool {
+	return len(d.Breaking()) > 0
+}
+
+// String lists the changes one per line.
+func (d *SpecDiff) String() string {
+	lines := make([]string, len(d.Changes))
+	for i, change := range d.Changes {
+		lines[i] = change.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// DiffOpenAPISpecs compares the operations of two specifications and classifies every change as breaking or not.
+// Request schemas break clients when they accept less than before, response schemas when they may return
+// something clients did not expect. Operations are matched by method and path, ignoring path parameter names.
+//
+//	diff := steel.DiffOpenAPISpecs(committed, router.GetOpenAPISpec())
+//	if diff.HasBreakingChanges() {
+//		t.Fatalf("breaking API changes:\n%s", diff)
+//	}
+func DiffOpenAPISpecs(base, revision *OpenAPISpec) *SpecDiff {
+	d := &specDiffer{
+		base:     newSchemaValidator(base.Components.Schemas),
+		revision: newSchemaValidator(revision.Components.Schemas),
+		diff:     &SpecDiff{Changes: []SpecChange{}},
+	}
+
+	baseOps := indexSpecOperations(base)
+	revisionOps := indexSpecOperations(revision)
+
+	keys := make([]string, 0, len(baseOps)+len(revisionOps))
+	for key := range baseOps {
+		keys = append(keys, key)
+	}
+	for key := range revisionOps {
+		if _, ok := baseOps[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldOp, inBase := baseOps[key]
+		newOp, inRevision := revisionOps[key]
+		switch {
+		case !inRevision:
+			d.add(SpecChangeOperationRemoved, true, oldOp.name, "", "operation removed")
+		case !inBase:
+			d.add(SpecChangeOperationAdded, false, newOp.name, "", "operation added")
+		default:
+			d.diffOperation(newOp.name, oldOp, newOp)
+		}
+	}
+
+	return d.diff
+}
+
+// specOperation is an operation with the path template it is documented under.
+type specOperation struct {
+	name      string
+	template  string
+	operation OpenAPIOperation
+}
+
+// indexSpecOperations keys operations by method and path with parameter names removed.
+func indexSpecOperations(spec *OpenAPISpec) map[string]specOperation {
+	operations := make(map[string]specOperation)
+	for template, pathItem := range spec.Paths {
+		segments := strings.Split(template, "/")
+		for i, segment := range segments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				segments[i] = "{}"
+			}
+		}
+		normalized := strings.Join(segments, "/")
+
+		for method, operation := range pathItem {
+			method = strings.ToUpper(method)
+			operations[method+" "+normalized] = specOperation{
+				name:      method + " " + template,
+				template:  template,
+				operation: operation,
+			}
+		}
+	}
+	return operations
+}
+
+// schemaDirection tells whether a schema describes data clients send or data they receive.
+type schemaDirection int
+
+const (
+	requestSchema schemaDirection = iota
+	responseSchema
+)
+
+// specDiffer accumulates the changes between two specifications.
+type specDiffer struct {
+	base     *schemaValidator
+	revision *schemaValidator
+	diff     *SpecDiff
+	visiting map[string]bool
+}
+
+func (d *specDiffer) add(kind SpecChangeKind, breaking bool, operation, location, format string, args ...interface{}) {
+	d.diff.Changes = append(d.diff.Changes, SpecChange{
+		Kind:      kind,
+		Breaking:  breaking,
+		Operation: operation,
+		Location:  location,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+func (d *specDiffer) diffOperation(name string, oldOp, newOp specOperation) {
+	if newOp.operation.Deprecated && !oldOp.operation.Deprecated {
+		d.add(SpecChangeOperationDeprecated, false, name, "", "operation deprecated")
+	}
+
+	d.diffParameters(name, oldOp, newOp)
+	d.diffRequestBody(name, oldOp.operation.RequestBody, newOp.operation.RequestBody)
+	d.diffResponses(name, oldOp.operation.Responses, newOp.operation.Responses)
+}
+
+// parameterKey identifies a parameter. Path parameters are matched by position so renaming them is not a change.
+func parameterKey(parameter OpenAPIParameter, template string) string {
+	switch parameter.In {
+	case "path":
+		for i, segment := range strings.Split(template, "/") {
+			if segment == "{"+parameter.Name+"}" {
+				return "path#" + strconv.Itoa(i)
+			}
+		}
+	case "header":
+		return "header:" + strings.ToLower(parameter.Name)
+	}
+	return parameter.In + ":" + parameter.Name
+}
+
+func (d *specDiffer) diffParameters(name string, oldOp, newOp specOperation) {
+	oldParams := make(map[string]OpenAPIParameter)
+	for _, parameter := range oldOp.operation.Parameters {
+		oldParams[parameterKey(parameter, oldOp.template)] = parameter
+	}
+
+	seen := make(map[string]bool)
+	for _, parameter := range newOp.operation.Parameters {
+		key := parameterKey(parameter, newOp.template)
+		seen[key] = true
+		location := fmt.Sprintf("%s parameter %q", parameter.In, parameter.Name)
+
+		old, ok := oldParams[key]
+		if !ok {
+			if parameter.Required {
+				d.add(SpecChangeParameterAdded, true, name, location, "required parameter added")
+			} else {
+				d.add(SpecChangeParameterAdded, false, name, location, "optional parameter added")
+			}
+			continue
+		}
+
+		if parameter.Required != old.Required {
+			d.add(SpecChangeRequiredChanged, parameter.Required, name, location, "%s", requiredChangeMessage(parameter.Required))
+		}
+		d.diffSchema(name, location, "", old.Schema, parameter.Schema, requestSchema)
+	}
+
+	for _, parameter := range oldOp.operation.Parameters {
+		if !seen[parameterKey(parameter, oldOp.template)] {
+			d.add(SpecChangeParameterRemoved, true, name, fmt.Sprintf("%s parameter %q", parameter.In, parameter.Name), "parameter removed")
+		}
+	}
+}
+
+func (d *specDiffer) diffRequestBody(name string, oldBody, newBody *OpenAPIRequestBody) {
+	const location = "request body"
+
+	switch {
+	case oldBody == nil && newBody == nil:
+		return
+	case oldBody == nil:
+		d.add(SpecChangeRequestBodyAdded, newBody.Required, name, location, "request body added")
+		return
+	case newBody == nil:
+		d.add(SpecChangeRequestBodyRemoved, true, name, location, "request body removed")
+		return
+	}
+
+	if newBody.Required != oldBody.Required {
+		d.add(SpecChangeRequiredChanged, newBody.Required, name, location, "%s", requiredChangeMessage(newBody.Required))
+	}
+	d.diffContent(name, location, oldBody.Content, newBody.Content, requestSchema)
+}
+
+func (d *specDiffer) diffResponses(name string, oldResponses, newResponses map[string]OpenAPIResponse) {
+	for _, status := range unionKeys(oldResponses, newResponses) {
+		location := "response " + status
+		oldResponse, inBase := oldResponses[status]
+		newResponse, inRevision := newResponses[status]
+
+		switch {
+		case !inRevision:
+			d.add(SpecChangeResponseRemoved, true, name, location, "response removed")
+		case !inBase:
+			d.add(SpecChangeResponseAdded, false, name, location, "response added")
+		default:
+			d.diffContent(name, location, oldResponse.Content, newResponse.Content, responseSchema)
+		}
+	}
+}
+
+func (d *specDiffer) diffContent(name, location string, oldContent, newContent map[string]OpenAPIMediaType, direction schemaDirection) {
+	for _, mediaType := range unionKeys(oldContent, newContent) {
+		mediaLocation := location + " (" + mediaType + ")"
+		oldMedia, inBase := oldContent[mediaType]
+		newMedia, inRevision := newContent[mediaType]
+
+		switch {
+		case !inRevision:
+			d.add(SpecChangeMediaTypeRemoved, true, name, mediaLocation, "media type removed")
+		case !inBase:
+			d.add(SpecChangeMediaTypeAdded, false, name, mediaLocation, "media type added")
+		default:
+			d.diffSchema(name, mediaLocation, "", oldMedia.Schema, newMedia.Schema, direction)
+		}
+	}
+}
+
+// diffSchema compares two schemas. Narrowing breaks requests and widening breaks responses.
+func (d *specDiffer) diffSchema(name, location, pointer string, oldSchema, newSchema OpenAPISchema, direction schemaDirection) {
+	if oldSchema.Ref != "" && newSchema.Ref != "" {
+		key := fmt.Sprintf("%s|%s|%d", oldSchema.Ref, newSchema.Ref, direction)
+		if d.visiting[key] {
+			return
+		}
+		if d.visiting == nil {
+			d.visiting = make(map[string]bool)
+		}
+		d.visiting[key] = true
+		defer delete(d.visiting, key)
+	}
+
+	oldSchema = flattenSchema(d.base, oldSchema)
+	newSchema = flattenSchema(d.revision, newSchema)
+
+	at := location
+	if pointer != "" {
+		at = location + " " + pointer
+	}
+	narrowing := direction == requestSchema
+
+	oldTypes, newTypes := schemaTypes(oldSchema.Type), schemaTypes(newSchema.Type)
+	removed, added := typeDifference(oldTypes, newTypes), typeDifference(newTypes, oldTypes)
+	switch {
+	case len(oldTypes) == 0 && len(newTypes) > 0:
+		d.add(SpecChangeTypeChanged, narrowing, name, at, "type narrowed to %s", strings.Join(newTypes, ", "))
+	case len(newTypes) == 0 && len(oldTypes) > 0:
+		d.add(SpecChangeTypeChanged, !narrowing, name, at, "type widened from %s to any", strings.Join(oldTypes, ", "))
+	case len(removed) > 0 && len(added) > 0:
+		d.add(SpecChangeTypeChanged, true, name, at, "type changed from %s to %s", strings.Join(oldTypes, ", "), strings.Join(newTypes, ", "))
+		return
+	case len(removed) > 0:
+		d.add(SpecChangeTypeChanged, narrowing, name, at, "type narrowed from %s to %s", strings.Join(oldTypes, ", "), strings.Join(newTypes, ", "))
+	case len(added) > 0:
+		d.add(SpecChangeTypeChanged, !narrowing, name, at, "type widened from %s to %s", strings.Join(oldTypes, ", "), strings.Join(newTypes, ", "))
+	}
+
+	sameTypes := len(removed) == 0 && len(added) == 0
+	if sameTypes && oldSchema.Format != newSchema.Format && oldSchema.Format != "" && newSchema.Format != "" {
+		d.add(SpecChangeFormatChanged, true, name, at, "format changed from %s to %s", oldSchema.Format, newSchema.Format)
+	}
+
+	d.diffEnum(name, at, oldSchema.Enum, newSchema.Enum, direction)
+	d.diffProperties(name, location, pointer, oldSchema, newSchema, direction)
+
+	oldItems, oldHasItems := schemaFromValue(oldSchema.Items)
+	newItems, newHasItems := schemaFromValue(newSchema.Items)
+	if oldHasItems && newHasItems {
+		d.diffSchema(name, location, pointer+"/*", oldItems, newItems, direction)
+	}
+
+	oldAdditional, oldHasAdditional := schemaFromValue(oldSchema.AdditionalProperties)
+	newAdditional, newHasAdditional := schemaFromValue(newSchema.AdditionalProperties)
+	if oldHasAdditional && newHasAdditional {
+		d.diffSchema(name, location, pointer+"/*", oldAdditional, newAdditional, direction)
+	}
+}
+
+func (d *specDiffer) diffEnum(name, at string, oldEnum, newEnum []interface{}, direction schemaDirection) {
+	narrowing := direction == requestSchema
+
+	switch {
+	case len(oldEnum) == 0 && len(newEnum) == 0:
+		return
+	case len(oldEnum) == 0:
+		d.add(SpecChangeEnumChanged, narrowing, name, at, "values restricted to %v", newEnum)
+		return
+	case len(newEnum) == 0:
+		d.add(SpecChangeEnumChanged, !narrowing, name, at, "enum restriction removed")
+		return
+	}
+
+	if removed := enumDifference(oldEnum, newEnum); len(removed) > 0 {
+		d.add(SpecChangeEnumChanged, narrowing, name, at, "enum values removed: %v", removed)
+	}
+	if added := enumDifference(newEnum, oldEnum); len(added) > 0 {
+		d.add(SpecChangeEnumChanged, !narrowing, name, at, "enum values added: %v", added)
+	}
+}
+
+func (d *specDiffer) diffProperties(name, location, pointer string, oldSchema, newSchema OpenAPISchema, direction schemaDirection) {
+	for _, property := range unionKeys(oldSchema.Properties, newSchema.Properties) {
+		propertyPointer := pointer + "/" + escapeJSONPointer(property)
+		at := location + " " + propertyPointer
+		oldProperty, inBase := oldSchema.Properties[property]
+		newProperty, inRevision := newSchema.Properties[property]
+		wasRequired := containsString(oldSchema.Required, property)
+		isRequired := containsString(newSchema.Required, property)
+
+		switch {
+		case !inRevision:
+			d.add(SpecChangePropertyRemoved, true, name, at, "property removed")
+			continue
+		case !inBase:
+			if direction == requestSchema && isRequired {
+				d.add(SpecChangePropertyAdded, true, name, at, "required property added")
+			} else {
+				d.add(SpecChangePropertyAdded, false, name, at, "property added")
+			}
+			continue
+		}
+
+		if wasRequired != isRequired {
+			// Requests break when a property becomes required, responses when it may now be missing.
+			breaking := isRequired == (direction == requestSchema)
+			d.add(SpecChangeRequiredChanged, breaking, name, at, "%s", requiredChangeMessage(isRequired))
+		}
+		d.diffSchema(name, location, propertyPointer, oldProperty, newProperty, direction)
+	}
+}
+
+// flattenSchema resolves references and merges the properties and required lists of allOf members.
+func flattenSchema(v *schemaValidator, schema OpenAPISchema) OpenAPISchema {
+	schema, _ = v.resolve(schema)
+	if len(schema.AllOf) == 0 {
+		return schema
+	}
+
+	properties := make(map[string]OpenAPISchema)
+	for property, propertySchema := range schema.Properties {
+		properties[property] = propertySchema
+	}
+	required := append([]string(nil), schema.Required...)
+
+	for _, member := range schema.AllOf {
+		member = flattenSchema(v, member)
+		for property, propertySchema := range member.Properties {
+			properties[property] = propertySchema
+		}
+		required = append(required, member.Required...)
+		if schema.Type == nil {
+			schema.Type = member.Type
+		}
+	}
+
+	schema.Properties = properties
+	schema.Required = required
+	schema.AllOf = nil
+	return schema
+}
+
+// schemaFromValue returns the schema held by the items or additionalProperties keyword, if any.
+func schemaFromValue(value interface{}) (OpenAPISchema, bool) {
+	switch schema := value.(type) {
+	case OpenAPISchema:
+		return schema, true
+	case *OpenAPISchema:
+		if schema != nil {
+			return *schema, true
+		}
+	}
+	return OpenAPISchema{}, false
+}
+
+// typeDifference returns the types in from that to does not accept. Number accepts integer.
+func typeDifference(from, to []string) []string {
+	var difference []string
+	for _, t := range from {
+		if containsString(to, t) || (t == "integer" && containsString(to, "number")) {
+			continue
+		}
+		difference = append(difference, t)
+	}
+	return difference
+}
+
+func enumDifference(from, to []interface{}) []interface{} {
+	var difference []interface{}
+	for _, value := range from {
+		if !valueInEnum(value, to) {
+			difference = append(difference, value)
+		}
+	}
+	return difference
+}
+
+func requiredChangeMessage(required bool) string {
+	if required {
+		return "became required"
+	}
+	return "became optional"
+}
+
+// unionKeys returns the sorted keys of both maps.
+func unionKeys[V any](a, b map[string]V) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,438 @@
+package steel
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+)
+
+// SpecFormat selects the serialization of a specification document.
+type SpecFormat string
+
+// Supported specification formats.
+const (
+	SpecFormatJSON SpecFormat = "json"
+	SpecFormatYAML SpecFormat = "yaml"
+)
+
+// Default mount paths of the specification documents.
+const (
+	DefaultOpenAPIJSONPath  = "/openapi.json"
+	DefaultOpenAPIYAMLPath  = "/openapi.yaml"
+	DefaultAsyncAPIJSONPath = "/asyncapi"
+	DefaultAsyncAPIYAMLPath = "/asyncapi.yaml"
+)
+
+// ErrUnsupportedSpecFormat is returned when a specification is requested in a format other than JSON or YAML.
+var ErrUnsupportedSpecFormat = errors.New("unsupported specification format")
+
+// SpecPaths configures where EnableOpenAPI and EnableAsyncAPI mount the specification documents.
+// Empty fields keep their default path.
+type SpecPaths struct {
+	OpenAPIJSON  string
+	OpenAPIYAML  string
+	AsyncAPIJSON string
+	AsyncAPIYAML string
+}
+
+// specKind identifies which specification a document holds.
+type specKind int
+
+const (
+	specOpenAPI specKind = iota
+	specAsyncAPI
+)
+
+// specDocument is a serialized specification. Its bytes never change once built,
+// so it can be served concurrently without locking.
+type specDocument struct {
+	bodies   map[SpecFormat][]byte
+	etags    map[SpecFormat]string
+	modified time.Time
+}
+
+// specState guards the specification structs and caches the documents serialized from them.
+// Every change to the specifications goes through updateSpec, which discards the cached documents.
+type specState struct {
+	mu        sync.Mutex
+	documents map[specKind]*specDocument
+}
+
+// SetSpecPaths sets the paths the specification documents are mounted on.
+// It must be called before EnableOpenAPI and EnableAsyncAPI.
+func (r *SteelRouter) SetSpecPaths(paths SpecPaths) {
+	r.options.SpecPaths = paths
+}
+
+// specPaths returns the configured mount paths with defaults filled in.
+func (r *SteelRouter) specPaths() SpecPaths {
+	paths := r.options.SpecPaths
+	if paths.OpenAPIJSON == "" {
+		paths.OpenAPIJSON = DefaultOpenAPIJSONPath
+	}
+	if paths.OpenAPIYAML == "" {
+		paths.OpenAPIYAML = DefaultOpenAPIYAMLPath
+	}
+	if paths.AsyncAPIJSON == "" {
+		paths.AsyncAPIJSON = DefaultAsyncAPIJSONPath
+	}
+	if paths.AsyncAPIYAML == "" {
+		paths.AsyncAPIYAML = DefaultAsyncAPIYAMLPath
+	}
+	return paths
+}
+
+// WriteSpec writes the OpenAPI specification to w in the given format, for example to export it at build time.
+func (r *SteelRouter) WriteSpec(w io.Writer, format SpecFormat) error {
+	return r.writeSpecDocument(w, specOpenAPI, format)
+}
+
+// WriteAsyncAPISpec writes the AsyncAPI specification to w in the given format.
+func (r *SteelRouter) WriteAsyncAPISpec(w io.Writer, format SpecFormat) error {
+	return r.writeSpecDocument(w, specAsyncAPI, format)
+}
+
+func (r *SteelRouter) writeSpecDocument(w io.Writer, kind specKind, format SpecFormat) error {
+	doc, err := r.specDocument(kind)
+	if err != nil {
+		return err
+	}
+
+	body, ok := doc.bodies[format]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedSpecFormat, format)
+	}
+	_, err = w.Write(body)
+	return err
+}
+
+// specDocument returns the document of a specification, building it on first use after a change.
+func (r *SteelRouter) specDocument(kind specKind) (*specDocument, error) {
+	r.specs.mu.Lock()
+	defer r.specs.mu.Unlock()
+
+	if doc, ok := r.specs.documents[kind]; ok {
+		return doc, nil
+	}
+
+	doc, err := r.buildSpecDocument(kind)
+	if err != nil {
+		return nil, err
+	}
+	if r.specs.documents == nil {
+		r.specs.documents = make(map[specKind]*specDocument)
+	}
+	r.specs.documents[kind] = doc
+	return doc, nil
+}
+
+// updateSpec applies a change to the specifications while holding the spec lock so documents
+// are never built from a half applied change, and discards the documents built before it.
+func (r *SteelRouter) updateSpec(update func()) {
+	r.specs.mu.Lock()
+	defer r.specs.mu.Unlock()
+
+	update()
+	r.specs.documents = nil
+}
+
+// readSpec runs read while holding the spec lock.
+func (r *SteelRouter) readSpec(read func()) {
+	r.specs.mu.Lock()
+	defer r.specs.mu.Unlock()
+
+	read()
+}
+
+// copySpec returns a deep copy of a specification, which callers can read and change while routes
+// are still being registered. Callers must hold the spec lock.
+func copySpec[T any](spec *T) *T {
+	return copyValue(reflect.ValueOf(spec)).Interface().(*T)
+}
+
+// copyValue deep copies maps, slices, pointers, interfaces and the exported fields of structs.
+func copyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(copyValue(v.Elem()))
+		return c
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(copyValue(v.Elem()))
+		return c
+
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), copyValue(iter.Value()))
+		}
+		return c
+
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(copyValue(v.Index(i)))
+		}
+		return c
+
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(copyValue(v.Field(i)))
+			}
+		}
+		return c
+	}
+	return v
+}
+
+// buildSpecDocument serializes a specification in every supported format. Callers must hold the spec lock.
+func (r *SteelRouter) buildSpecDocument(kind specKind) (*specDocument, error) {
+	var spec interface{} = r.openAPISpec
+	if kind == specAsyncAPI {
+		spec = r.asyncAPIDocument()
+	}
+
+	// Sorted map keys keep the document, and so its ETag, identical across rebuilds
+	data, err := json.ConfigCompatibleWithStandardLibrary.MarshalIndent(spec, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal specification: %w", err)
+	}
+
+	yamlData, err := jsonToYAML(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert specification to YAML: %w", err)
+	}
+
+	doc := &specDocument{
+		bodies: map[SpecFormat][]byte{
+			SpecFormatJSON: data,
+			SpecFormatYAML: yamlData,
+		},
+		etags:    make(map[SpecFormat]string),
+		modified: time.Now().UTC().Truncate(time.Second),
+	}
+	for format, body := range doc.bodies {
+		sum := sha256.Sum256(body)
+		doc.etags[format] = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	return doc, nil
+}
+
+// jsonToYAML converts a JSON document to block-style YAML, keeping the key order of the JSON input.
+func jsonToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	resetYAMLStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resetYAMLStyle clears the flow and quoting styles taken from the JSON input, letting the encoder
+// emit block style and quote only the strings that would otherwise be read as another type.
+func resetYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetYAMLStyle(child)
+	}
+}
+
+// specHandler serves a specification document, which is rebuilt on the first request after a change.
+// Responses carry ETag and Last-Modified headers and conditional requests are answered with 304.
+func (r *SteelRouter) specHandler(kind specKind, format SpecFormat) HandlerFunc {
+	contentType := "application/json"
+	if format == SpecFormatYAML {
+		contentType = "application/yaml"
+	}
+
+	return func(w http.ResponseWriter, req *http.Request) {
+		doc, err := r.specDocument(kind)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("ETag", doc.etags[format])
+		if kind == specAsyncAPI {
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		}
+		http.ServeContent(w, req, "", doc.modified, bytes.NewReader(doc.bodies[format]))
+	}
+}
+
+// withSpecURL points a documentation page at the configured spec path instead of the default one.
+func withSpecURL(html, defaultPath, path string) []byte {
+	if path == defaultPath {
+		return []byte(html)
+	}
+	for _, quote := range []string{`"`, `'`} {
+		html = strings.ReplaceAll(html, quote+defaultPath+quote, quote+path+quote)
+	}
+	return []byte(html)
+}
+
+// LoadOpenAPISpec parses an OpenAPI document in JSON or YAML, such as one exported with WriteSpec,
+// so it can be compared with the router's specification using DiffOpenAPISpecs.
+func LoadOpenAPISpec(data []byte) (*OpenAPISpec, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) == 0 || trimmed[0] != '{' {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse specification: %w", err)
+		}
+		converted, err := json.Marshal(yamlToJSONValue(doc))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse specification: %w", err)
+		}
+		data = converted
+	}
+
+	var spec OpenAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse specification: %w", err)
+	}
+
+	for name, schema := range spec.Components.Schemas {
+		spec.Components.Schemas[name] = normalizeSchema(schema)
+	}
+	for _, pathItems := range []map[string]OpenAPIPath{spec.Paths, spec.Webhooks} {
+		for _, pathItem := range pathItems {
+			for method, operation := range pathItem {
+				pathItem[method] = normalizeOperation(operation)
+			}
+		}
+	}
+
+	return &spec, nil
+}
+
+// yamlToJSONValue converts maps with non-string keys, such as unquoted status codes, to JSON objects.
+func yamlToJSONValue(value interface{}) interface{} {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for key, item := range typed {
+			typed[key] = yamlToJSONValue(item)
+		}
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(typed))
+		for key, item := range typed {
+			converted[fmt.Sprint(key)] = yamlToJSONValue(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range typed {
+			typed[i] = yamlToJSONValue(item)
+		}
+	}
+	return value
+}
+
+func normalizeOperation(operation OpenAPIOperation) OpenAPIOperation {
+	for i, parameter := range operation.Parameters {
+		operation.Parameters[i].Schema = normalizeSchema(parameter.Schema)
+	}
+	if operation.RequestBody != nil {
+		normalizeContent(operation.RequestBody.Content)
+	}
+	for _, response := range operation.Responses {
+		normalizeContent(response.Content)
+	}
+	return operation
+}
+
+func normalizeContent(content map[string]OpenAPIMediaType) {
+	for mediaType, media := range content {
+		media.Schema = normalizeSchema(media.Schema)
+		content[mediaType] = media
+	}
+}
+
+// normalizeSchema replaces the decoded JSON objects of keywords typed as interface{}, such as items,
+// with OpenAPISchema values so loaded specifications look like generated ones.
+func normalizeSchema(schema OpenAPISchema) OpenAPISchema {
+	schema.Items = normalizeSchemaValue(schema.Items)
+	schema.AdditionalProperties = normalizeSchemaValue(schema.AdditionalProperties)
+	schema.UnevaluatedItems = normalizeSchemaValue(schema.UnevaluatedItems)
+	schema.UnevaluatedProperties = normalizeSchemaValue(schema.UnevaluatedProperties)
+
+	for name, property := range schema.Properties {
+		schema.Properties[name] = normalizeSchema(property)
+	}
+	for _, list := range [][]OpenAPISchema{schema.PrefixItems, schema.AllOf, schema.AnyOf, schema.OneOf} {
+		for i := range list {
+			list[i] = normalizeSchema(list[i])
+		}
+	}
+	for _, sub := range []*OpenAPISchema{schema.Not, schema.Contains, schema.PropertyNames, schema.If, schema.Then, schema.Else} {
+		if sub != nil {
+			*sub = normalizeSchema(*sub)
+		}
+	}
+	return schema
+}
+
+func normalizeSchemaValue(value interface{}) interface{} {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return value
+	}
+
+	data, err := json.Marshal(object)
+	if err != nil {
+		return value
+	}
+	var schema OpenAPISchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return value
+	}
+	return normalizeSchema(schema)
+}
@@ -1,10 +1,14 @@
 package steel
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"reflect"
+	"strings"
 	"sync"
+	"time"
 
 	json "github.com/json-iterator/go"
 )
@@ -21,6 +25,16 @@ type SSEConnection struct {
 	metadata map[string]interface{}
 	mu       sync.RWMutex
 	closed   bool
+	sent     int // events written, counted on the stream span
+
+	// Lifecycle state, cancelled on client disconnect or Close
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// Replay state
+	stream   string
+	history  SSEHistoryStore
+	replayed map[string]struct{}
 }
 
 // SSEMessage represents a single message sent over a Server-Sent Events (SSE) connection.
@@ -31,6 +45,12 @@ type SSEMessage struct {
 	Retry int         `json:"retry,omitempty"`
 }
 
+// ErrSSEStreamingUnsupported is returned when the response writer cannot flush events to the client.
+var ErrSSEStreamingUnsupported = errors.New("sse: response writer does not support flushing")
+
+// DefaultSSEHeartbeatInterval is the interval between ": ping" comments on SSE streams.
+const DefaultSSEHeartbeatInterval = 15 * time.Second
+
 // SSEHandler defines a function type for handling SSE connections with typed parameters.
 // Takes an SSEConnection and a parameter of any type, and returns an error.
 type SSEHandler[TParams any] func(conn *SSEConnection, params TParams) error
@@ -43,15 +63,72 @@ type SSEHandlerInfo struct {
 	Summary     string
 	Description string
 	Tags        []string
+
+	// Events lists the typed events the endpoint emits, each documented as its own message.
+	Events []SSEEventInfo
+
+	// History stores published events so clients reconnecting with Last-Event-ID are replayed missed events.
+	History SSEHistoryStore
+
+	// StreamKey selects the history stream for a request. Defaults to the request path.
+	StreamKey func(r *http.Request) string
+
+	// SecurityRequirements documents the authentication required to connect.
+	SecurityRequirements []OpenAPISecurityRequirement
+
+	// OperationID identifies the endpoint's operation in the AsyncAPI specification.
+	OperationID string
+
+	// Deprecated marks the endpoint's operation as deprecated.
+	Deprecated bool
+
+	// Examples are documented on the streamed message. With typed events, each example is
+	// attached to the event of the same name.
+	Examples []AsyncAPIExample
+
+	// HeadersType is the struct documenting the headers of the streamed messages.
+	HeadersType reflect.Type
+
+	// HeartbeatInterval is the interval between ": ping" comments keeping idle streams open.
+	// Zero uses DefaultSSEHeartbeatInterval and a negative value disables heartbeats.
+	HeartbeatInterval time.Duration
 }
 
 // SendMessage sends a Server-Sent Event (SSE) message to the client through the current connection.
 // It includes optional fields such as ID, event name, retry interval, and data payload.
+// When the endpoint has a history store the message is recorded on the connection's stream and
+// receives an auto-incrementing ID if it has none.
 // Returns an error if the connection is closed or data serialization fails.
 func (sse *SSEConnection) SendMessage(message SSEMessage) error {
 	sse.mu.Lock()
 	defer sse.mu.Unlock()
 
+	if sse.history != nil {
+		stored, err := sse.history.Append(sse.stream, message)
+		if err != nil {
+			return err
+		}
+		message = stored
+	}
+
+	return sse.writeMessage(message)
+}
+
+// deliver writes a message that has already been recorded in the history store, skipping events
+// the client already received during replay.
+func (sse *SSEConnection) deliver(message SSEMessage) error {
+	sse.mu.Lock()
+	defer sse.mu.Unlock()
+
+	if sse.history != nil && sse.alreadyDelivered(message.ID) {
+		return nil
+	}
+
+	return sse.writeMessage(message)
+}
+
+// writeMessage encodes a message in the event stream format. It must be called with the connection lock held.
+func (sse *SSEConnection) writeMessage(message SSEMessage) error {
 	if sse.writer == nil {
 		return fmt.Errorf("http writer is nil")
 	}
@@ -70,25 +147,124 @@ func (sse *SSEConnection) SendMessage(message SSEMessage) error {
 		fmt.Fprintf(sse.writer, "retry: %d\n", message.Retry)
 	}
 
-	data, err := json.Marshal(message.Data)
+	data, err := encodeSSEData(message.Data)
 	if err != nil {
 		return err
 	}
 
-	fmt.Fprintf(sse.writer, "data: %s\n\n", string(data))
+	// Each line of the payload needs its own data field; clients join them back with newlines
+	for _, line := range data {
+		fmt.Fprintf(sse.writer, "data: %s\n", line)
+	}
+	fmt.Fprint(sse.writer, "\n")
+
+	// The span records the first MaxSpanEvents events and counts them all
+	sse.sent++
+	if span := SpanFromContext(sse.Context()); span != nil {
+		span.AddEvent("sse.event", map[string]interface{}{"sse.event": message.Event, "sse.id": message.ID})
+		span.SetAttribute("sse.events_sent", sse.sent)
+	}
+
+	return sse.flush()
+}
 
-	if flusher, ok := sse.writer.(http.Flusher); ok {
-		flusher.Flush()
+// encodeSSEData returns the lines of an event payload. Strings are sent as is, split on any line ending,
+// and other values are JSON encoded.
+func encodeSSEData(data interface{}) ([]string, error) {
+	var text string
+	switch val := data.(type) {
+	case string:
+		text = val
+	case []byte:
+		text = string(val)
+	default:
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+		text = string(encoded)
 	}
 
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return strings.Split(text, "\n"), nil
+}
+
+// flush pushes buffered output to the client. It must be called with the connection lock held.
+func (sse *SSEConnection) flush() error {
+	if err := http.NewResponseController(sse.writer).Flush(); err != nil {
+		if errors.Is(err, http.ErrNotSupported) {
+			return ErrSSEStreamingUnsupported
+		}
+		return err
+	}
 	return nil
 }
 
-// Close gracefully shuts down the SSE connection by marking it as closed and ensuring thread-safety with a mutex lock.
-func (sse *SSEConnection) Close() {
+// Ping writes a ": ping" comment line, which clients ignore but keeps proxies from closing idle streams.
+func (sse *SSEConnection) Ping() error {
 	sse.mu.Lock()
 	defer sse.mu.Unlock()
+
+	if sse.writer == nil {
+		return fmt.Errorf("http writer is nil")
+	}
+
+	if sse.closed {
+		return fmt.Errorf("connection closed")
+	}
+
+	fmt.Fprint(sse.writer, ": ping\n\n")
+	return sse.flush()
+}
+
+// heartbeat sends ping comments at the given interval until the connection is done.
+func (sse *SSEConnection) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sse.Done():
+			return
+		case <-ticker.C:
+			if err := sse.Ping(); err != nil {
+				sse.Close()
+				return
+			}
+		}
+	}
+}
+
+// Context returns a context that is cancelled when the client disconnects or the connection is closed.
+func (sse *SSEConnection) Context() context.Context {
+	if sse.ctx != nil {
+		return sse.ctx
+	}
+	if sse.request != nil {
+		return sse.request.Context()
+	}
+	return context.Background()
+}
+
+// Done returns a channel that is closed when the client disconnects or the connection is closed.
+func (sse *SSEConnection) Done() <-chan struct{} {
+	return sse.Context().Done()
+}
+
+// Close marks the SSE connection as closed, cancels its context so the handler can return,
+// and removes it from the router's connection manager.
+func (sse *SSEConnection) Close() {
+	sse.mu.Lock()
 	sse.closed = true
+	sse.mu.Unlock()
+
+	if sse.cancel != nil {
+		sse.cancel()
+	}
+	if sse.router != nil && sse.router.connectionManager != nil {
+		sse.router.connectionManager.RemoveSSEConnection(sse.clientID)
+	}
 }
 
 // IsClosed checks whether the SSE connection has been closed. Returns true if the connection is closed, otherwise false.
@@ -127,3 +303,22 @@ func (sse *SSEConnection) GetMetadata(key string) (interface{}, bool) {
 func (sse *SSEConnection) Request() *http.Request {
 	return sse.request
 }
+
+// Stream returns the history stream the connection is subscribed to.
+func (sse *SSEConnection) Stream() string {
+	return sse.stream
+}
+
+// canFlush reports whether w, or a writer it wraps, can flush streamed output.
+func canFlush(w http.ResponseWriter) bool {
+	for {
+		switch rw := w.(type) {
+		case http.Flusher:
+			return true
+		case interface{ Unwrap() http.ResponseWriter }:
+			w = rw.Unwrap()
+		default:
+			return false
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package steel
+
+import (
+	"reflect"
+)
+
+// SSEEventInfo describes an event type declared on an SSE endpoint.
+type SSEEventInfo struct {
+	Name     string
+	DataType reflect.Type
+}
+
+// SSEEvent is a typed event declaration. It documents the event on SSE endpoints it is passed to
+// and sends data of type T with the event name, so payloads are checked at compile time.
+//
+//	var PriceUpdate = steel.NewSSEEvent[Price]("price.update")
+//
+//	router.SSE("/prices", func(conn *steel.SSEConnection, params struct{}) error {
+//		return PriceUpdate.Send(conn, Price{Symbol: "ACME", Value: 42})
+//	}, PriceUpdate)
+type SSEEvent[T any] struct {
+	Name string
+}
+
+// NewSSEEvent declares a typed SSE event with the given event name.
+func NewSSEEvent[T any](name string) SSEEvent[T] {
+	return SSEEvent[T]{Name: name}
+}
+
+// WithSSEEvent declares that an SSE endpoint emits events of the given name carrying data of type T.
+// The returned value can also be used to send the event.
+func WithSSEEvent[T any](name string) SSEEvent[T] {
+	return NewSSEEvent[T](name)
+}
+
+// ApplyToWS is a no-op since SSE events do not apply to WebSocket endpoints.
+func (e SSEEvent[T]) ApplyToWS(info *WSHandlerInfo) {}
+
+// ApplyToSSE declares the event on the endpoint so it is documented in the AsyncAPI specification.
+func (e SSEEvent[T]) ApplyToSSE(info *SSEHandlerInfo) {
+	for _, event := range info.Events {
+		if event.Name == e.Name {
+			return
+		}
+	}
+	info.Events = append(info.Events, SSEEventInfo{
+		Name:     e.Name,
+		DataType: reflect.TypeOf((*T)(nil)).Elem(),
+	})
+}
+
+// Send sends the event with data to a single connection.
+func (e SSEEvent[T]) Send(conn *SSEConnection, data T) error {
+	return conn.SendMessage(SSEMessage{Event: e.Name, Data: data})
+}
+
+// Publish records the event on a stream and delivers it to every connection subscribed to that stream.
+func (e SSEEvent[T]) Publish(router *SteelRouter, stream string, data T) error {
+	return router.PublishSSE(stream, SSEMessage{Event: e.Name, Data: data})
+}
+
+// SendSSEEvent sends data of type T as the named event to a single connection.
+func SendSSEEvent[T any](conn *SSEConnection, event string, data T) error {
+	return NewSSEEvent[T](event).Send(conn, data)
+}
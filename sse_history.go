@@ -0,0 +1,250 @@
+package steel
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SSEHistoryStore keeps recent events per stream so reconnecting clients can be replayed what they missed.
+// Implementations must be safe for concurrent use.
+type SSEHistoryStore interface {
+	// Append records a message on a stream. If the message has no ID the store assigns the next
+	// auto-incrementing ID for that stream. The stored message is returned.
+	Append(stream string, message SSEMessage) (SSEMessage, error)
+
+	// Since returns the retained messages of a stream published after the message with lastEventID, oldest first.
+	Since(stream string, lastEventID string) ([]SSEMessage, error)
+}
+
+// SSEHistoryOptions configures retention for an SSERingBuffer.
+type SSEHistoryOptions struct {
+	// MaxEvents is the number of events kept per stream. Defaults to 1000.
+	MaxEvents int
+
+	// MaxAge drops events older than this duration. Zero keeps events until MaxEvents is exceeded.
+	MaxAge time.Duration
+}
+
+// SSERingBuffer is an in-memory SSEHistoryStore holding a bounded ring of events per stream.
+type SSERingBuffer struct {
+	options SSEHistoryOptions
+	streams map[string]*sseStreamHistory
+	mu      sync.Mutex
+	now     func() time.Time
+}
+
+// sseStreamHistory is the ring of retained events for one stream.
+type sseStreamHistory struct {
+	seq     uint64
+	entries []sseHistoryEntry
+}
+
+// sseHistoryEntry is a retained event together with its sequence number and publish time.
+type sseHistoryEntry struct {
+	seq     uint64
+	at      time.Time
+	message SSEMessage
+}
+
+// NewSSERingBuffer creates an in-memory history store with the given retention options.
+func NewSSERingBuffer(options SSEHistoryOptions) *SSERingBuffer {
+	if options.MaxEvents <= 0 {
+		options.MaxEvents = 1000
+	}
+
+	return &SSERingBuffer{
+		options: options,
+		streams: make(map[string]*sseStreamHistory),
+		now:     time.Now,
+	}
+}
+
+// Append records a message on the stream, assigning the next sequence number as its ID when it has none.
+func (b *SSERingBuffer) Append(stream string, message SSEMessage) (SSEMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history, ok := b.streams[stream]
+	if !ok {
+		history = &sseStreamHistory{}
+		b.streams[stream] = history
+	}
+
+	history.seq++
+	if message.ID == "" {
+		message.ID = strconv.FormatUint(history.seq, 10)
+	}
+
+	history.entries = append(history.entries, sseHistoryEntry{
+		seq:     history.seq,
+		at:      b.now(),
+		message: message,
+	})
+	b.prune(history)
+
+	return message, nil
+}
+
+// Since returns the retained messages after the message whose ID is lastEventID. IDs are matched as
+// opaque strings; if the referenced event is no longer retained, every retained event is replayed
+// since the client has missed at least those.
+func (b *SSERingBuffer) Since(stream string, lastEventID string) ([]SSEMessage, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history, ok := b.streams[stream]
+	if !ok {
+		return nil, nil
+	}
+	b.prune(history)
+
+	start := 0
+	for i := len(history.entries) - 1; i >= 0; i-- {
+		if history.entries[i].message.ID == lastEventID {
+			start = i + 1
+			break
+		}
+	}
+
+	messages := make([]SSEMessage, 0, len(history.entries)-start)
+	for _, entry := range history.entries[start:] {
+		messages = append(messages, entry.message)
+	}
+
+	return messages, nil
+}
+
+// Len returns the number of events currently retained for a stream.
+func (b *SSERingBuffer) Len(stream string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	history, ok := b.streams[stream]
+	if !ok {
+		return 0
+	}
+	b.prune(history)
+	return len(history.entries)
+}
+
+// prune applies the count and age retention limits to a stream.
+func (b *SSERingBuffer) prune(history *sseStreamHistory) {
+	drop := 0
+	if excess := len(history.entries) - b.options.MaxEvents; excess > 0 {
+		drop = excess
+	}
+
+	if b.options.MaxAge > 0 {
+		cutoff := b.now().Add(-b.options.MaxAge)
+		for drop < len(history.entries) && history.entries[drop].at.Before(cutoff) {
+			drop++
+		}
+	}
+
+	if drop > 0 {
+		history.entries = append(history.entries[:0], history.entries[drop:]...)
+	}
+}
+
+// lastEventID returns the ID a reconnecting client last received, taken from the Last-Event-ID header
+// or the lastEventId query parameter used by EventSource polyfills.
+func lastEventID(req *http.Request) string {
+	if id := req.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+	return req.URL.Query().Get("lastEventId")
+}
+
+// replayHistory writes the events missed since the client's Last-Event-ID before live delivery starts.
+// It must be called with the connection lock held.
+func (sse *SSEConnection) replayHistory() error {
+	if sse.history == nil {
+		return nil
+	}
+
+	lastID := lastEventID(sse.request)
+	if lastID == "" {
+		return nil
+	}
+	messages, err := sse.history.Since(sse.stream, lastID)
+	if err != nil {
+		return err
+	}
+
+	for _, message := range messages {
+		if err := sse.writeMessage(message); err != nil {
+			return err
+		}
+		sse.trackDelivered(message.ID)
+	}
+	return nil
+}
+
+// trackDelivered records an event ID replayed to the client so the same event published while the
+// replay was in progress is not delivered twice.
+func (sse *SSEConnection) trackDelivered(id string) {
+	if id == "" {
+		return
+	}
+	if sse.replayed == nil {
+		sse.replayed = make(map[string]struct{})
+	}
+	sse.replayed[id] = struct{}{}
+}
+
+// alreadyDelivered reports whether a stored event was already replayed to the client.
+// Each replayed ID suppresses at most one live delivery.
+func (sse *SSEConnection) alreadyDelivered(id string) bool {
+	if _, ok := sse.replayed[id]; !ok {
+		return false
+	}
+	delete(sse.replayed, id)
+	return true
+}
+
+// PublishSSE records a message on a stream in every history store registered on the router and
+// delivers it to all SSE connections subscribed to that stream.
+// Connections of endpoints without history receive the message as is.
+func (r *SteelRouter) PublishSSE(stream string, message SSEMessage) error {
+	r.initAsyncAPI()
+
+	stored := make(map[SSEHistoryStore]SSEMessage)
+	for _, info := range r.sseHandlers {
+		if info.History == nil {
+			continue
+		}
+		if _, ok := stored[info.History]; ok {
+			continue
+		}
+		appended, err := info.History.Append(stream, message)
+		if err != nil {
+			return err
+		}
+		stored[info.History] = appended
+	}
+
+	// Snapshot the subscribers so slow clients are written to without holding the manager lock
+	cm := r.connectionManager
+	cm.mu.RLock()
+	var conns []*SSEConnection
+	for _, conn := range cm.sseConnections {
+		if conn.stream == stream {
+			conns = append(conns, conn)
+		}
+	}
+	cm.mu.RUnlock()
+
+	for _, conn := range conns {
+		if conn.history == nil {
+			conn.deliver(message)
+			continue
+		}
+		if appended, ok := stored[conn.history]; ok {
+			conn.deliver(appended)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package steel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// TimeoutConfig configures the response sent when a request times out
+type TimeoutConfig struct {
+	// Status is the status of timed out requests, http.StatusGatewayTimeout (the default)
+	// or http.StatusServiceUnavailable
+	Status int
+	// Message is the message of the error response
+	Message string
+}
+
+// WithTimeout sets the timeout of an operation. The router enforces it whether or not the Timeout
+// middleware is used, and the middleware leaves the operation to it.
+func WithTimeout(timeout time.Duration) HandlerOption {
+	if timeout <= 0 {
+		panic("timeout must be positive")
+	}
+	return func(h *HandlerInfo) {
+		h.Timeout = timeout
+	}
+}
+
+// Timeout cancels the context of requests after the timeout and responds with a 504 error,
+// or the status of config, if the handler has not completed by then. Responses are buffered
+// until the handler returns, and writes made after the timeout are discarded with
+// http.ErrHandlerTimeout. Operations with their own timeout (see WithTimeout) use it instead,
+// and WebSocket and SSE endpoints, which cannot be buffered, are left alone.
+//
+// Panics of the handler are re-raised as a *PanicError with the handler's stack, for Recoverer.
+func Timeout(timeout time.Duration, config ...TimeoutConfig) func(next http.Handler) http.Handler {
+	var cfg TimeoutConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			params := ParamsFromContext(r.Context())
+			if params.kind != endpointHTTP || (params.info != nil && params.info.Timeout > 0) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			serveWithTimeout(params.router, w, r, next, timeout, cfg)
+		})
+	}
+}
+
+// timeoutHandler enforces the timeout of an operation set with WithTimeout
+func (r *SteelRouter) timeoutHandler(handler HandlerFunc, timeout time.Duration) HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		serveWithTimeout(r, w, req, http.HandlerFunc(handler), timeout, TimeoutConfig{})
+	}
+}
+
+// serveWithTimeout runs next on its own goroutine with a buffered writer and sends its response,
+// or an error response from the router when the deadline passes first. A nil router still responds.
+func serveWithTimeout(r *SteelRouter, w http.ResponseWriter, req *http.Request, next http.Handler, timeout time.Duration, config TimeoutConfig) {
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+
+	// The handler may outlive this call, after which the router returns its params to the pool
+	if params, ok := req.Context().Value(paramsKey).(*Params); ok {
+		ctx = context.WithValue(ctx, paramsKey, params.clone())
+	}
+	req = req.WithContext(ctx)
+
+	tw := &timeoutWriter{header: make(http.Header)}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+
+	go func() {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value != http.ErrAbortHandler {
+				value = &PanicError{Value: value, Stack: debug.Stack()}
+			}
+
+			// After the timeout response nobody waits for the panic, so report it here
+			tw.mu.Lock()
+			timedOut := tw.timedOut
+			if !timedOut {
+				panicked <- value
+			}
+			tw.mu.Unlock()
+
+			if timedOut && value != http.ErrAbortHandler {
+				r.reportPanic(req, value)
+			}
+		}()
+		next.ServeHTTP(tw, req)
+		close(done)
+	}()
+
+	select {
+	case value := <-panicked:
+		panic(value)
+
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+
+		header := w.Header()
+		for key, values := range tw.header {
+			header[key] = values
+		}
+		if tw.status == 0 {
+			tw.status = http.StatusOK
+		}
+		w.WriteHeader(tw.status)
+		w.Write(tw.body.Bytes())
+
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		tw.mu.Unlock()
+
+		// A panic raised just before the deadline is reported rather than re-raised
+		select {
+		case value := <-panicked:
+			if value != http.ErrAbortHandler {
+				r.reportPanic(req, value)
+			}
+		default:
+		}
+
+		var err *HTTPError
+		if ctx.Err() == context.DeadlineExceeded {
+			err = timeoutError(config, timeout)
+		} else {
+			// The client went away or the server is shutting down
+			err = ServiceUnavailable("Request canceled")
+		}
+		r.handleError(w, req, err)
+	}
+}
+
+// timeoutError returns the error response of a request that timed out
+func timeoutError(config TimeoutConfig, timeout time.Duration) *HTTPError {
+	message := config.Message
+	if message == "" {
+		message = fmt.Sprintf("Request did not complete within %s", timeout)
+	}
+	if config.Status == http.StatusServiceUnavailable {
+		return ServiceUnavailable(message)
+	}
+	return GatewayTimeout(message)
+}
+
+// timeoutWriter buffers the response of a handler running under a timeout
+type timeoutWriter struct {
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.status != 0 {
+		return
+	}
+	if status < 100 || status > 999 {
+		panic(fmt.Sprintf("invalid WriteHeader code %v", status))
+	}
+	// Informational responses cannot be relayed from the buffer
+	if status >= http.StatusOK {
+		tw.status = status
+	}
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+// addTimeoutDocumentation documents the 504 response of an operation with a timeout
+func (r *SteelRouter) addTimeoutDocumentation(operation *OpenAPIOperation, timeout time.Duration) {
+	if timeout <= 0 {
+		return
+	}
+
+	r.ensureErrorSchemasRegistered()
+	operation.Responses["504"] = OpenAPIResponse{
+		Description: fmt.Sprintf("Gateway Timeout - Request did not complete within %s", timeout),
+		Content: map[string]OpenAPIMediaType{
+			"application/json": {
+				Schema: OpenAPISchema{Ref: "#/components/schemas/ErrorResponse"},
+			},
+		},
+	}
+}
@@ -0,0 +1,410 @@
+package steel
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// W3C trace context headers
+const (
+	TraceparentHeader = "traceparent"
+	TracestateHeader  = "tracestate"
+)
+
+// TraceID identifies a trace across services
+type TraceID [16]byte
+
+// String returns the ID as 32 lowercase hex characters
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+// String returns the ID as 16 lowercase hex characters
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// IsValid reports whether the ID is not all zeros
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+// SpanContext is the part of a span propagated to other services
+type SpanContext struct {
+	TraceID    TraceID
+	SpanID     SpanID
+	Sampled    bool
+	TraceState string
+	// Remote is set when the span context was received from another service
+	Remote bool
+}
+
+// IsValid reports whether the span context has a trace and span ID
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+func ParseTraceparent(value string) (SpanContext, error) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, fmt.Errorf("traceparent %q must have four fields", value)
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if len(version) != 2 || !isLowerHex(version) || version == "ff" {
+		return sc, fmt.Errorf("traceparent version %q is invalid", version)
+	}
+	// Version 00 has exactly four fields, later versions may append more
+	if version == "00" && len(parts) != 4 {
+		return sc, fmt.Errorf("traceparent %q has too many fields for version 00", value)
+	}
+	if len(traceID) != 32 || !isLowerHex(traceID) {
+		return sc, fmt.Errorf("traceparent trace ID %q is invalid", traceID)
+	}
+	if len(spanID) != 16 || !isLowerHex(spanID) {
+		return sc, fmt.Errorf("traceparent parent ID %q is invalid", spanID)
+	}
+	if len(flags) != 2 || !isLowerHex(flags) {
+		return sc, fmt.Errorf("traceparent flags %q are invalid", flags)
+	}
+
+	hex.Decode(sc.TraceID[:], []byte(traceID))
+	hex.Decode(sc.SpanID[:], []byte(spanID))
+	if !sc.IsValid() {
+		return SpanContext{}, errors.New("traceparent IDs must not be all zeros")
+	}
+
+	var flagBits [1]byte
+	hex.Decode(flagBits[:], []byte(flags))
+	sc.Sampled = flagBits[0]&1 == 1
+	sc.Remote = true
+	return sc, nil
+}
+
+func isLowerHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// SpanKind describes the relationship of a span to its parent and children
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = iota
+	SpanKindServer
+	SpanKindClient
+	SpanKindProducer
+	SpanKindConsumer
+)
+
+func (k SpanKind) String() string {
+	switch k {
+	case SpanKindServer:
+		return "server"
+	case SpanKindClient:
+		return "client"
+	case SpanKindProducer:
+		return "producer"
+	case SpanKindConsumer:
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
+
+// SpanStatusCode is the outcome of the operation of a span
+type SpanStatusCode int
+
+const (
+	SpanStatusUnset SpanStatusCode = iota
+	SpanStatusOK
+	SpanStatusError
+)
+
+func (c SpanStatusCode) String() string {
+	switch c {
+	case SpanStatusOK:
+		return "ok"
+	case SpanStatusError:
+		return "error"
+	default:
+		return "unset"
+	}
+}
+
+// SpanEvent is a timestamped annotation of a span
+type SpanEvent struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// Span is a timed operation within a trace. Its methods are safe to call on a nil span, which is
+// what StartSpan returns when tracing is not enabled. Spans are read-only once ended.
+type Span struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	ParentSpanID  SpanID
+	StartTime     time.Time
+	EndTime       time.Time
+	Attributes    map[string]interface{}
+	Events        []SpanEvent
+	DroppedEvents int // events not recorded once the span held MaxSpanEvents
+	StatusCode    SpanStatusCode
+	StatusMessage string
+	ServiceName   string
+
+	tracer *Tracer
+	mu     sync.Mutex
+	ended  bool
+}
+
+// Context returns the span context to propagate, or an invalid one for a nil span
+func (s *Span) Context() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.SpanContext
+}
+
+// Tracer returns the tracer that started the span
+func (s *Span) Tracer() *Tracer {
+	if s == nil {
+		return nil
+	}
+	return s.tracer
+}
+
+// SetName renames the span, such as once the route of a request is known
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.Name = name
+	}
+}
+
+// SetAttribute sets an attribute of the span
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.ended {
+		s.Attributes[key] = value
+	}
+}
+
+// MaxSpanEvents is the number of events a span records, so long-lived spans such as those of
+// event streams stay bounded. Further events are counted in DroppedEvents.
+const MaxSpanEvents = 128
+
+// AddEvent records an event on the span
+func (s *Span) AddEvent(name string, attributes map[string]interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended {
+		return
+	}
+	if len(s.Events) >= MaxSpanEvents {
+		s.DroppedEvents++
+		return
+	}
+	s.Events = append(s.Events, SpanEvent{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+// RecordError records err as an exception event without changing the status of the span
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.AddEvent("exception", map[string]interface{}{
+		"exception.type":    reflect.TypeOf(err).String(),
+		"exception.message": err.Error(),
+	})
+}
+
+// SetStatus sets the outcome of the span. An OK status is final, and an unset status is ignored.
+func (s *Span) SetStatus(code SpanStatusCode, message string) {
+	if s == nil || code == SpanStatusUnset {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ended || s.StatusCode == SpanStatusOK {
+		return
+	}
+	s.StatusCode = code
+	if code == SpanStatusError {
+		s.StatusMessage = message
+	}
+}
+
+// End completes the span and exports it when sampled. Calls after the first are ignored.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+
+	if s.SpanContext.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(s)
+	}
+}
+
+// SpanExporter receives ended spans, for instance to write them out or send them to a collector.
+// ExportSpan is called from the goroutine ending the span and must not block for long.
+type SpanExporter interface {
+	ExportSpan(span *Span)
+}
+
+// Tracer starts spans and hands them to an exporter when they end
+type Tracer struct {
+	serviceName string
+	exporter    SpanExporter
+}
+
+// NewTracer returns a tracer for a service exporting its sampled spans. Traces started by the tracer
+// are sampled; traces continued from another service keep the sampling decision of the caller.
+func NewTracer(serviceName string, exporter SpanExporter) *Tracer {
+	return &Tracer{serviceName: serviceName, exporter: exporter}
+}
+
+// Start starts a span that is a child of the span in ctx, of the remote span context in ctx,
+// see ExtractTraceContext, or the root of a new trace. The returned context carries the span.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		Name:        name,
+		Kind:        kind,
+		StartTime:   time.Now(),
+		Attributes:  make(map[string]interface{}),
+		ServiceName: t.serviceName,
+		tracer:      t,
+	}
+
+	parent := SpanFromContext(ctx).Context()
+	if !parent.IsValid() {
+		parent, _ = ctx.Value(remoteSpanContextKey).(SpanContext)
+	}
+
+	if parent.IsValid() {
+		span.SpanContext = SpanContext{
+			TraceID:    parent.TraceID,
+			Sampled:    parent.Sampled,
+			TraceState: parent.TraceState,
+		}
+		span.ParentSpanID = parent.SpanID
+	} else {
+		binary.BigEndian.PutUint64(span.SpanContext.TraceID[:8], rand.Uint64())
+		binary.BigEndian.PutUint64(span.SpanContext.TraceID[8:], rand.Uint64()|1)
+		span.SpanContext.Sampled = true
+	}
+	binary.BigEndian.PutUint64(span.SpanContext.SpanID[:], rand.Uint64()|1)
+
+	return context.WithValue(ctx, spanKey, span), span
+}
+
+type spanKeyType struct{}
+type remoteSpanContextKeyType struct{}
+
+var (
+	spanKey              = spanKeyType{}
+	remoteSpanContextKey = remoteSpanContextKeyType{}
+)
+
+// SpanFromContext returns the current span of ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey).(*Span)
+	return span
+}
+
+// StartSpan starts a child of the span in ctx with the same tracer. Without a span in ctx
+// tracing is disabled, and it returns ctx unchanged with a nil span.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	tracer := SpanFromContext(ctx).Tracer()
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, name, kind)
+}
+
+// TraceIDFromContext returns the hex trace ID of the span in ctx, or an empty string
+func TraceIDFromContext(ctx context.Context) string {
+	if sc := SpanFromContext(ctx).Context(); sc.IsValid() {
+		return sc.TraceID.String()
+	}
+	return ""
+}
+
+// ExtractTraceContext returns a copy of ctx carrying the span context of the traceparent and
+// tracestate headers, so the next span started continues the caller's trace. Invalid headers are ignored.
+func ExtractTraceContext(ctx context.Context, header http.Header) context.Context {
+	sc, err := ParseTraceparent(header.Get(TraceparentHeader))
+	if err != nil {
+		return ctx
+	}
+	sc.TraceState = strings.Join(header.Values(TracestateHeader), ",")
+	return context.WithValue(ctx, remoteSpanContextKey, sc)
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers from the span in ctx,
+// for requests to other services or for responses
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	sc := SpanFromContext(ctx).Context()
+	if !sc.IsValid() {
+		return
+	}
+	header.Set(TraceparentHeader, sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set(TracestateHeader, sc.TraceState)
+	}
+}
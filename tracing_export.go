@@ -0,0 +1,339 @@
+package steel
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	json "github.com/json-iterator/go"
+)
+
+// InMemorySpanExporter keeps ended spans in memory, for tests
+type InMemorySpanExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewInMemorySpanExporter returns an empty in-memory exporter
+func NewInMemorySpanExporter() *InMemorySpanExporter {
+	return &InMemorySpanExporter{}
+}
+
+func (e *InMemorySpanExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far, in the order they ended
+func (e *InMemorySpanExporter) Spans() []*Span {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]*Span{}, e.spans...)
+}
+
+// Reset drops the spans exported so far
+func (e *InMemorySpanExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
+
+// stdoutSpan is the JSON form of a span written by the stdout exporter
+type stdoutSpan struct {
+	Name          string                 `json:"name"`
+	Kind          string                 `json:"kind"`
+	Service       string                 `json:"service,omitempty"`
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Start         time.Time              `json:"start"`
+	Duration      string                 `json:"duration"`
+	Status        string                 `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Events        []SpanEvent            `json:"events,omitempty"`
+	DroppedEvents int                    `json:"dropped_events,omitempty"`
+}
+
+type stdoutSpanExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewStdoutSpanExporter returns an exporter writing each span as a line of JSON to w, os.Stdout when nil
+func NewStdoutSpanExporter(w io.Writer) SpanExporter {
+	if w == nil {
+		w = os.Stdout
+	}
+	return &stdoutSpanExporter{w: w}
+}
+
+func (e *stdoutSpanExporter) ExportSpan(span *Span) {
+	record := stdoutSpan{
+		Name:          span.Name,
+		Kind:          span.Kind.String(),
+		Service:       span.ServiceName,
+		TraceID:       span.SpanContext.TraceID.String(),
+		SpanID:        span.SpanContext.SpanID.String(),
+		Start:         span.StartTime,
+		Duration:      span.EndTime.Sub(span.StartTime).String(),
+		Status:        span.StatusCode.String(),
+		StatusMessage: span.StatusMessage,
+		Attributes:    span.Attributes,
+		Events:        span.Events,
+		DroppedEvents: span.DroppedEvents,
+	}
+	if span.ParentSpanID.IsValid() {
+		record.ParentSpanID = span.ParentSpanID.String()
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		log.Printf("steel: exporting span %q: %v", span.Name, err)
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.w.Write(append(data, '\n'))
+}
+
+// =============================================================================
+// OTLP Exporter
+// =============================================================================
+
+// OTLPConfig configures an exporter sending spans to an OpenTelemetry collector over OTLP/HTTP
+type OTLPConfig struct {
+	// Endpoint is the traces URL of the collector, such as http://localhost:4318/v1/traces
+	Endpoint   string
+	Headers    map[string]string
+	HTTPClient *http.Client
+	// BatchSize is the number of spans sent per request, 512 when zero
+	BatchSize int
+	// FlushInterval is the longest time a span waits to be sent, 5 seconds when zero
+	FlushInterval time.Duration
+}
+
+// OTLPSpanExporter sends spans in batches to an OpenTelemetry collector using the OTLP/HTTP JSON
+// encoding, without depending on the OpenTelemetry SDK. Call Shutdown to send the last batch.
+type OTLPSpanExporter struct {
+	config  OTLPConfig
+	mu      sync.Mutex
+	pending []*Span
+	flushes chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// NewOTLPSpanExporter starts an exporter sending spans to the collector of config
+func NewOTLPSpanExporter(config OTLPConfig) *OTLPSpanExporter {
+	if config.Endpoint == "" {
+		panic("OTLP exporter requires an endpoint")
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 512
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Second
+	}
+
+	e := &OTLPSpanExporter{
+		config:  config,
+		flushes: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+func (e *OTLPSpanExporter) ExportSpan(span *Span) {
+	e.mu.Lock()
+	e.pending = append(e.pending, span)
+	full := len(e.pending) >= e.config.BatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushes <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (e *OTLPSpanExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flushes:
+		case <-e.done:
+			return
+		}
+		if err := e.Flush(context.Background()); err != nil {
+			log.Printf("steel: exporting spans: %v", err)
+		}
+	}
+}
+
+// Flush sends the pending spans
+func (e *OTLPSpanExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	spans := e.pending
+	e.pending = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		batch := spans[:min(len(spans), e.config.BatchSize)]
+		spans = spans[len(batch):]
+		if err := e.send(ctx, batch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the exporter and sends the pending spans
+func (e *OTLPSpanExporter) Shutdown(ctx context.Context) error {
+	close(e.done)
+	<-e.stopped
+	return e.Flush(ctx)
+}
+
+func (e *OTLPSpanExporter) send(ctx context.Context, spans []*Span) error {
+	// The request is built from maps; the standard library config keeps their keys sorted
+	body, err := json.ConfigCompatibleWithStandardLibrary.Marshal(otlpRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.config.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.config.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("collector responded %s", resp.Status)
+	}
+	return nil
+}
+
+// otlpRequest builds an ExportTraceServiceRequest in the OTLP JSON encoding,
+// grouping the spans by service
+func otlpRequest(spans []*Span) map[string]interface{} {
+	byService := make(map[string][]interface{})
+	var services []string
+	for _, span := range spans {
+		if _, seen := byService[span.ServiceName]; !seen {
+			services = append(services, span.ServiceName)
+		}
+		byService[span.ServiceName] = append(byService[span.ServiceName], otlpSpan(span))
+	}
+
+	resourceSpans := make([]interface{}, 0, len(services))
+	for _, service := range services {
+		resourceSpans = append(resourceSpans, map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]interface{}{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/xraph/steel"},
+				"spans": byService[service],
+			}},
+		})
+	}
+	return map[string]interface{}{"resourceSpans": resourceSpans}
+}
+
+func otlpSpan(span *Span) map[string]interface{} {
+	events := make([]interface{}, 0, len(span.Events))
+	for _, event := range span.Events {
+		events = append(events, map[string]interface{}{
+			"name":         event.Name,
+			"timeUnixNano": strconv.FormatInt(event.Time.UnixNano(), 10),
+			"attributes":   otlpAttributes(event.Attributes),
+		})
+	}
+
+	record := map[string]interface{}{
+		"traceId":           span.SpanContext.TraceID.String(),
+		"spanId":            span.SpanContext.SpanID.String(),
+		"name":              span.Name,
+		"kind":              int(span.Kind) + 1, // OTLP numbers kinds from SPAN_KIND_INTERNAL = 1
+		"startTimeUnixNano": strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		"endTimeUnixNano":   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		"attributes":        otlpAttributes(span.Attributes),
+		"events":            events,
+		"status": map[string]interface{}{
+			"code":    int(span.StatusCode),
+			"message": span.StatusMessage,
+		},
+	}
+	if span.ParentSpanID.IsValid() {
+		record["parentSpanId"] = span.ParentSpanID.String()
+	}
+	if span.SpanContext.TraceState != "" {
+		record["traceState"] = span.SpanContext.TraceState
+	}
+	if span.DroppedEvents > 0 {
+		record["droppedEventsCount"] = span.DroppedEvents
+	}
+	return record
+}
+
+// otlpAttributes converts attributes to OTLP key-value pairs sorted by key
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		pairs = append(pairs, map[string]interface{}{"key": key, "value": value})
+	}
+	return pairs
+}
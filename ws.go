@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"reflect"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -16,10 +18,26 @@ type WSConnection struct {
 	params   *Params
 	request  *http.Request
 	clientID string
+	codec    WSCodec
 	metadata map[string]interface{}
 	mu       sync.RWMutex
+
+	// Request/response correlation state
+	requestTimeout time.Duration
+	acknowledge    bool
+	requestSeq     atomic.Uint64
+	pending        map[string]chan WSMessage
+	pendingMu      sync.Mutex
+	closed         bool
+	jsonRPC        bool
 }
 
+// WebSocket frame types accepted by SendRaw and returned by ReadRaw.
+const (
+	WSTextFrame   = websocket.TextMessage
+	WSBinaryFrame = websocket.BinaryMessage
+)
+
 // WSMessage represents a WebSocket message containing a type, payload, optional ID, and optional error.
 type WSMessage struct {
 	Type    string      `json:"type"`
@@ -49,23 +67,122 @@ type WSHandlerInfo struct {
 	Summary      string
 	Description  string
 	Tags         []string
+	Codec        WSCodec
+
+	// RequestTimeout bounds server-initiated requests made with WSConnection.SendRequest.
+	RequestTimeout time.Duration
+
+	// Acknowledge sends an "ack" message for every client message carrying an ID before the handler runs.
+	Acknowledge bool
+
+	// ParamsType is the struct bound from the upgrade request, documented in the ws binding.
+	ParamsType reflect.Type
+
+	// SecurityRequirements documents the authentication required to connect.
+	SecurityRequirements []OpenAPISecurityRequirement
+
+	// OperationID identifies the endpoint's operations in the AsyncAPI specification.
+	OperationID string
+
+	// Deprecated marks the endpoint's operations as deprecated.
+	Deprecated bool
+
+	// Examples are documented on the message received from clients.
+	Examples []AsyncAPIExample
+
+	// HeadersType is the struct documenting the headers of the message received from clients.
+	HeadersType reflect.Type
 }
 
-// SendMessage sends a WSMessage to the WebSocket connection in a thread-safe manner using a mutex lock.
+// Codec returns the codec used to encode and decode messages on this connection, defaulting to JSONCodec.
+func (ws *WSConnection) Codec() WSCodec {
+	if ws.codec == nil {
+		return JSONCodec
+	}
+	return ws.codec
+}
+
+// SendMessage encodes a WSMessage with the connection codec and sends it in a thread-safe manner.
+// With RawCodec only the payload is written; error messages are still sent as JSON text frames so clients can read them.
 func (ws *WSConnection) SendMessage(message WSMessage) error {
+	codec := ws.Codec()
+
+	if isRawCodec(codec) {
+		if message.Error != nil {
+			data, err := JSONCodec.Marshal(message)
+			if err != nil {
+				return err
+			}
+			return ws.SendRaw(WSTextFrame, data)
+		}
+		data, err := codec.Marshal(message.Payload)
+		if err != nil {
+			return err
+		}
+		return ws.SendRaw(codec.FrameType(), data)
+	}
+
+	data, err := codec.Marshal(message)
+	if err != nil {
+		return err
+	}
+	return ws.SendRaw(codec.FrameType(), data)
+}
+
+// ReadMessage reads the next frame and decodes it into a WSMessage using the connection codec.
+// With RawCodec the frame bytes are returned as the payload and the type is "text" or "binary".
+func (ws *WSConnection) ReadMessage() (WSMessage, error) {
+	frameType, data, err := ws.ReadRaw()
+	if err != nil {
+		return WSMessage{}, err
+	}
+	return ws.decodeMessage(frameType, data)
+}
+
+// decodeMessage decodes a single frame into a WSMessage using the connection codec.
+func (ws *WSConnection) decodeMessage(frameType int, data []byte) (WSMessage, error) {
+	var message WSMessage
+
+	codec := ws.Codec()
+	if isRawCodec(codec) {
+		message.Type = "binary"
+		if frameType == WSTextFrame {
+			message.Type = "text"
+		}
+		message.Payload = data
+		return message, nil
+	}
+
+	err := codec.Unmarshal(data, &message)
+	return message, err
+}
+
+// SendText sends a text frame containing the given string without any envelope or encoding.
+func (ws *WSConnection) SendText(text string) error {
+	return ws.SendRaw(WSTextFrame, []byte(text))
+}
+
+// SendBinary sends a binary frame containing the given bytes without any envelope or encoding.
+func (ws *WSConnection) SendBinary(data []byte) error {
+	return ws.SendRaw(WSBinaryFrame, data)
+}
+
+// SendRaw writes a frame of the given type (WSTextFrame or WSBinaryFrame) in a thread-safe manner.
+func (ws *WSConnection) SendRaw(frameType int, data []byte) error {
 	ws.mu.Lock()
 	defer ws.mu.Unlock()
 	if ws.conn == nil {
 		return fmt.Errorf("websocket connection is nil")
 	}
-	return ws.conn.WriteJSON(message)
+	return ws.conn.WriteMessage(frameType, data)
 }
 
-// ReadMessage reads a JSON-formatted message from the WebSocket connection and returns it as a WSMessage, or an error.
-func (ws *WSConnection) ReadMessage() (WSMessage, error) {
-	var message WSMessage
-	err := ws.conn.ReadJSON(&message)
-	return message, err
+// ReadRaw reads the next frame from the connection and returns its type and undecoded contents.
+func (ws *WSConnection) ReadRaw() (int, []byte, error) {
+	if ws.conn == nil {
+		return 0, nil, fmt.Errorf("websocket connection is nil")
+	}
+	return ws.conn.ReadMessage()
 }
 
 // Close safely closes the underlying WebSocket connection, ensuring thread-safety by locking the mutex during operation.
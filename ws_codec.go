@@ -0,0 +1,136 @@
+package steel
+
+import (
+	"bytes"
+	"encoding"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/gorilla/websocket"
+	json "github.com/json-iterator/go"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// WSCodec encodes and decodes WebSocket frames for an endpoint.
+// The codec decides both the wire format of the WSMessage envelope and the frame type used to carry it.
+type WSCodec interface {
+	// ContentType returns the MIME type documented for messages using this codec.
+	ContentType() string
+
+	// FrameType returns the WebSocket frame type (websocket.TextMessage or websocket.BinaryMessage).
+	FrameType() int
+
+	// Marshal encodes a value into a frame payload.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal decodes a frame payload into the value pointed to by v.
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Built-in codecs available for WebSocket endpoints.
+var (
+	// JSONCodec exchanges WSMessage envelopes as JSON text frames. This is the default.
+	JSONCodec WSCodec = jsonCodec{}
+
+	// MessagePackCodec exchanges WSMessage envelopes as MessagePack binary frames.
+	MessagePackCodec WSCodec = msgpackCodec{}
+
+	// CBORCodec exchanges WSMessage envelopes as CBOR binary frames.
+	CBORCodec WSCodec = cborCodec{}
+
+	// RawCodec passes frames through without an envelope.
+	// Handlers receive the frame bytes as their message and their response is written back as a binary frame.
+	RawCodec WSCodec = rawCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string { return "application/json" }
+
+func (jsonCodec) FrameType() int { return websocket.TextMessage }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) ContentType() string { return "application/msgpack" }
+
+func (msgpackCodec) FrameType() int { return websocket.BinaryMessage }
+
+// Marshal encodes v using the json struct tags so envelopes and payloads keep the same field names as the JSON codec.
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetOmitEmpty(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string { return "application/cbor" }
+
+func (cborCodec) FrameType() int { return websocket.BinaryMessage }
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) { return cbor.Marshal(v) }
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error { return cbor.Unmarshal(data, v) }
+
+type rawCodec struct{}
+
+func (rawCodec) ContentType() string { return "application/octet-stream" }
+
+func (rawCodec) FrameType() int { return websocket.BinaryMessage }
+
+// Marshal accepts []byte, string and encoding.BinaryMarshaler values.
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return val, nil
+	case *[]byte:
+		return *val, nil
+	case string:
+		return []byte(val), nil
+	case *string:
+		return []byte(*val), nil
+	case encoding.BinaryMarshaler:
+		return val.MarshalBinary()
+	default:
+		return nil, fmt.Errorf("raw codec cannot encode %T", v)
+	}
+}
+
+// Unmarshal accepts *[]byte, *string and encoding.BinaryUnmarshaler targets.
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	switch val := v.(type) {
+	case *[]byte:
+		*val = append((*val)[:0], data...)
+		return nil
+	case *string:
+		*val = string(data)
+		return nil
+	case encoding.BinaryUnmarshaler:
+		return val.UnmarshalBinary(data)
+	default:
+		return fmt.Errorf("raw codec cannot decode into %T", v)
+	}
+}
+
+// isRawCodec reports whether frames for the codec carry no WSMessage envelope.
+func isRawCodec(codec WSCodec) bool {
+	_, ok := codec.(rawCodec)
+	return ok
+}
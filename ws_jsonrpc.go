@@ -0,0 +1,459 @@
+package steel
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+
+	json "github.com/json-iterator/go"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	JSONRPCParseError     = -32700
+	JSONRPCInvalidRequest = -32600
+	JSONRPCMethodNotFound = -32601
+	JSONRPCInvalidParams  = -32602
+	JSONRPCInternalError  = -32603
+	JSONRPCServerError    = -32000
+)
+
+// JSONRPCVersion is the protocol version carried by every JSON-RPC message.
+const JSONRPCVersion = "2.0"
+
+// JSONRPCError is the error object of a JSON-RPC 2.0 response.
+// Handlers may return it directly to control the code sent to the client.
+type JSONRPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// NewJSONRPCError creates a JSONRPCError with the given code, message and optional data.
+func NewJSONRPCError(code int, message string, data ...interface{}) *JSONRPCError {
+	var d interface{}
+	if len(data) > 0 {
+		d = data[0]
+	}
+	return &JSONRPCError{
+		Code:    code,
+		Message: message,
+		Data:    d,
+	}
+}
+
+// toJSONRPCError maps a handler error to a JSONRPCError.
+// Errors that are not JSONRPCError values are reported as server errors with the WSError as data.
+func toJSONRPCError(err error) *JSONRPCError {
+	if rpcErr, ok := err.(*JSONRPCError); ok {
+		return rpcErr
+	}
+
+	wsErr := toWSError(err)
+	return &JSONRPCError{
+		Code:    JSONRPCServerError,
+		Message: wsErr.Message,
+		Data:    wsErr,
+	}
+}
+
+// jsonRPCRequest is an incoming JSON-RPC request or notification.
+// A request without an id is a notification and gets no response.
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// jsonRPCResponse is an outgoing JSON-RPC response. Exactly one of Result and Error is set.
+type jsonRPCResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	Result  *json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError    `json:"error,omitempty"`
+	ID      json.RawMessage  `json:"id"`
+}
+
+// jsonRPCNotification is a server-initiated JSON-RPC notification.
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+// jsonRPCMethod holds a registered JSON-RPC method handler.
+type jsonRPCMethod struct {
+	name        string
+	handler     reflect.Value
+	paramsType  reflect.Type
+	resultType  reflect.Type
+	summary     string
+	description string
+	tags        []string
+}
+
+// JSONRPCEndpoint is a WebSocket endpoint speaking JSON-RPC 2.0.
+// Methods are registered with Method and each one is documented as a message in the AsyncAPI specification.
+type JSONRPCEndpoint struct {
+	router  *SteelRouter
+	info    *WSHandlerInfo
+	methods map[string]*jsonRPCMethod
+	mu      sync.RWMutex
+}
+
+// JSONRPC registers a WebSocket endpoint that speaks JSON-RPC 2.0 and returns it so methods can be added.
+func (r *SteelRouter) JSONRPC(pattern string, opts ...AsyncHandlerOption) *JSONRPCEndpoint {
+	r.initAsyncAPI()
+
+	info := &WSHandlerInfo{
+		Path:  pattern,
+		Codec: JSONCodec,
+	}
+
+	for _, opt := range opts {
+		opt.ApplyToWS(info)
+	}
+	info.Codec = JSONCodec
+
+	endpoint := &JSONRPCEndpoint{
+		router:  r,
+		info:    info,
+		methods: make(map[string]*jsonRPCMethod),
+	}
+
+	r.wsHandlers[pattern] = info
+	r.updateSpec(func() {
+		r.generateAsyncAPIForJSONRPC(endpoint)
+	})
+
+	r.addRoute("GET", pattern, r.wsUpgradeHandler(info, func(wsConn *WSConnection) {
+		wsConn.jsonRPC = true
+		endpoint.serve(wsConn)
+	})).kind = endpointWebSocket
+
+	return endpoint
+}
+
+// Method registers a handler for a JSON-RPC method.
+// The handler must have the signature func(*WSConnection, ParamsType) (*ResultType, error).
+// Summary, description and tags options are used to document the method.
+func (e *JSONRPCEndpoint) Method(name string, handler interface{}, opts ...AsyncHandlerOption) *JSONRPCEndpoint {
+	handlerType := reflect.TypeOf(handler)
+	if handlerType.Kind() != reflect.Func {
+		panic("JSON-RPC handler must be a function")
+	}
+
+	if handlerType.NumIn() != 2 || handlerType.NumOut() != 2 ||
+		handlerType.In(0) != reflect.TypeOf((*WSConnection)(nil)) ||
+		!handlerType.Out(1).Implements(reflect.TypeOf((*error)(nil)).Elem()) {
+		panic("JSON-RPC handler must have signature func(*WSConnection, ParamsType) (*ResultType, error)")
+	}
+
+	resultType := handlerType.Out(0)
+	if resultType.Kind() == reflect.Ptr {
+		resultType = resultType.Elem()
+	}
+
+	doc := &WSHandlerInfo{}
+	for _, opt := range opts {
+		opt.ApplyToWS(doc)
+	}
+
+	e.mu.Lock()
+	e.methods[name] = &jsonRPCMethod{
+		name:        name,
+		handler:     reflect.ValueOf(handler),
+		paramsType:  handlerType.In(1),
+		resultType:  resultType,
+		summary:     doc.Summary,
+		description: doc.Description,
+		tags:        doc.Tags,
+	}
+	e.mu.Unlock()
+
+	e.router.updateSpec(func() {
+		e.router.generateAsyncAPIForJSONRPC(e)
+	})
+	return e
+}
+
+// serve reads JSON-RPC frames until the connection closes, answering requests in order.
+func (e *JSONRPCEndpoint) serve(wsConn *WSConnection) {
+	defer wsConn.Close()
+	defer wsConn.failPending()
+
+	for {
+		_, data, err := wsConn.ReadRaw()
+		if err != nil {
+			return
+		}
+
+		if reply := e.handleFrame(wsConn, data); reply != nil {
+			if err := wsConn.SendRaw(WSTextFrame, reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handleFrame processes a single request or a batch and returns the encoded reply, or nil if nothing is to be sent.
+func (e *JSONRPCEndpoint) handleFrame(wsConn *WSConnection, data []byte) []byte {
+	trimmed := bytes.TrimSpace(data)
+
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var batch []json.RawMessage
+		if err := json.Unmarshal(trimmed, &batch); err != nil {
+			return encodeJSONRPCResponse(jsonRPCErrorResponse(nil, NewJSONRPCError(JSONRPCParseError, "Parse error", err.Error())))
+		}
+		if len(batch) == 0 {
+			return encodeJSONRPCResponse(jsonRPCErrorResponse(nil, NewJSONRPCError(JSONRPCInvalidRequest, "Invalid Request")))
+		}
+
+		responses := make([]*jsonRPCResponse, 0, len(batch))
+		for _, item := range batch {
+			if response := e.handleRequest(wsConn, item); response != nil {
+				responses = append(responses, response)
+			}
+		}
+		if len(responses) == 0 {
+			return nil
+		}
+
+		encoded, _ := json.Marshal(responses)
+		return encoded
+	}
+
+	if response := e.handleRequest(wsConn, trimmed); response != nil {
+		return encodeJSONRPCResponse(response)
+	}
+	return nil
+}
+
+// handleRequest dispatches one JSON-RPC request. Notifications return a nil response.
+func (e *JSONRPCEndpoint) handleRequest(wsConn *WSConnection, data []byte) (response *jsonRPCResponse) {
+	var request jsonRPCRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		if json.Valid(data) {
+			return jsonRPCErrorResponse(nil, NewJSONRPCError(JSONRPCInvalidRequest, "Invalid Request", err.Error()))
+		}
+		return jsonRPCErrorResponse(nil, NewJSONRPCError(JSONRPCParseError, "Parse error", err.Error()))
+	}
+
+	isNotification := len(request.ID) == 0
+
+	if request.JSONRPC != JSONRPCVersion || request.Method == "" {
+		return jsonRPCErrorResponse(request.ID, NewJSONRPCError(JSONRPCInvalidRequest, "Invalid Request"))
+	}
+
+	e.mu.RLock()
+	method, ok := e.methods[request.Method]
+	e.mu.RUnlock()
+
+	if !ok {
+		if isNotification {
+			return nil
+		}
+		return jsonRPCErrorResponse(request.ID, NewJSONRPCError(JSONRPCMethodNotFound, "Method not found", request.Method))
+	}
+
+	_, span := StartSpan(wsConn.request.Context(), "JSONRPC "+request.Method, SpanKindServer)
+	defer span.End()
+	span.SetAttribute("rpc.system", "jsonrpc")
+	span.SetAttribute("rpc.method", request.Method)
+	span.SetAttribute("http.route", RoutePattern(wsConn.request))
+
+	// A panicking method fails the call, not the connection
+	defer func() {
+		if value := recover(); value != nil {
+			panicErr := e.router.reportPanic(wsConn.request, value)
+			span.RecordError(panicErr)
+			span.SetStatus(SpanStatusError, panicErr.Error())
+			response = nil
+			if !isNotification {
+				response = jsonRPCErrorResponse(request.ID, NewJSONRPCError(JSONRPCInternalError, "Internal error"))
+			}
+		}
+	}()
+
+	params := reflect.New(method.paramsType)
+	if len(request.Params) > 0 {
+		if err := json.Unmarshal(request.Params, params.Interface()); err != nil {
+			span.RecordError(err)
+			span.SetStatus(SpanStatusError, "Invalid params")
+			if isNotification {
+				return nil
+			}
+			return jsonRPCErrorResponse(request.ID, NewJSONRPCError(JSONRPCInvalidParams, "Invalid params", err.Error()))
+		}
+	}
+
+	results := method.handler.Call([]reflect.Value{
+		reflect.ValueOf(wsConn),
+		params.Elem(),
+	})
+
+	if handlerErr := results[1]; !handlerErr.IsNil() {
+		err := handlerErr.Interface().(error)
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, err.Error())
+		if isNotification {
+			return nil
+		}
+		return jsonRPCErrorResponse(request.ID, toJSONRPCError(err))
+	}
+
+	if isNotification {
+		return nil
+	}
+
+	var result interface{}
+	if !results[0].IsNil() {
+		result = results[0].Interface()
+	}
+
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return jsonRPCErrorResponse(request.ID, NewJSONRPCError(JSONRPCInternalError, "Internal error", err.Error()))
+	}
+
+	raw := json.RawMessage(encoded)
+	return &jsonRPCResponse{
+		JSONRPC: JSONRPCVersion,
+		Result:  &raw,
+		ID:      request.ID,
+	}
+}
+
+// jsonRPCErrorResponse builds an error response, using a null id when the request id is unknown.
+func jsonRPCErrorResponse(id json.RawMessage, rpcErr *JSONRPCError) *jsonRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &jsonRPCResponse{
+		JSONRPC: JSONRPCVersion,
+		Error:   rpcErr,
+		ID:      id,
+	}
+}
+
+func encodeJSONRPCResponse(response *jsonRPCResponse) []byte {
+	encoded, _ := json.Marshal(response)
+	return encoded
+}
+
+// Notify sends a JSON-RPC notification to the client of a JSON-RPC endpoint.
+func (ws *WSConnection) Notify(method string, params interface{}) error {
+	if !ws.jsonRPC {
+		return fmt.Errorf("notify is only supported on JSON-RPC endpoints")
+	}
+
+	data, err := json.Marshal(jsonRPCNotification{
+		JSONRPC: JSONRPCVersion,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	return ws.SendRaw(WSTextFrame, data)
+}
+
+// generateAsyncAPIForJSONRPC documents a JSON-RPC endpoint as a channel whose messages are one of the registered methods.
+// Requests are documented on subscribe and results on publish, one message per method.
+func (r *SteelRouter) generateAsyncAPIForJSONRPC(endpoint *JSONRPCEndpoint) {
+	endpoint.mu.RLock()
+	names := make([]string, 0, len(endpoint.methods))
+	for name := range endpoint.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	requests := make([]AsyncAPIMessage, 0, len(names))
+	results := make([]AsyncAPIMessage, 0, len(names))
+
+	for _, name := range names {
+		method := endpoint.methods[name]
+
+		request := AsyncAPIMessage{
+			Name:        name,
+			Title:       name,
+			Summary:     method.summary,
+			Description: method.description,
+			ContentType: "application/json",
+			Payload: AsyncAPISchema{
+				Type: "object",
+				Properties: map[string]AsyncAPISchema{
+					"jsonrpc": {Type: "string", Enum: []interface{}{JSONRPCVersion}},
+					"method":  {Type: "string", Enum: []interface{}{name}},
+					"params":  r.typeToAsyncAPISchema(method.paramsType),
+					"id":      {Description: "Request identifier; omitted for notifications"},
+				},
+				Required: []string{"jsonrpc", "method"},
+			},
+		}
+
+		result := AsyncAPIMessage{
+			Name:        name + ".result",
+			Title:       name + " result",
+			Summary:     method.summary,
+			ContentType: "application/json",
+			Payload: AsyncAPISchema{
+				Type: "object",
+				Properties: map[string]AsyncAPISchema{
+					"jsonrpc": {Type: "string", Enum: []interface{}{JSONRPCVersion}},
+					"result":  r.typeToAsyncAPISchema(method.resultType),
+					"error":   r.typeToAsyncAPISchema(reflect.TypeOf(JSONRPCError{})),
+					"id":      {Description: "Identifier of the request being answered"},
+				},
+				Required: []string{"jsonrpc", "id"},
+			},
+		}
+
+		requests = append(requests, request)
+		results = append(results, result)
+		r.asyncAPISpec.Components.Messages[name] = request
+		r.asyncAPISpec.Components.Messages[name+".result"] = result
+	}
+	endpoint.mu.RUnlock()
+
+	info := endpoint.info
+	channel := AsyncAPIChannel{
+		Description: info.Description,
+		Subscribe: &AsyncAPIOperation{
+			OperationID: info.OperationID,
+			Summary:     info.Summary,
+			Description: info.Description,
+			Tags:        convertToAsyncAPITags(info.Tags),
+			Message: AsyncAPIMessage{
+				ContentType: "application/json",
+				OneOf:       requests,
+			},
+			Security:   info.SecurityRequirements,
+			Deprecated: info.Deprecated,
+		},
+		Publish: &AsyncAPIOperation{
+			OperationID: replyOperationID(info.OperationID),
+			Summary:     info.Summary + " Response",
+			Description: info.Description,
+			Tags:        convertToAsyncAPITags(info.Tags),
+			Message: AsyncAPIMessage{
+				ContentType: "application/json",
+				OneOf:       results,
+			},
+			Security:   info.SecurityRequirements,
+			Deprecated: info.Deprecated,
+		},
+		Bindings: r.wsChannelBindings(info.ParamsType),
+	}
+
+	r.asyncAPISpec.Channels[info.Path] = channel
+	r.generateAsyncAPI3ForJSONRPC(info, requests, results)
+}
@@ -0,0 +1,242 @@
+package steel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Standard WSError codes sent to WebSocket clients.
+const (
+	WSErrorInvalidMessage = "INVALID_MESSAGE"
+	WSErrorHandler        = "HANDLER_ERROR"
+	WSErrorTimeout        = "TIMEOUT"
+	WSErrorCanceled       = "CANCELED"
+	WSErrorUnknownType    = "UNKNOWN_TYPE"
+	WSErrorInternal       = "INTERNAL_ERROR"
+)
+
+// Message types used by the request/response protocol.
+const (
+	WSMessageTypeAck      = "ack"
+	WSMessageTypeResponse = "response"
+	WSMessageTypeError    = "error"
+)
+
+// ErrWSConnectionClosed is returned by pending requests when the connection closes before a reply arrives.
+var ErrWSConnectionClosed = errors.New("websocket connection closed")
+
+// DefaultWSRequestTimeout bounds server-initiated requests whose context carries no deadline.
+const DefaultWSRequestTimeout = 30 * time.Second
+
+// Error implements the error interface so a WSError can be returned from handlers and SendRequest.
+func (e *WSError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// NewWSError creates a WSError with the given code, message and optional details.
+func NewWSError(code, message string, details ...interface{}) *WSError {
+	var detail interface{}
+	if len(details) > 0 {
+		detail = details[0]
+	}
+	return &WSError{
+		Code:    code,
+		Message: message,
+		Details: detail,
+	}
+}
+
+// toWSError maps a handler error to a WSError, keeping the code of WSError and APIError values.
+func toWSError(err error) *WSError {
+	var wsErr *WSError
+	if errors.As(err, &wsErr) {
+		return wsErr
+	}
+
+	var apiErr APIError
+	if errors.As(err, &apiErr) {
+		return &WSError{
+			Code:    apiErr.ErrorCode(),
+			Message: err.Error(),
+			Details: apiErr.Details(),
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return NewWSError(WSErrorTimeout, err.Error())
+	case errors.Is(err, context.Canceled):
+		return NewWSError(WSErrorCanceled, err.Error())
+	}
+
+	return NewWSError(WSErrorHandler, err.Error())
+}
+
+// wsInbound is a decoded frame delivered from the reader goroutine to the dispatch loop.
+type wsInbound struct {
+	message WSMessage
+	err     error
+}
+
+// SendRequest sends a server-initiated message to the client and waits for the reply carrying the same ID.
+// The client must answer with a message whose ID matches the request. If ctx has no deadline the request is bounded by the endpoint request timeout (DefaultWSRequestTimeout unless configured).
+// A reply with an Error is returned together with that error.
+func (ws *WSConnection) SendRequest(ctx context.Context, msgType string, payload interface{}) (*WSMessage, error) {
+	if isRawCodec(ws.Codec()) {
+		return nil, fmt.Errorf("request/response is not supported with the raw codec")
+	}
+	if ws.jsonRPC {
+		return nil, fmt.Errorf("request/response is not supported on JSON-RPC endpoints, use Notify")
+	}
+
+	if _, hasDeadline := ctx.Deadline(); !hasDeadline {
+		timeout := ws.requestTimeout
+		if timeout <= 0 {
+			timeout = DefaultWSRequestTimeout
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	id := "srv_" + strconv.FormatUint(ws.requestSeq.Add(1), 10)
+	reply := make(chan WSMessage, 1)
+
+	ws.pendingMu.Lock()
+	if ws.closed {
+		ws.pendingMu.Unlock()
+		return nil, ErrWSConnectionClosed
+	}
+	if ws.pending == nil {
+		ws.pending = make(map[string]chan WSMessage)
+	}
+	ws.pending[id] = reply
+	ws.pendingMu.Unlock()
+
+	defer func() {
+		ws.pendingMu.Lock()
+		delete(ws.pending, id)
+		ws.pendingMu.Unlock()
+	}()
+
+	if err := ws.SendMessage(WSMessage{Type: msgType, Payload: payload, ID: id}); err != nil {
+		return nil, err
+	}
+
+	select {
+	case message, ok := <-reply:
+		if !ok {
+			return nil, ErrWSConnectionClosed
+		}
+		if message.Error != nil {
+			return &message, message.Error
+		}
+		return &message, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// resolvePending delivers a reply to the matching pending SendRequest and reports whether it was consumed.
+func (ws *WSConnection) resolvePending(message WSMessage) bool {
+	if message.ID == "" {
+		return false
+	}
+
+	ws.pendingMu.Lock()
+	defer ws.pendingMu.Unlock()
+
+	reply, ok := ws.pending[message.ID]
+	if !ok {
+		return false
+	}
+	delete(ws.pending, message.ID)
+	reply <- message
+	return true
+}
+
+// failPending marks the connection closed and releases all pending requests.
+func (ws *WSConnection) failPending() {
+	ws.pendingMu.Lock()
+	defer ws.pendingMu.Unlock()
+
+	ws.closed = true
+	for id, reply := range ws.pending {
+		close(reply)
+		delete(ws.pending, id)
+	}
+}
+
+// wsMaxQueuedMessages bounds the messages read ahead of the dispatch loop. A client exceeding it
+// while a handler is busy is disconnected.
+const wsMaxQueuedMessages = 1024
+
+// startReader reads frames on a dedicated goroutine so replies to SendRequest are routed
+// even while a handler is blocked waiting for them. Other messages are queued for the returned channel,
+// which is closed once the connection fails and the queue is drained. The reader never waits on
+// the dispatch loop, so a burst of messages cannot hold back a reply.
+func (ws *WSConnection) startReader() <-chan wsInbound {
+	frames := make(chan wsInbound)
+	inbound := make(chan wsInbound)
+
+	go func() {
+		defer close(frames)
+		defer ws.failPending()
+
+		for {
+			frameType, data, err := ws.ReadRaw()
+			if err != nil {
+				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+					log.Printf("WebSocket error: %v", err)
+				}
+				return
+			}
+
+			message, err := ws.decodeMessage(frameType, data)
+			if err == nil && ws.resolvePending(message) {
+				continue
+			}
+
+			frames <- wsInbound{message: message, err: err}
+		}
+	}()
+
+	// The pump always accepts frames from the reader and hands them to the dispatch loop in order
+	go func() {
+		defer close(inbound)
+
+		var queue []wsInbound
+		for frames != nil || len(queue) > 0 {
+			var out chan wsInbound
+			var next wsInbound
+			if len(queue) > 0 {
+				out, next = inbound, queue[0]
+			}
+
+			select {
+			case frame, ok := <-frames:
+				if !ok {
+					frames = nil
+					continue
+				}
+				if len(queue) >= wsMaxQueuedMessages {
+					log.Printf("WebSocket client %s exceeded %d queued messages, closing", ws.clientID, wsMaxQueuedMessages)
+					ws.Close()
+					continue
+				}
+				queue = append(queue, frame)
+			case out <- next:
+				queue[0] = wsInbound{}
+				queue = queue[1:]
+			}
+		}
+	}()
+
+	return inbound
+}
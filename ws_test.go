@@ -1,7 +1,10 @@
 package steel
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,6 +12,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/gorilla/websocket"
 )
 
 // Test message types for WebSocket testing
@@ -766,7 +771,1067 @@ func TestRouterConnectionManager(t *testing.T) {
 	}
 }
 
+// dialTestWebSocket starts a test server for the router and opens a WebSocket connection to path
+func dialTestWebSocket(t *testing.T, router *SteelRouter, path string) *websocket.Conn {
+	t.Helper()
+
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + path
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return conn
+}
+
+// TestWSCodecs tests envelope round trips for the built-in codecs
+func TestWSCodecs(t *testing.T) {
+	codecs := map[string]WSCodec{
+		"json":    JSONCodec,
+		"msgpack": MessagePackCodec,
+		"cbor":    CBORCodec,
+	}
+
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			router := NewRouter()
+			router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+				return &WSTestResponse{Echo: message.Text, Timestamp: int64(message.ID)}, nil
+			}, WithWSCodec(codec))
+
+			client := dialTestWebSocket(t, router, "/ws")
+
+			request, err := codec.Marshal(WSMessage{
+				Type:    "echo",
+				ID:      "req-1",
+				Payload: WSTestMessage{Text: "hello", ID: 7},
+			})
+			if err != nil {
+				t.Fatalf("Failed to encode request: %v", err)
+			}
+			if err := client.WriteMessage(codec.FrameType(), request); err != nil {
+				t.Fatalf("Failed to write request: %v", err)
+			}
+
+			frameType, data, err := client.ReadMessage()
+			if err != nil {
+				t.Fatalf("Failed to read response: %v", err)
+			}
+			if frameType != codec.FrameType() {
+				t.Errorf("Expected frame type %d, got %d", codec.FrameType(), frameType)
+			}
+
+			var response struct {
+				Type    string         `json:"type"`
+				ID      string         `json:"id"`
+				Payload WSTestResponse `json:"payload"`
+			}
+			if err := codec.Unmarshal(data, &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			if response.Type != "response" || response.ID != "req-1" {
+				t.Errorf("Expected response to req-1, got type %q id %q", response.Type, response.ID)
+			}
+			if response.Payload.Echo != "hello" || response.Payload.Timestamp != 7 {
+				t.Errorf("Unexpected payload: %+v", response.Payload)
+			}
+		})
+	}
+}
+
+// TestWSRawCodec tests raw binary frames without an envelope
+func TestWSRawCodec(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws/audio", func(conn *WSConnection, chunk []byte) (*[]byte, error) {
+		reversed := make([]byte, len(chunk))
+		for i, b := range chunk {
+			reversed[len(chunk)-1-i] = b
+		}
+		return &reversed, nil
+	}, WithWSCodec(RawCodec))
+
+	client := dialTestWebSocket(t, router, "/ws/audio")
+
+	if err := client.WriteMessage(websocket.BinaryMessage, []byte{1, 2, 3}); err != nil {
+		t.Fatalf("Failed to write frame: %v", err)
+	}
+
+	frameType, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("Failed to read frame: %v", err)
+	}
+	if frameType != websocket.BinaryMessage {
+		t.Errorf("Expected binary frame, got %d", frameType)
+	}
+	if string(data) != string([]byte{3, 2, 1}) {
+		t.Errorf("Expected reversed bytes, got %v", data)
+	}
+
+	message := router.asyncAPISpec.Channels["/ws/audio"].Subscribe.Message
+	if message.ContentType != "application/octet-stream" {
+		t.Errorf("Expected contentType 'application/octet-stream', got %q", message.ContentType)
+	}
+	if message.Payload.Format != "binary" {
+		t.Errorf("Expected binary payload format, got %q", message.Payload.Format)
+	}
+}
+
+// TestWSCodecContentType tests that the AsyncAPI content type reflects the codec
+func TestWSCodecContentType(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws/default", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	})
+	router.WebSocket("/ws/msgpack", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	}, WithWSCodec(MessagePackCodec))
+
+	if ct := router.asyncAPISpec.Channels["/ws/default"].Publish.Message.ContentType; ct != "application/json" {
+		t.Errorf("Expected default contentType 'application/json', got %q", ct)
+	}
+	if ct := router.asyncAPISpec.Channels["/ws/msgpack"].Publish.Message.ContentType; ct != "application/msgpack" {
+		t.Errorf("Expected contentType 'application/msgpack', got %q", ct)
+	}
+}
+
 // BenchmarkWSConnectionMetadata benchmarks WebSocket connection metadata operations
+func TestWSServerRequest(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		reply, err := conn.SendRequest(context.Background(), "confirm", message)
+		if err != nil {
+			return nil, err
+		}
+		return &WSTestResponse{Echo: fmt.Sprint(reply.Payload)}, nil
+	}, WithWSRequestTimeout(time.Second))
+
+	client := dialTestWebSocket(t, router, "/ws")
+
+	if err := client.WriteJSON(WSMessage{Type: "start", ID: "c1", Payload: WSTestMessage{Text: "go"}}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var request WSMessage
+	if err := client.ReadJSON(&request); err != nil {
+		t.Fatalf("Failed to read server request: %v", err)
+	}
+	if request.Type != "confirm" || request.ID == "" {
+		t.Fatalf("Expected confirm request with ID, got %+v", request)
+	}
+
+	if err := client.WriteJSON(WSMessage{Type: "response", ID: request.ID, Payload: "yes"}); err != nil {
+		t.Fatalf("Failed to write reply: %v", err)
+	}
+
+	var response struct {
+		Type    string         `json:"type"`
+		ID      string         `json:"id"`
+		Payload WSTestResponse `json:"payload"`
+	}
+	if err := client.ReadJSON(&response); err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	if response.ID != "c1" || response.Payload.Echo != "yes" {
+		t.Errorf("Expected correlated response with echo 'yes', got %+v", response)
+	}
+}
+
+func TestWSServerRequestTimeout(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		_, err := conn.SendRequest(context.Background(), "confirm", message)
+		return nil, err
+	}, WithWSRequestTimeout(50*time.Millisecond))
+
+	client := dialTestWebSocket(t, router, "/ws")
+
+	if err := client.WriteJSON(WSMessage{Type: "start", ID: "c1", Payload: WSTestMessage{}}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var request WSMessage
+	if err := client.ReadJSON(&request); err != nil {
+		t.Fatalf("Failed to read server request: %v", err)
+	}
+
+	var response WSMessage
+	if err := client.ReadJSON(&response); err != nil {
+		t.Fatalf("Failed to read error: %v", err)
+	}
+	if response.Type != WSMessageTypeError || response.ID != "c1" {
+		t.Fatalf("Expected error for c1, got %+v", response)
+	}
+	if response.Error.Code != WSErrorTimeout {
+		t.Errorf("Expected code %s, got %s", WSErrorTimeout, response.Error.Code)
+	}
+}
+
+func TestWSServerRequestBehindBurst(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		if message.Text != "go" {
+			return &WSTestResponse{Echo: message.Text}, nil
+		}
+		reply, err := conn.SendRequest(context.Background(), "confirm", message)
+		if err != nil {
+			return nil, err
+		}
+		return &WSTestResponse{Echo: fmt.Sprint(reply.Payload)}, nil
+	}, WithWSRequestTimeout(2*time.Second))
+
+	client := dialTestWebSocket(t, router, "/ws")
+
+	if err := client.WriteJSON(WSMessage{Type: "start", ID: "c1", Payload: WSTestMessage{Text: "go"}}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var request WSMessage
+	if err := client.ReadJSON(&request); err != nil {
+		t.Fatalf("Failed to read server request: %v", err)
+	}
+
+	// More messages than any fixed read-ahead buffer arrive before the reply
+	for i := 0; i < 64; i++ {
+		if err := client.WriteJSON(WSMessage{Type: "noise", ID: fmt.Sprintf("n%d", i), Payload: WSTestMessage{Text: "noise"}}); err != nil {
+			t.Fatalf("Failed to write message: %v", err)
+		}
+	}
+	if err := client.WriteJSON(WSMessage{Type: "response", ID: request.ID, Payload: "yes"}); err != nil {
+		t.Fatalf("Failed to write reply: %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var response struct {
+			Type    string         `json:"type"`
+			ID      string         `json:"id"`
+			Payload WSTestResponse `json:"payload"`
+		}
+		if err := client.ReadJSON(&response); err != nil {
+			t.Fatalf("Failed to read response for c1: %v", err)
+		}
+		if response.ID != "c1" {
+			continue
+		}
+		if response.Payload.Echo != "yes" {
+			t.Errorf("Expected echo 'yes', got %+v", response)
+		}
+		return
+	}
+}
+
+func TestWSAcknowledgementsAndErrorCodes(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, NewWSError("OUT_OF_STOCK", "item unavailable", map[string]interface{}{"id": message.ID})
+	}, WithWSAcknowledgements())
+
+	client := dialTestWebSocket(t, router, "/ws")
+
+	if err := client.WriteJSON(WSMessage{Type: "order", ID: "o1", Payload: WSTestMessage{ID: 3}}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var ack WSMessage
+	if err := client.ReadJSON(&ack); err != nil {
+		t.Fatalf("Failed to read ack: %v", err)
+	}
+	if ack.Type != WSMessageTypeAck || ack.ID != "o1" {
+		t.Errorf("Expected ack for o1, got %+v", ack)
+	}
+
+	var response WSMessage
+	if err := client.ReadJSON(&response); err != nil {
+		t.Fatalf("Failed to read error: %v", err)
+	}
+	if response.Type != WSMessageTypeError || response.ID != "o1" {
+		t.Fatalf("Expected error for o1, got %+v", response)
+	}
+	if response.Error.Code != "OUT_OF_STOCK" {
+		t.Errorf("Expected code OUT_OF_STOCK, got %s", response.Error.Code)
+	}
+}
+
+func TestToWSError(t *testing.T) {
+	if got := toWSError(NotFound("User", 1)).Code; got != "NOT_FOUND" {
+		t.Errorf("Expected NOT_FOUND, got %s", got)
+	}
+	if got := toWSError(context.DeadlineExceeded).Code; got != WSErrorTimeout {
+		t.Errorf("Expected %s, got %s", WSErrorTimeout, got)
+	}
+	if got := toWSError(fmt.Errorf("boom")).Code; got != WSErrorHandler {
+		t.Errorf("Expected %s, got %s", WSErrorHandler, got)
+	}
+}
+
+type rpcAddParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type rpcAddResult struct {
+	Sum int `json:"sum"`
+}
+
+func newTestJSONRPCRouter() *SteelRouter {
+	router := NewRouter()
+	router.JSONRPC("/rpc").
+		Method("add", func(conn *WSConnection, params rpcAddParams) (*rpcAddResult, error) {
+			return &rpcAddResult{Sum: params.A + params.B}, nil
+		}, WithAsyncSummary("Add two numbers")).
+		Method("fail", func(conn *WSConnection, params struct{}) (*rpcAddResult, error) {
+			return nil, NewJSONRPCError(-32001, "custom failure")
+		})
+	return router
+}
+
+func TestJSONRPCEndpoint(t *testing.T) {
+	client := dialTestWebSocket(t, newTestJSONRPCRouter(), "/rpc")
+
+	type rpcResponse struct {
+		JSONRPC string        `json:"jsonrpc"`
+		Result  *rpcAddResult `json:"result"`
+		Error   *JSONRPCError `json:"error"`
+		ID      interface{}   `json:"id"`
+	}
+
+	call := func(request string) []byte {
+		t.Helper()
+		if err := client.WriteMessage(websocket.TextMessage, []byte(request)); err != nil {
+			t.Fatalf("Failed to write request: %v", err)
+		}
+		_, data, err := client.ReadMessage()
+		if err != nil {
+			t.Fatalf("Failed to read response: %v", err)
+		}
+		return data
+	}
+
+	var response rpcResponse
+	json.Unmarshal(call(`{"jsonrpc":"2.0","method":"add","params":{"a":2,"b":3},"id":1}`), &response)
+	if response.Result == nil || response.Result.Sum != 5 || response.ID != float64(1) {
+		t.Errorf("Expected sum 5 for id 1, got %+v", response)
+	}
+
+	response = rpcResponse{}
+	json.Unmarshal(call(`{"jsonrpc":"2.0","method":"missing","id":"x"}`), &response)
+	if response.Error == nil || response.Error.Code != JSONRPCMethodNotFound || response.ID != "x" {
+		t.Errorf("Expected method not found for id x, got %+v", response)
+	}
+
+	response = rpcResponse{}
+	json.Unmarshal(call(`{"jsonrpc":"2.0","method":"fail","id":2}`), &response)
+	if response.Error == nil || response.Error.Code != -32001 {
+		t.Errorf("Expected custom error code, got %+v", response)
+	}
+
+	response = rpcResponse{}
+	json.Unmarshal(call(`{not json`), &response)
+	if response.Error == nil || response.Error.Code != JSONRPCParseError || response.ID != nil {
+		t.Errorf("Expected parse error with null id, got %+v", response)
+	}
+
+	// Notifications in a batch produce no response entries
+	var batch []rpcResponse
+	json.Unmarshal(call(`[{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":1}},{"jsonrpc":"2.0","method":"add","params":{"a":1,"b":2},"id":9}]`), &batch)
+	if len(batch) != 1 || batch[0].Result.Sum != 3 {
+		t.Errorf("Expected single batch response with sum 3, got %+v", batch)
+	}
+}
+
+func TestJSONRPCMethodSignature(t *testing.T) {
+	handlers := map[string]interface{}{
+		"non-error result":  func(conn *WSConnection, params rpcAddParams) (*rpcAddResult, string) { return nil, "" },
+		"missing conn":      func(params rpcAddParams, other rpcAddParams) (*rpcAddResult, error) { return nil, nil },
+		"wrong param count": func(conn *WSConnection) (*rpcAddResult, error) { return nil, nil },
+	}
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected registration to panic")
+				}
+			}()
+			NewRouter().JSONRPC("/rpc").Method("bad", handler)
+		})
+	}
+}
+
+func TestJSONRPCAsyncAPI(t *testing.T) {
+	router := newTestJSONRPCRouter()
+
+	channel, exists := router.asyncAPISpec.Channels["/rpc"]
+	if !exists {
+		t.Fatal("Expected /rpc channel in AsyncAPI spec")
+	}
+
+	messages := channel.Subscribe.Message.OneOf
+	if len(messages) != 2 || messages[0].Name != "add" || messages[1].Name != "fail" {
+		t.Fatalf("Expected add and fail method messages, got %+v", messages)
+	}
+	if messages[0].Summary != "Add two numbers" {
+		t.Errorf("Expected method summary, got %q", messages[0].Summary)
+	}
+	if ref := messages[0].Payload.Properties["params"].Ref; ref != "#/components/schemas/rpcAddParams" {
+		t.Errorf("Expected params to reference rpcAddParams, got %q", ref)
+	}
+	if len(channel.Publish.Message.OneOf) != 2 {
+		t.Errorf("Expected result messages for each method")
+	}
+	if _, ok := router.asyncAPISpec.Components.Messages["add"]; !ok {
+		t.Error("Expected add message in components")
+	}
+}
+
+func TestSSERingBufferRetention(t *testing.T) {
+	buffer := NewSSERingBuffer(SSEHistoryOptions{MaxEvents: 3, MaxAge: time.Minute})
+	now := time.Now()
+	buffer.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		message, err := buffer.Append("prices", SSEMessage{Data: i})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+		if want := fmt.Sprint(i + 1); message.ID != want {
+			t.Errorf("Expected auto ID %s, got %s", want, message.ID)
+		}
+	}
+
+	if n := buffer.Len("prices"); n != 3 {
+		t.Errorf("Expected 3 retained events, got %d", n)
+	}
+
+	missed, _ := buffer.Since("prices", "1")
+	if len(missed) != 3 || missed[0].ID != "3" {
+		t.Errorf("Expected events 3-5 after evicted ID 1, got %+v", missed)
+	}
+
+	missed, _ = buffer.Since("prices", "4")
+	if len(missed) != 1 || missed[0].ID != "5" {
+		t.Errorf("Expected event 5 after ID 4, got %+v", missed)
+	}
+
+	if message, _ := buffer.Append("orders", SSEMessage{}); message.ID != "1" {
+		t.Errorf("Expected IDs to be per stream, got %s", message.ID)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if n := buffer.Len("prices"); n != 0 {
+		t.Errorf("Expected events to expire by age, got %d", n)
+	}
+}
+
+func TestSSERingBufferCustomIDs(t *testing.T) {
+	buffer := NewSSERingBuffer(SSEHistoryOptions{})
+	for _, id := range []string{"500", "20", "7"} {
+		buffer.Append("prices", SSEMessage{ID: id})
+	}
+
+	missed, _ := buffer.Since("prices", "20")
+	if len(missed) != 1 || missed[0].ID != "7" {
+		t.Errorf("Expected event 7 after ID 20, got %+v", missed)
+	}
+
+	missed, _ = buffer.Since("prices", "2")
+	if len(missed) != 3 {
+		t.Errorf("Expected all retained events for an unknown ID, got %+v", missed)
+	}
+}
+
+func TestSSEReplayLastEventID(t *testing.T) {
+	history := NewSSERingBuffer(SSEHistoryOptions{})
+
+	router := NewRouter()
+	router.SSE("/events", func(conn *SSEConnection, params struct{}) error {
+		return conn.SendMessage(SSEMessage{Event: "live", Data: "now"})
+	}, WithSSEHistory(history))
+
+	for i := 1; i <= 3; i++ {
+		if err := router.PublishSSE("/events", SSEMessage{Event: "tick", Data: i}); err != nil {
+			t.Fatalf("PublishSSE failed: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Last-Event-ID", "1")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	for _, id := range []string{"id: 2\n", "id: 3\n", "id: 4\n"} {
+		if !strings.Contains(body, id) {
+			t.Errorf("Expected %q in stream, got %q", id, body)
+		}
+	}
+	if strings.Contains(body, "id: 1\n") {
+		t.Errorf("Did not expect already seen event 1 to be replayed: %q", body)
+	}
+	if strings.Index(body, "id: 3\n") > strings.Index(body, "event: live") {
+		t.Errorf("Expected replayed events before live events: %q", body)
+	}
+}
+
+func TestSSEHeartbeatAndDisconnect(t *testing.T) {
+	router := NewRouter()
+	closed := make(chan struct{})
+	router.SSE("/events", func(conn *SSEConnection, params struct{}) error {
+		<-conn.Done()
+		close(closed)
+		return nil
+	}, WithSSEHeartbeat(10*time.Millisecond))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 64)
+	n, err := resp.Body.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+	if !strings.Contains(string(buf[:n]), ": ping") {
+		t.Errorf("Expected ping comment, got %q", buf[:n])
+	}
+	if len(router.ConnectionManager().SSEConnections()) != 1 {
+		t.Errorf("Expected active connection in manager")
+	}
+
+	cancel()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Done to be closed after client disconnect")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(router.ConnectionManager().SSEConnections()) != 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(router.ConnectionManager().SSEConnections()) != 0 {
+		t.Error("Expected disconnected client to be removed from manager")
+	}
+}
+
+func TestSSECloseRemovesConnection(t *testing.T) {
+	router := NewRouter()
+	router.initAsyncAPI()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn := &SSEConnection{router: router, clientID: "c1", ctx: ctx, cancel: cancel}
+	router.connectionManager.AddSSEConnection("c1", conn)
+
+	conn.Close()
+
+	if _, exists := router.connectionManager.SSEConnections()["c1"]; exists {
+		t.Error("Expected Close to remove connection from manager")
+	}
+	select {
+	case <-conn.Done():
+	default:
+		t.Error("Expected Done to be closed after Close")
+	}
+}
+
+// nonFlushingWriter is a ResponseWriter without http.Flusher support
+type nonFlushingWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *nonFlushingWriter) Header() http.Header         { return w.header }
+func (w *nonFlushingWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *nonFlushingWriter) WriteHeader(status int)      { w.status = status }
+
+func TestSSERequiresFlusher(t *testing.T) {
+	router := NewRouter()
+	called := false
+	router.SSE("/events", func(conn *SSEConnection, params struct{}) error {
+		called = true
+		return nil
+	})
+
+	w := &nonFlushingWriter{header: http.Header{}}
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events", nil))
+
+	if w.status != http.StatusInternalServerError {
+		t.Errorf("Expected status 500, got %d", w.status)
+	}
+	if called {
+		t.Error("Expected handler not to run without streaming support")
+	}
+
+	conn := &SSEConnection{writer: w}
+	if err := conn.SendMessage(SSEMessage{Data: "x"}); err != ErrSSEStreamingUnsupported {
+		t.Errorf("Expected ErrSSEStreamingUnsupported, got %v", err)
+	}
+}
+
+type ssePriceUpdate struct {
+	Symbol string  `json:"symbol"`
+	Price  float64 `json:"price"`
+}
+
+func TestSSETypedEvents(t *testing.T) {
+	priceUpdate := WithSSEEvent[ssePriceUpdate]("price.update")
+	notice := WithSSEEvent[string]("notice")
+
+	router := NewRouter()
+	router.SSE("/prices", func(conn *SSEConnection, params struct{}) error {
+		if err := priceUpdate.Send(conn, ssePriceUpdate{Symbol: "ACME", Price: 42.5}); err != nil {
+			return err
+		}
+		return notice.Send(conn, "market\nclosed")
+	}, priceUpdate, notice)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/prices", nil))
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: price.update\ndata: {\"symbol\":\"ACME\",\"price\":42.5}\n\n") {
+		t.Errorf("Expected typed price.update event, got %q", body)
+	}
+	if !strings.Contains(body, "event: notice\ndata: market\ndata: closed\n\n") {
+		t.Errorf("Expected multiline data split into data lines, got %q", body)
+	}
+
+	message := router.asyncAPISpec.Channels["/prices"].Subscribe.Message
+	if len(message.OneOf) != 2 {
+		t.Fatalf("Expected one message per event, got %d", len(message.OneOf))
+	}
+	if message.OneOf[0].Name != "price.update" || message.OneOf[0].Payload.Ref != "#/components/schemas/ssePriceUpdate" {
+		t.Errorf("Unexpected price.update message: %+v", message.OneOf[0])
+	}
+	if message.OneOf[1].Name != "notice" || message.OneOf[1].ContentType != "text/plain" {
+		t.Errorf("Unexpected notice message: %+v", message.OneOf[1])
+	}
+	if _, ok := router.asyncAPISpec.Components.Messages["price.update"]; !ok {
+		t.Error("Expected price.update in component messages")
+	}
+}
+
+func TestEncodeSSEData(t *testing.T) {
+	tests := []struct {
+		data interface{}
+		want []string
+	}{
+		{"single", []string{"single"}},
+		{"a\r\nb\rc\nd", []string{"a", "b", "c", "d"}},
+		{map[string]int{"n": 1}, []string{`{"n":1}`}},
+	}
+
+	for _, tt := range tests {
+		got, err := encodeSSEData(tt.data)
+		if err != nil {
+			t.Fatalf("encodeSSEData(%v) failed: %v", tt.data, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("encodeSSEData(%q) = %q, want %q", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestAsyncAPI3Generation(t *testing.T) {
+	router := NewRouter()
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+
+	router.WebSocket("/ws/chat/:room", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	}, WithAsyncSummary("Chat"))
+	router.WebSocket("/ws/echo", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	})
+	router.SSE("/prices", func(conn *SSEConnection, params struct{}) error {
+		return nil
+	}, WithSSEEvent[ssePriceUpdate]("price.update"))
+
+	spec := router.AsyncAPIV3()
+	if spec.AsyncAPI != "3.0.0" {
+		t.Errorf("Expected version 3.0.0, got %s", spec.AsyncAPI)
+	}
+	if server := spec.Servers["ws"]; server.Host != "localhost:8080" || server.Protocol != "ws" {
+		t.Errorf("Unexpected server: %+v", server)
+	}
+
+	channel, exists := spec.Channels["ws_chat_room"]
+	if !exists {
+		t.Fatalf("Expected ws_chat_room channel, got %v", spec.Channels)
+	}
+	if channel.Address != "/ws/chat/{room}" {
+		t.Errorf("Expected address /ws/chat/{room}, got %s", channel.Address)
+	}
+	if _, ok := channel.Parameters["room"]; !ok {
+		t.Error("Expected room channel parameter")
+	}
+	if ref := channel.Messages["WSTestMessage"].Ref; ref != "#/components/messages/WSTestMessage" {
+		t.Errorf("Expected channel message to reference components, got %q", ref)
+	}
+
+	receive := spec.Operations["ws_chat_room_receive"]
+	if receive.Action != AsyncAPIActionReceive || receive.Channel.Ref != "#/channels/ws_chat_room" {
+		t.Errorf("Unexpected receive operation: %+v", receive)
+	}
+	if receive.Reply == nil || receive.Reply.Messages[0].Ref != "#/channels/ws_chat_room/messages/WSTestResponse" {
+		t.Errorf("Expected reply with response message, got %+v", receive.Reply)
+	}
+
+	// Both WebSocket channels share the same message components
+	if len(spec.Components.Messages) != 3 {
+		t.Errorf("Expected shared WS messages plus price.update, got %v", spec.Components.Messages)
+	}
+
+	send := spec.Operations["prices_send"]
+	if send.Action != AsyncAPIActionSend || len(send.Messages) != 1 || send.Messages[0].Ref != "#/channels/prices/messages/price.update" {
+		t.Errorf("Unexpected send operation: %+v", send)
+	}
+
+	var buf bytes.Buffer
+	var doc map[string]interface{}
+	if err := NewRouter().WriteAsyncAPISpec(&buf, SpecFormatJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc["asyncapi"] != AsyncAPIVersion2 {
+		t.Errorf("Expected routers to default to AsyncAPI 2.x, got %v", doc["asyncapi"])
+	}
+}
+
+func TestAsyncAPI3JSONRPCOperations(t *testing.T) {
+	router := newTestJSONRPCRouter()
+	spec := router.asyncAPI3Spec
+
+	operation, exists := spec.Operations["rpc_add"]
+	if !exists {
+		t.Fatalf("Expected rpc_add operation, got %v", spec.Operations)
+	}
+	if operation.Messages[0].Ref != "#/channels/rpc/messages/add" {
+		t.Errorf("Unexpected request message ref %q", operation.Messages[0].Ref)
+	}
+	if operation.Reply == nil || operation.Reply.Messages[0].Ref != "#/channels/rpc/messages/add.result" {
+		t.Errorf("Unexpected reply %+v", operation.Reply)
+	}
+	if len(spec.Channels["rpc"].Messages) != 4 {
+		t.Errorf("Expected request and result messages for both methods, got %v", spec.Channels["rpc"].Messages)
+	}
+}
+
+type asyncSharedOrder struct {
+	Status    string            `json:"status" enum:"open,filled" description:"Order status"`
+	Quantity  int               `json:"quantity" min:"1" max:"100"`
+	Priority  int               `json:"priority" enum:"1,2,3"`
+	Express   bool              `json:"express" enum:"true"`
+	Symbol    string            `json:"symbol" pattern:"^[A-Z]+$"`
+	Note      *string           `json:"note,omitempty"`
+	Labels    map[string]string `json:"labels"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func TestAsyncAPISchemaFidelity(t *testing.T) {
+	router := NewRouter()
+	router.OpinionatedPOST("/orders", func(ctx *Context, input asyncSharedOrder) (*asyncSharedOrder, error) {
+		return &input, nil
+	})
+	router.WebSocket("/ws/orders", func(conn *WSConnection, message asyncSharedOrder) (*asyncSharedOrder, error) {
+		return &message, nil
+	})
+
+	asyncSchema, exists := router.asyncAPISpec.Components.Schemas["asyncSharedOrder"]
+	if !exists {
+		t.Fatal("Expected asyncSharedOrder registered in AsyncAPI components")
+	}
+	openAPISchema := router.openAPISpec.Components.Schemas["asyncSharedOrder"]
+
+	if !reflect.DeepEqual(asyncSchema, openAPISchema) {
+		t.Errorf("Expected identical schemas in both specs:\nasync:   %+v\nopenapi: %+v", asyncSchema, openAPISchema)
+	}
+
+	props := asyncSchema.Properties
+	if len(props["status"].Enum) != 2 || props["status"].Description != "Order status" {
+		t.Errorf("Expected enum and description on status, got %+v", props["status"])
+	}
+	if enum := props["priority"].Enum; !reflect.DeepEqual(enum, []interface{}{int64(1), int64(2), int64(3)}) {
+		t.Errorf("Expected integer enum values on priority, got %#v", enum)
+	}
+	if enum := props["express"].Enum; !reflect.DeepEqual(enum, []interface{}{true}) {
+		t.Errorf("Expected boolean enum values on express, got %#v", enum)
+	}
+	if props["quantity"].Minimum == nil || *props["quantity"].Maximum != 100 {
+		t.Errorf("Expected min/max on quantity, got %+v", props["quantity"])
+	}
+	if props["symbol"].Pattern != "^[A-Z]+$" {
+		t.Errorf("Expected pattern on symbol, got %q", props["symbol"].Pattern)
+	}
+	if types, ok := props["note"].Type.([]interface{}); !ok || len(types) != 2 || types[1] != "null" {
+		t.Errorf("Expected nullable note, got %v", props["note"].Type)
+	}
+	if props["labels"].AdditionalProperties == nil {
+		t.Error("Expected map to use additionalProperties")
+	}
+	if props["created_at"].Format != "date-time" {
+		t.Errorf("Expected date-time format, got %q", props["created_at"].Format)
+	}
+}
+
+// asyncBindingParams is bound from the upgrade and stream requests in binding tests
+type asyncBindingParams struct {
+	Room  string `query:"room" description:"Room to join" required:"true"`
+	Token string `header:"X-Token"`
+}
+
+// TestAsyncAPIBindings tests ws channel bindings and http operation bindings derived from params types
+func TestAsyncAPIBindings(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws/chat", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	}, WithWSParams[asyncBindingParams]())
+	router.SSE("/events", func(conn *SSEConnection, params asyncBindingParams) error {
+		return nil
+	})
+
+	spec := router.AsyncAPIV2()
+
+	ws, ok := spec.Channels["/ws/chat"].Bindings["ws"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected ws channel binding, got %v", spec.Channels["/ws/chat"].Bindings)
+	}
+	if ws["method"] != http.MethodGet || ws["bindingVersion"] != asyncAPIWSBindingVersion {
+		t.Errorf("Unexpected ws binding: %v", ws)
+	}
+	query := ws["query"].(*AsyncAPISchema)
+	if query.Properties["room"].Description != "Room to join" || len(query.Required) != 1 || query.Required[0] != "room" {
+		t.Errorf("Unexpected query schema: %+v", query)
+	}
+	if headers := ws["headers"].(*AsyncAPISchema); headers.Properties["X-Token"].Type != "string" {
+		t.Errorf("Unexpected headers schema: %+v", headers)
+	}
+
+	binding, ok := spec.Channels["/events"].Subscribe.Bindings["http"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected http operation binding, got %v", spec.Channels["/events"].Subscribe.Bindings)
+	}
+	if _, exists := binding["query"].(*AsyncAPISchema).Properties["room"]; !exists {
+		t.Errorf("Expected room query parameter in http binding, got %v", binding)
+	}
+}
+
+// TestAsyncAPIServers tests servers derived from the OpenAPI servers and registered endpoints
+func TestAsyncAPIServers(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	})
+	router.SSE("/events", func(conn *SSEConnection, params struct{}) error {
+		return nil
+	})
+
+	spec := router.AsyncAPIV2()
+	if spec.Servers["ws"].URL != "ws://localhost:8080" || spec.Servers["http"].URL != "http://localhost:8080" {
+		t.Errorf("Unexpected default servers: %+v", spec.Servers)
+	}
+
+	router.AddServer(OpenAPIServer{URL: "https://api.example.com/v1", Description: "Production"})
+	spec = router.AsyncAPIV2()
+	if len(spec.Servers) != 2 {
+		t.Fatalf("Expected 2 servers, got %+v", spec.Servers)
+	}
+	if server := spec.Servers["wss"]; server.URL != "wss://api.example.com/v1" || server.Protocol != "wss" {
+		t.Errorf("Unexpected wss server: %+v", server)
+	}
+	if server := spec.Servers["https"]; server.URL != "https://api.example.com/v1" {
+		t.Errorf("Unexpected https server: %+v", server)
+	}
+
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+	spec3 := router.AsyncAPIV3()
+	if server := spec3.Servers["wss"]; server.Host != "api.example.com" || server.Pathname != "/v1" {
+		t.Errorf("Unexpected 3.0 server: %+v", server)
+	}
+}
+
+// TestAsyncAPISecurity tests security scheme conversion and security requirements on servers and operations
+func TestAsyncAPISecurity(t *testing.T) {
+	router := NewRouter()
+	router.RegisterSecurityScheme("apiKey", NewSecurityScheme(SecurityTypeAPIKey).APIKey("X-API-Key", "header").Build())
+	router.RegisterSecurityScheme("bearer", NewSecurityScheme(SecurityTypeHTTP).HTTPScheme("bearer").BearerFormat("JWT").Build())
+	router.SetGlobalSecurity(OpenAPISecurityRequirement{"apiKey": {}})
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	}, WithAsyncSecurity(OpenAPISecurityRequirement{"bearer": {}}))
+
+	spec := router.AsyncAPIV2()
+	if scheme := spec.Components.SecuritySchemes["apiKey"]; scheme.Type != "httpApiKey" || scheme.Name != "X-API-Key" || scheme.In != "header" {
+		t.Errorf("Unexpected apiKey scheme: %+v", scheme)
+	}
+	if scheme := spec.Components.SecuritySchemes["bearer"]; scheme.Type != SecurityTypeHTTP || scheme.Scheme != "bearer" {
+		t.Errorf("Unexpected bearer scheme: %+v", scheme)
+	}
+	if security := spec.Servers["ws"].Security; len(security) != 1 || security[0]["apiKey"] == nil {
+		t.Errorf("Expected global security on server, got %v", security)
+	}
+	if security := spec.Channels["/ws"].Subscribe.Security; len(security) != 1 || security[0]["bearer"] == nil {
+		t.Errorf("Expected bearer security on operation, got %v", security)
+	}
+
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+	spec3 := router.AsyncAPIV3()
+	operation := spec3.Operations["ws_receive"]
+	if len(operation.Security) != 1 || operation.Security[0].Ref != "#/components/securitySchemes/bearer" {
+		t.Errorf("Unexpected 3.0 operation security: %v", operation.Security)
+	}
+	if _, exists := spec3.Components.SecuritySchemes["bearer"]; !exists {
+		t.Errorf("Expected bearer scheme in 3.0 components")
+	}
+}
+
+// TestWSBindParams tests binding upgrade request parameters inside a WebSocket handler
+func TestWSBindParams(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws/:room", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		var params struct {
+			Room string `path:"room"`
+			Name string `query:"name"`
+		}
+		if err := conn.BindParams(&params); err != nil {
+			return nil, err
+		}
+		return &WSTestResponse{Echo: params.Room + ":" + params.Name}, nil
+	})
+
+	conn := dialTestWebSocket(t, router, "/ws/lobby?name=ann")
+	if err := conn.WriteJSON(WSMessage{Type: "message", Payload: WSTestMessage{Text: "hello"}}); err != nil {
+		t.Fatalf("Failed to write message: %v", err)
+	}
+
+	var reply struct {
+		Payload WSTestResponse `json:"payload"`
+	}
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Payload.Echo != "lobby:ann" {
+		t.Errorf("Expected lobby:ann, got %q", reply.Payload.Echo)
+	}
+}
+
+// asyncTraceHeaders documents message headers in builder tests
+type asyncTraceHeaders struct {
+	TraceID string `json:"traceId" description:"Correlation identifier"`
+}
+
+// TestAsyncAPIBuilder tests document-level configuration through the fluent builder
+func TestAsyncAPIBuilder(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	})
+
+	builder := router.AsyncAPI().
+		SetTitle("Realtime API").
+		SetVersion("2.1.0").
+		SetDescription("Streaming endpoints").
+		AddProductionServer("rt.example.com").
+		AddTagWithDocs("chat", "Chat channels", "https://example.com/chat", "Chat guide").
+		SetExternalDocs("https://example.com/docs", "Full documentation").
+		SetDefaultContentType("application/json")
+	if err := builder.Validate(); err != nil {
+		t.Fatalf("Unexpected validation error: %v", err)
+	}
+	builder.Build()
+
+	spec := router.AsyncAPIV2()
+	if spec.Info.Title != "Realtime API" || spec.Info.Version != "2.1.0" || spec.Info.Description != "Streaming endpoints" {
+		t.Errorf("Unexpected info: %+v", spec.Info)
+	}
+	if len(spec.Servers) != 1 || spec.Servers["production"].URL != "wss://rt.example.com" {
+		t.Errorf("Expected only the configured server, got %+v", spec.Servers)
+	}
+	if len(spec.Tags) != 1 || spec.Tags[0].ExternalDocs.URL != "https://example.com/chat" {
+		t.Errorf("Unexpected tags: %+v", spec.Tags)
+	}
+	if spec.ExternalDocs == nil || spec.DefaultContentType != "application/json" {
+		t.Errorf("Expected external docs and default content type, got %+v %q", spec.ExternalDocs, spec.DefaultContentType)
+	}
+	if spec.Info.Tags != nil {
+		t.Errorf("Expected 2.x info without tags, got %+v", spec.Info.Tags)
+	}
+
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+	spec3 := router.AsyncAPIV3()
+	if len(spec3.Info.Tags) != 1 || spec3.Info.ExternalDocs == nil || spec3.DefaultContentType != "application/json" {
+		t.Errorf("Expected tags and docs in 3.0 info, got %+v", spec3.Info)
+	}
+	if server := spec3.Servers["production"]; server.Host != "rt.example.com" || server.Protocol != "wss" {
+		t.Errorf("Unexpected 3.0 server: %+v", server)
+	}
+
+	if err := router.AsyncAPI().SetTitle("Untitled").Validate(); err == nil {
+		t.Error("Expected validation error without version")
+	}
+}
+
+// TestAsyncAPIHandlerOptions tests operation IDs, deprecation, examples and headers on async endpoints
+func TestAsyncAPIHandlerOptions(t *testing.T) {
+	router := NewRouter()
+	router.WebSocket("/ws/chat", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	},
+		WithAsyncOperationID("chat"),
+		WithAsyncDeprecated(true),
+		WithAsyncExample("greeting", "A greeting", WSTestMessage{Text: "hi"}),
+		WithAsyncHeaders[asyncTraceHeaders](),
+	)
+	router.SSE("/prices", func(conn *SSEConnection, params struct{}) error {
+		return nil
+	},
+		WithSSEEvent[ssePriceUpdate]("price.update"),
+		WithAsyncOperationID("streamPrices"),
+		WithAsyncExample("price.update", "A price tick", ssePriceUpdate{Symbol: "ACME", Price: 42}),
+	)
+
+	spec := router.AsyncAPIV2()
+	chat := spec.Channels["/ws/chat"]
+	if chat.Subscribe.OperationID != "chat" || chat.Publish.OperationID != "chatReply" {
+		t.Errorf("Unexpected operation IDs: %q %q", chat.Subscribe.OperationID, chat.Publish.OperationID)
+	}
+	if !chat.Subscribe.Deprecated || !chat.Publish.Deprecated {
+		t.Error("Expected deprecated operations")
+	}
+	message := chat.Subscribe.Message
+	if len(message.Examples) != 1 || message.Examples[0].Payload.(WSTestMessage).Text != "hi" {
+		t.Errorf("Unexpected examples: %+v", message.Examples)
+	}
+	if message.Headers == nil || message.Headers.Ref != "#/components/schemas/asyncTraceHeaders" {
+		t.Errorf("Unexpected headers: %+v", message.Headers)
+	}
+
+	event := spec.Components.Messages["price.update"]
+	if len(event.Examples) != 1 || event.Examples[0].Summary != "A price tick" {
+		t.Errorf("Expected example on matching event, got %+v", event.Examples)
+	}
+
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+	spec3 := router.AsyncAPIV3()
+	if operation, exists := spec3.Operations["chat"]; !exists || !operation.Deprecated {
+		t.Errorf("Expected deprecated chat operation, got %v", spec3.Operations)
+	}
+	if _, exists := spec3.Operations["streamPrices"]; !exists {
+		t.Errorf("Expected streamPrices operation, got %v", spec3.Operations)
+	}
+}
+
 func BenchmarkWSConnectionMetadata(b *testing.B) {
 	conn := &WSConnection{
 		metadata: make(map[string]interface{}),
@@ -828,3 +1893,161 @@ func BenchmarkConnectionManagerOperations(b *testing.B) {
 		cm.RemoveSSEConnection(clientID)
 	}
 }
+
+// TestTracingAsyncSpans tests the spans of WebSocket messages and SSE streams
+func TestTracingAsyncSpans(t *testing.T) {
+	exporter := NewInMemorySpanExporter()
+	router := NewRouter()
+	router.Use(tracingTestMiddleware(NewTracer("realtime", exporter)))
+
+	router.WebSocket("/rooms/:room", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		if message.ID == 0 {
+			return nil, NewWSError("INVALID", "missing id")
+		}
+		return &WSTestResponse{Echo: message.Text}, nil
+	})
+	router.SSE("/feed", func(conn *SSEConnection, params struct{}) error {
+		conn.SendMessage(SSEMessage{Event: "tick", ID: "1", Data: "a"})
+		return errors.New("feed closed")
+	})
+	router.SSE("/firehose", func(conn *SSEConnection, params struct{}) error {
+		for i := 0; i < MaxSpanEvents+10; i++ {
+			conn.SendMessage(SSEMessage{Event: "tick", Data: i})
+		}
+		return nil
+	})
+
+	client := dialTestWebSocket(t, router, "/rooms/lobby")
+	for _, message := range []WSTestMessage{{Text: "hi", ID: 1}, {Text: "oops"}} {
+		client.WriteJSON(WSMessage{Type: "chat", Payload: message})
+		var response WSMessage
+		if err := client.ReadJSON(&response); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(exporter.Spans()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a span per message, got %d", len(spans))
+	}
+	for _, span := range spans {
+		if span.Name != "WS /rooms/:room" || span.Kind != SpanKindConsumer || !span.ParentSpanID.IsValid() || span.Attributes["messaging.operation"] != "chat" {
+			t.Errorf("Unexpected message span %s %s %v", span.Name, span.Kind, span.Attributes)
+		}
+	}
+	if spans[0].StatusCode != SpanStatusUnset || spans[1].StatusCode != SpanStatusError {
+		t.Errorf("Expected the handler error on the second message span, got %s and %s", spans[0].StatusCode, spans[1].StatusCode)
+	}
+
+	exporter.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/feed", nil))
+	spans = exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected a stream span and a server span, got %d", len(spans))
+	}
+	stream := spans[0]
+	if stream.Name != "SSE /feed" || stream.Kind != SpanKindProducer || stream.ParentSpanID != spans[1].SpanContext.SpanID {
+		t.Errorf("Unexpected stream span %s %s", stream.Name, stream.Kind)
+	}
+	if stream.StatusCode != SpanStatusError || stream.StatusMessage != "feed closed" {
+		t.Errorf("Expected the handler error on the stream span, got %s %q", stream.StatusCode, stream.StatusMessage)
+	}
+	if len(stream.Events) < 1 || stream.Events[0].Name != "sse.event" || stream.Events[0].Attributes["sse.event"] != "tick" {
+		t.Errorf("Expected an event per message sent, got %+v", stream.Events)
+	}
+
+	exporter.Reset()
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/firehose", nil))
+	stream = exporter.Spans()[0]
+	if len(stream.Events) != MaxSpanEvents || stream.DroppedEvents != 10 || stream.Attributes["sse.events_sent"] != MaxSpanEvents+10 {
+		t.Errorf("Expected span events to be capped and counted, got %d events, %d dropped, %v sent",
+			len(stream.Events), stream.DroppedEvents, stream.Attributes["sse.events_sent"])
+	}
+}
+
+// TestAsyncPanicRecovery tests that panics in WebSocket, JSON-RPC and SSE handlers are reported
+// without dropping the connection
+func TestAsyncPanicRecovery(t *testing.T) {
+	reports := make(chan *PanicError, 4)
+	router := NewRouter()
+	router.OnPanic(func(req *http.Request, err *PanicError) {
+		reports <- err
+	})
+
+	router.WebSocket("/chat", func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		if message.Text == "panic" {
+			panic("chat failed")
+		}
+		return &WSTestResponse{Echo: message.Text}, nil
+	})
+	router.JSONRPC("/rpc").Method("fail", func(conn *WSConnection, params struct{}) (*WSTestResponse, error) {
+		panic("rpc failed")
+	})
+	router.SSE("/feed", func(conn *SSEConnection, params struct{}) error {
+		conn.SendMessage(SSEMessage{Event: "tick", Data: "a"})
+		panic("feed failed")
+	})
+
+	client := dialTestWebSocket(t, router, "/chat")
+	client.WriteJSON(WSMessage{Type: "chat", ID: "1", Payload: WSTestMessage{Text: "panic"}})
+	var response WSMessage
+	if err := client.ReadJSON(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Type != WSMessageTypeError || response.ID != "1" || response.Error == nil || response.Error.Code != WSErrorInternal {
+		t.Errorf("Expected an internal error message, got %+v", response)
+	}
+	client.WriteJSON(WSMessage{Type: "chat", ID: "2", Payload: WSTestMessage{Text: "still here"}})
+	if err := client.ReadJSON(&response); err != nil || response.Type != WSMessageTypeResponse {
+		t.Errorf("Expected the connection to survive the panic, got %+v %v", response, err)
+	}
+	if report := <-reports; report.Value != "chat failed" || len(report.Stack) == 0 {
+		t.Errorf("Unexpected report %v", report)
+	}
+
+	rpc := dialTestWebSocket(t, router, "/rpc")
+	rpc.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","method":"fail","id":7}`))
+	var rpcResponse map[string]interface{}
+	if err := rpc.ReadJSON(&rpcResponse); err != nil {
+		t.Fatal(err)
+	}
+	rpcErr, _ := rpcResponse["error"].(map[string]interface{})
+	if rpcResponse["id"] != float64(7) || rpcErr["code"] != float64(JSONRPCInternalError) {
+		t.Errorf("Expected an internal JSON-RPC error, got %v", rpcResponse)
+	}
+	if report := <-reports; report.Value != "rpc failed" {
+		t.Errorf("Unexpected report %v", report)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/feed", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "event: tick") {
+		t.Errorf("Expected the events sent before the panic, got %d %q", w.Code, w.Body.String())
+	}
+	if report := <-reports; report.Value != "feed failed" {
+		t.Errorf("Unexpected report %v", report)
+	}
+}
+
+// TestAsyncAPI3ChannelIDsUnique tests that patterns deriving the same channel key get distinct channels
+func TestAsyncAPI3ChannelIDsUnique(t *testing.T) {
+	router := NewRouter()
+	router.SetAsyncAPIVersion(AsyncAPIVersion3)
+	handler := func(conn *WSConnection, message WSTestMessage) (*WSTestResponse, error) {
+		return nil, nil
+	}
+	router.WebSocket("/ws/chat", handler)
+	router.WebSocket("/ws-chat", handler)
+
+	spec := router.AsyncAPIV3()
+	if spec.Channels["ws_chat"].Address != "/ws/chat" || spec.Channels["ws_chat_2"].Address != "/ws-chat" {
+		t.Errorf("Expected distinct channels, got %+v", spec.Channels)
+	}
+	if spec.Operations["ws_chat_2_receive"].Channel.Ref != "#/channels/ws_chat_2" {
+		t.Errorf("Expected the second operation on its own channel, got %+v", spec.Operations)
+	}
+}